@@ -0,0 +1,192 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+)
+
+const applyDesc = `
+This command installs or upgrades a set of releases described in a file, in
+the order of their dependencies.
+
+The file lists the releases with their chart, values and the releases they
+need:
+
+    releases:
+    - name: db
+      namespace: data
+      chart: bitnami/postgresql
+      version: 12.1.0
+      valuesFiles: [values/db.yaml]
+    - name: app
+      chart: ./charts/app
+      values:
+        replicaCount: 2
+      needs: [data/db]
+
+Values files and local charts are relative to the directory of the file, and
+releases without a namespace are deployed to the current namespace. A release
+is deployed once the releases it needs are ready, and releases that don't
+depend on each other are deployed in parallel. If a release fails, the
+releases that need it are skipped.
+
+Use '--dry-run' to print the plan without changing anything.
+`
+
+func newApplyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewApply(cfg)
+	var outfmt output.Format
+	var filename string
+
+	cmd := &cobra.Command{
+		Use:               "apply -f FILE",
+		Short:             "install or upgrade a set of releases in dependency order",
+		Long:              applyDesc,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noMoreArgsCompFunc,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			set, err := action.LoadReleaseSet(filename)
+			if err != nil {
+				return err
+			}
+			client.Namespace = settings.Namespace()
+			client.Settings = settings
+			if cfg.RESTClientGetter != nil {
+				client.ConfigurationFor = func(namespace string) (*action.Configuration, error) {
+					return newNamespaceConfiguration(cfg, namespace)
+				}
+			}
+
+			result, err := client.Run(set)
+			if result != nil {
+				if werr := outfmt.Write(out, &applyPrinter{result, client.DryRun}); werr != nil {
+					return werr
+				}
+			}
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&filename, "file", "f", "", "file describing the releases to apply")
+	f.BoolVar(&client.DryRun, "dry-run", false, "print the plan without installing or upgrading any release")
+	f.IntVar(&client.Parallelism, "parallelism", client.Parallelism, "maximum number of releases deployed at the same time")
+	f.BoolVar(&client.Wait, "wait", client.Wait, "wait until the resources of a release are ready before deploying the releases that need it. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking a release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.Atomic, "atomic", false, "if set, a release that fails is rolled back, or uninstalled if it was being installed")
+	f.BoolVar(&client.CreateNamespace, "create-namespace", false, "create the namespaces of the releases if not present")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	bindOutputFlag(cmd, &outfmt)
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+// newNamespaceConfiguration returns a new configuration like cfg, for the
+// releases of the given namespace.
+func newNamespaceConfiguration(cfg *action.Configuration, namespace string) (*action.Configuration, error) {
+	c := new(action.Configuration)
+	getter := &namespacedClientGetter{RESTClientGetter: settings.RESTClientGetter(), namespace: namespace}
+	if err := c.Init(getter, namespace, os.Getenv("HELM_DRIVER"), debug); err != nil {
+		return nil, err
+	}
+	c.RegistryClient = cfg.RegistryClient
+	c.LockHolder = cfg.LockHolder
+	c.CustomTemplateFuncs = cfg.CustomTemplateFuncs
+	return c, nil
+}
+
+// namespacedClientGetter overrides the namespace of a RESTClientGetter.
+type namespacedClientGetter struct {
+	genericclioptions.RESTClientGetter
+	namespace string
+}
+
+func (g *namespacedClientGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	return &namespacedClientConfig{g.RESTClientGetter.ToRawKubeConfigLoader(), g.namespace}
+}
+
+// namespacedClientConfig overrides the namespace of a ClientConfig.
+type namespacedClientConfig struct {
+	config    clientcmd.ClientConfig
+	namespace string
+}
+
+func (c *namespacedClientConfig) RawConfig() (clientcmdapi.Config, error) {
+	return c.config.RawConfig()
+}
+
+func (c *namespacedClientConfig) ClientConfig() (*rest.Config, error) {
+	return c.config.ClientConfig()
+}
+
+func (c *namespacedClientConfig) Namespace() (string, bool, error) {
+	return c.namespace, true, nil
+}
+
+func (c *namespacedClientConfig) ConfigAccess() clientcmd.ConfigAccess {
+	return c.config.ConfigAccess()
+}
+
+type applyPrinter struct {
+	result *action.ApplyResult
+	dryRun bool
+}
+
+func (p applyPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.result)
+}
+
+func (p applyPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.result)
+}
+
+func (p applyPrinter) WriteTable(out io.Writer) error {
+	table := uitable.New()
+	table.AddRow("STAGE", "NAME", "NAMESPACE", "CHART", "OPERATION", "NEEDS", "STATUS")
+	for _, s := range p.result.Steps {
+		table.AddRow(s.Stage, s.Name, s.Namespace, s.Chart, s.Operation, strings.Join(s.Needs, ","), s.Status)
+	}
+	if err := output.EncodeTable(out, table); err != nil {
+		return err
+	}
+	for _, s := range p.result.Steps {
+		if s.Error != "" {
+			_, _ = fmt.Fprintf(out, "%s %s: %s\n", s.Status, s.ID(), s.Error)
+		}
+	}
+	if p.dryRun {
+		_, _ = fmt.Fprintln(out, "\nDry run: run again without --dry-run to apply the plan.")
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestApplyCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "db", Version: 1, Status: release.StatusDeployed}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "apply with dry-run",
+		cmd:    "apply -f testdata/apply/releases.yaml --dry-run",
+		golden: "output/apply-dry-run.txt",
+		rels:   rels,
+	}, {
+		name:   "apply",
+		cmd:    "apply -f testdata/apply/releases.yaml",
+		golden: "output/apply.txt",
+		rels:   rels,
+	}, {
+		name:   "apply with json output",
+		cmd:    "apply -f testdata/apply/releases.yaml --dry-run -o json",
+		golden: "output/apply-dry-run.json",
+		rels:   rels,
+	}, {
+		name:      "apply with a dependency cycle",
+		cmd:       "apply -f testdata/apply/cycle.yaml",
+		golden:    "output/apply-cycle.txt",
+		wantError: true,
+	}, {
+		name:      "apply without file",
+		cmd:       "apply",
+		golden:    "output/apply-no-file.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestApplyFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "apply", false)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+)
+
+const diffUpgradeDesc = `
+This command shows the changes 'helm upgrade' would make to a release.
+
+The chart is rendered exactly as an upgrade would render it. Each resource is
+then compared both with the manifest stored for the deployed release and with
+the object currently in the cluster, so changes made out-of-band are visible.
+The values of Secrets are redacted, only showing which keys change. Nothing is
+modified.
+
+    $ helm diff upgrade --set image.tag=1.2.3 redis ./redis
+`
+
+func newDiffUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewDiff(cfg)
+	valueOpts := &values.Options{}
+	var outfmt output.Format
+
+	cmd := &cobra.Command{
+		Use:   "upgrade [RELEASE] [CHART]",
+		Short: "preview the changes of an upgrade",
+		Long:  diffUpgradeDesc,
+		Args:  require.ExactArgs(2),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) == 0 {
+				return compListReleases(toComplete, args, cfg)
+			}
+			if len(args) == 1 {
+				return compListCharts(toComplete, true)
+			}
+			return noMoreArgsComp()
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			client.Namespace = settings.Namespace()
+
+			registryClient, err := newRegistryClient(client.CertFile, client.KeyFile, client.CaFile,
+				client.InsecureSkipTLSverify, client.PlainHTTP)
+			if err != nil {
+				return fmt.Errorf("missing registry client: %w", err)
+			}
+			client.SetRegistryClient(registryClient)
+
+			if client.Version == "" && client.Devel {
+				debug("setting version to >0.0.0-0")
+				client.Version = ">0.0.0-0"
+			}
+
+			chartPath, err := client.ChartPathOptions.LocateChart(args[1], settings)
+			if err != nil {
+				return err
+			}
+
+			p := getter.All(settings)
+			vals, err := valueOpts.MergeValues(p)
+			if err != nil {
+				return err
+			}
+
+			ch, err := loader.Load(chartPath)
+			if err != nil {
+				return err
+			}
+			if req := ch.Metadata.Dependencies; req != nil {
+				if err := action.CheckDependencies(ch, req); err != nil {
+					return errors.Wrap(err, "An error occurred while checking for chart dependencies. You may need to run `helm dependency build` to fetch missing dependencies")
+				}
+			}
+
+			res, err := client.Run(args[0], ch, vals)
+			if err != nil {
+				return errors.Wrap(err, "DIFF FAILED")
+			}
+
+			return outfmt.Write(out, &diffPrinter{res})
+		},
+	}
+
+	f := cmd.Flags()
+	f.BoolVar(&client.Devel, "devel", false, "use development versions, too. Equivalent to version '>0.0.0-0'. If --version is set, this is ignored")
+	f.BoolVar(&client.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the diff will not validate rendered templates against the Kubernetes OpenAPI Schema")
+	f.BoolVar(&client.ResetValues, "reset-values", false, "when upgrading, reset the values to the ones built into the chart")
+	f.BoolVar(&client.ReuseValues, "reuse-values", false, "when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' is specified, this is ignored")
+	f.BoolVar(&client.ResetThenReuseValues, "reset-then-reuse-values", false, "when upgrading, reset the values to the ones built into the chart, apply the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' or '--reuse-values' is specified, this is ignored")
+	f.BoolVar(&client.SkipSchemaValidation, "skip-schema-validation", false, "if set, disables JSON schema validation")
+	f.BoolVar(&client.EnableDNS, "enable-dns", false, "enable DNS lookups when rendering templates")
+	f.IntVar(&client.Context, "context", 3, "number of lines of context to show around each change")
+	addChartPathOptionsFlags(f, &client.ChartPathOptions)
+	addValueOptionsFlags(f, valueOpts)
+	bindOutputFlag(cmd, &outfmt)
+	bindPostRenderFlag(cmd, &client.PostRenderer)
+
+	err := cmd.RegisterFlagCompletionFunc("version", func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != 2 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return compVersionFlag(args[1], toComplete)
+	})
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cmd
+}
+
+type diffPrinter struct {
+	result *action.DiffResult
+}
+
+func (d diffPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, d.result)
+}
+
+func (d diffPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, d.result)
+}
+
+func (d diffPrinter) WriteTable(out io.Writer) error {
+	if !d.result.Changed() {
+		_, _ = fmt.Fprintf(out, "Release %q has no changes.\n", d.result.Release)
+		return nil
+	}
+	for _, r := range d.result.Resources {
+		if r.Manifest.Change != action.ChangeUnchanged {
+			_, _ = fmt.Fprintf(out, "%s, %s, %s (%s) has been %s in the chart:\n%s\n",
+				r.Namespace, r.Name, r.Kind, r.APIVersion, r.Manifest.Change, r.Manifest.Diff)
+		}
+		if r.Live.Change != action.ChangeUnchanged && r.Live.Diff != r.Manifest.Diff {
+			_, _ = fmt.Fprintf(out, "%s, %s, %s (%s) will be %s in the cluster:\n%s\n",
+				r.Namespace, r.Name, r.Kind, r.APIVersion, r.Live.Change, r.Live.Diff)
+		}
+	}
+	return nil
+}
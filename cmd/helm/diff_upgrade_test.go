@@ -0,0 +1,56 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestDiffUpgradeCmd(t *testing.T) {
+	rels := []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "funny-bunny"})}
+
+	tests := []cmdTestCase{{
+		name:   "diff upgrade with changes",
+		cmd:    "diff upgrade funny-bunny testdata/testcharts/chart-with-secret",
+		golden: "output/diff-upgrade.txt",
+		rels:   rels,
+	}, {
+		name:   "diff upgrade as json",
+		cmd:    "diff upgrade funny-bunny testdata/testcharts/chart-with-secret -o json",
+		golden: "output/diff-upgrade.json",
+		rels:   rels,
+	}, {
+		name:      "diff upgrade of missing release",
+		cmd:       "diff upgrade missing testdata/testcharts/chart-with-secret",
+		golden:    "output/diff-upgrade-missing.txt",
+		wantError: true,
+	}, {
+		name:      "diff upgrade without args",
+		cmd:       "diff upgrade",
+		golden:    "output/diff-upgrade-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestDiffUpgradeFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "diff upgrade", false)
+	checkFileCompletion(t, "diff upgrade myrelease", true)
+	checkFileCompletion(t, "diff upgrade myrelease repo/chart", false)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -38,6 +39,7 @@ import (
 
 const (
 	outputFlag         = "output"
+	outputEventsFlag   = "output-events"
 	postRenderFlag     = "post-renderer"
 	postRenderArgsFlag = "post-renderer-args"
 )
@@ -115,6 +117,52 @@ func (o *outputValue) Set(s string) error {
 	return nil
 }
 
+// bindOutputEventsFlag will add the output-events flag to the given command.
+// When set, the progress events of the action are written to the standard
+// error of the command as they happen, apart from its output.
+func bindOutputEventsFlag(cmd *cobra.Command, cfg *action.Configuration) {
+	cmd.Flags().Var(&outputEventsValue{cfg: cfg, cmd: cmd}, outputEventsFlag,
+		"print progress events to stderr as they happen in the specified format. Allowed values: json")
+}
+
+// addHookParallelismFlag adds the hook-parallelism flag, which sets how many
+// hooks of the same weight are run at the same time.
+func addHookParallelismFlag(f *pflag.FlagSet, cfg *action.Configuration) {
+	f.IntVar(&cfg.HookParallelism, "hook-parallelism", 1, "maximum number of hooks with the same weight to run at the same time")
+}
+
+type outputEventsValue struct {
+	cfg    *action.Configuration
+	cmd    *cobra.Command
+	format string
+}
+
+func (o *outputEventsValue) String() string {
+	return o.format
+}
+
+func (o *outputEventsValue) Type() string {
+	return "format"
+}
+
+func (o *outputEventsValue) Set(s string) error {
+	switch s {
+	case "":
+		o.cfg.EventHandler = nil
+	case "json":
+		enc := json.NewEncoder(o.cmd.ErrOrStderr())
+		o.cfg.EventHandler = func(e action.Event) {
+			if err := enc.Encode(e); err != nil {
+				log.Printf("unable to write event: %s", err)
+			}
+		}
+	default:
+		return fmt.Errorf("invalid events format %q. Allowed values: json", s)
+	}
+	o.format = s
+	return nil
+}
+
 func bindPostRenderFlag(cmd *cobra.Command, varRef *postrender.PostRenderer) {
 	p := &postRendererOptions{varRef, "", []string{}}
 	cmd.Flags().Var(&postRendererString{p}, postRenderFlag, "the path to an executable to be used for post rendering. If it exists in $PATH, the binary will be used, otherwise it will try to look for the executable at the given path")
@@ -17,9 +17,14 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
@@ -93,3 +98,26 @@ func outputFlagCompletionTest(t *testing.T, cmdName string) {
 	}}
 	runTestCmd(t, tests)
 }
+
+func TestOutputEventsFlag(t *testing.T) {
+	var cfg action.Configuration
+	var out, errOut bytes.Buffer
+	cmd := &cobra.Command{}
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	bindOutputEventsFlag(cmd, &cfg)
+
+	if err := cmd.Flags().Set(outputEventsFlag, "json"); err != nil {
+		t.Fatal(err)
+	}
+	cfg.EventHandler(action.Event{Type: action.EventPhaseStarted, Release: "foo", Phase: "install"})
+
+	// Events are kept apart from the output of the command, which may be
+	// JSON or YAML as well.
+	if out.Len() != 0 {
+		t.Errorf("expected no events in the output, got %q", out.String())
+	}
+	if !strings.Contains(errOut.String(), `"type":"PhaseStarted"`) {
+		t.Errorf("expected the event on stderr, got %q", errOut.String())
+	}
+}
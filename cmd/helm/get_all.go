@@ -58,7 +58,7 @@ func newGetAllCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				}
 				return tpl(template, data, out)
 			}
-			return output.Table.Write(out, &statusPrinter{res, true, false, false, true, false})
+			return output.Table.Write(out, &statusPrinter{res, true, false, false, true, false, false})
 		},
 	}
 
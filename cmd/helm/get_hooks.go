@@ -20,21 +20,27 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/spf13/cobra"
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 const getHooksHelp = `
 This command downloads hooks for a given release.
 
 Hooks are formatted in YAML and separated by the YAML '---\n' separator.
+
+With --logs, the logs captured from Pod and Job hooks when they last ran are
+printed as YAML comments after each hook.
 `
 
 func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewGet(cfg)
+	var showLogs bool
 
 	cmd := &cobra.Command{
 		Use:   "hooks RELEASE_NAME",
@@ -54,12 +60,16 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			}
 			for _, hook := range res.Hooks {
 				fmt.Fprintf(out, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+				if showLogs {
+					writeHookLogComments(out, hook)
+				}
 			}
 			return nil
 		},
 	}
 
 	cmd.Flags().IntVar(&client.Version, "revision", 0, "get the named release with revision")
+	cmd.Flags().BoolVar(&showLogs, "logs", false, "print the logs captured from the hooks when they last ran")
 	err := cmd.RegisterFlagCompletionFunc("revision", func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) == 1 {
 			return compListRevisions(toComplete, cfg, args[0])
@@ -73,3 +83,17 @@ func newGetHooksCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	return cmd
 }
+
+// writeHookLogComments writes the logs captured from a hook as YAML comments.
+func writeHookLogComments(out io.Writer, hook *release.Hook) {
+	for _, l := range hook.LastRun.Logs {
+		truncated := ""
+		if l.Truncated {
+			truncated = " (truncated)"
+		}
+		fmt.Fprintf(out, "# Logs of container %s of pod %s%s:\n", l.Container, l.Pod, truncated)
+		for _, line := range strings.Split(strings.TrimSuffix(l.Log, "\n"), "\n") {
+			fmt.Fprintln(out, strings.TrimSpace("# "+line))
+		}
+	}
+}
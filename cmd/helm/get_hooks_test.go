@@ -23,11 +23,30 @@ import (
 )
 
 func TestGetHooks(t *testing.T) {
+	withLogs := func() []*release.Release {
+		rel := release.Mock(&release.MockReleaseOptions{Name: "aeneas"})
+		rel.Hooks[0].LastRun.Logs = []release.HookLog{
+			{Pod: "pre-install-hook", Container: "setup", Log: "preparing\n\ndone\n"},
+			{Pod: "pre-install-hook", Container: "main", Log: "...end of output", Truncated: true},
+		}
+		return []*release.Release{rel}
+	}
+
 	tests := []cmdTestCase{{
 		name:   "get hooks with release",
 		cmd:    "get hooks aeneas",
 		golden: "output/get-hooks.txt",
 		rels:   []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+	}, {
+		name:   "get hooks with logs",
+		cmd:    "get hooks aeneas --logs",
+		golden: "output/get-hooks-logs.txt",
+		rels:   withLogs(),
+	}, {
+		name:   "get hooks without logs",
+		cmd:    "get hooks aeneas",
+		golden: "output/get-hooks.txt",
+		rels:   withLogs(),
 	}, {
 		name:      "get hooks without args",
 		cmd:       "get hooks",
@@ -40,6 +40,13 @@ the chart is well-formed.
 If the linter encounters things that will cause the chart to fail installation,
 it will emit [ERROR] messages. If it encounters issues that break with convention
 or recommendation, it will emit [WARNING] messages.
+
+With '--check-values', the chart is also rendered to find the values that no
+template reads, and the values that templates read but that are not defined in
+the values files of the chart and its subcharts or in the given values. Only
+the templates executed with the given values are taken into account, so a
+value used only when a feature is enabled is reported as unused unless the
+feature is enabled, for example with '--set'.
 `
 
 func newLintCmd(out io.Writer) *cobra.Command {
@@ -150,6 +157,7 @@ func newLintCmd(out io.Writer) *cobra.Command {
 	f.BoolVar(&client.Quiet, "quiet", false, "print only warnings and errors")
 	f.BoolVar(&client.SkipSchemaValidation, "skip-schema-validation", false, "if set, disables JSON schema validation")
 	f.StringVar(&kubeVersion, "kube-version", "", "Kubernetes version used for capabilities and deprecation checks")
+	f.BoolVar(&client.CheckValues, "check-values", false, "warn of values no template reads and of undefined values read by templates")
 	addValueOptionsFlags(f, valueOpts)
 
 	return cmd
@@ -63,6 +63,20 @@ func TestLintCmdWithQuietFlag(t *testing.T) {
 
 }
 
+func TestLintCmdWithCheckValuesFlag(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:   "lint chart reading an undefined value using --check-values flag",
+		cmd:    "lint --check-values testdata/testcharts/alpine",
+		golden: "output/lint-check-values.txt",
+	}, {
+		name:      "lint chart reading an undefined value using --check-values and strict flags",
+		cmd:       "lint --check-values --strict testdata/testcharts/alpine",
+		golden:    "output/lint-check-values-strict.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
 func TestLintCmdWithKubeVersionFlag(t *testing.T) {
 	testChart := "testdata/testcharts/chart-with-deprecated-api"
 	tests := []cmdTestCase{{
@@ -26,12 +26,16 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/yaml"
 
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/engine"
 	"helm.sh/helm/v3/pkg/plugin"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
 const (
@@ -48,18 +52,23 @@ type pluginError struct {
 //
 // This follows a different pattern than the other commands because it has
 // to inspect its environment and then add commands to the base command
-// as it finds them.
-func loadPlugins(baseCmd *cobra.Command, out io.Writer) {
+// as it finds them. It returns the plugins found.
+func loadPlugins(baseCmd *cobra.Command, out io.Writer) []*plugin.Plugin {
 
 	// If HELM_NO_PLUGINS is set to 1, do not load plugins.
 	if os.Getenv("HELM_NO_PLUGINS") == "1" {
-		return
+		return nil
 	}
 
 	found, err := plugin.FindPlugins(settings.PluginsDirectory)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to load plugins: %s\n", err)
-		return
+		return nil
+	}
+
+	for _, plug := range found {
+		registerStorageDrivers(plug, os.Stderr)
+		registerKMSProviders(plug, os.Stderr)
 	}
 
 	// Now we create commands for all of these.
@@ -110,6 +119,97 @@ func loadPlugins(baseCmd *cobra.Command, out io.Writer) {
 			loadCompletionForPlugin(c, plug)
 		}
 	}
+	return found
+}
+
+// pluginStorageDrivers maps the names of the storage drivers registered by
+// plugins to the directory of their plugin. Plugins are loaded again for
+// completions, and their drivers are registered only once.
+var pluginStorageDrivers = make(map[string]string)
+
+// registerStorageDrivers makes the storage drivers of a plugin available
+// through HELM_DRIVER. Errors are reported to errOut.
+func registerStorageDrivers(plug *plugin.Plugin, errOut io.Writer) {
+	for _, sd := range plug.Metadata.StorageDrivers {
+		sd := sd
+		if pluginStorageDrivers[sd.Name] == plug.Dir {
+			continue
+		}
+		commands := strings.Fields(sd.Command)
+		if len(commands) == 0 {
+			fmt.Fprintf(errOut, "storage driver %q of plugin %q has no command\n", sd.Name, plug.Metadata.Name)
+			continue
+		}
+		err := driver.Register(sd.Name, func(namespace string, log func(string, ...interface{}), _ driver.Config) (driver.Driver, error) {
+			plugin.SetupPluginEnv(settings, plug.Metadata.Name, plug.Dir)
+			d := driver.NewPlugin(sd.Name, filepath.Join(plug.Dir, commands[0]), commands[1:], namespace)
+			d.Log = log
+			return d, nil
+		})
+		if err != nil {
+			fmt.Fprintf(errOut, "failed to register storage driver of plugin %q: %s\n", plug.Metadata.Name, err)
+			continue
+		}
+		pluginStorageDrivers[sd.Name] = plug.Dir
+	}
+}
+
+// pluginKMSProviders maps the names of the KMS providers registered by
+// plugins to the directory of their plugin, like pluginStorageDrivers.
+var pluginKMSProviders = make(map[string]string)
+
+// registerKMSProviders makes the KMS providers of a plugin available through
+// HELM_DRIVER_ENCRYPTION_KMS. Errors are reported to errOut.
+func registerKMSProviders(plug *plugin.Plugin, errOut io.Writer) {
+	for _, kp := range plug.Metadata.KMSProviders {
+		kp := kp
+		if pluginKMSProviders[kp.Name] == plug.Dir {
+			continue
+		}
+		commands := strings.Fields(kp.Command)
+		if len(commands) == 0 {
+			fmt.Fprintf(errOut, "KMS provider %q of plugin %q has no command\n", kp.Name, plug.Metadata.Name)
+			continue
+		}
+		err := driver.RegisterKMS(kp.Name, func() (driver.KeyProvider, error) {
+			plugin.SetupPluginEnv(settings, plug.Metadata.Name, plug.Dir)
+			return driver.NewKMSPlugin(kp.Name, filepath.Join(plug.Dir, commands[0]), commands[1:]), nil
+		})
+		if err != nil {
+			fmt.Fprintf(errOut, "failed to register KMS provider of plugin %q: %s\n", plug.Metadata.Name, err)
+			continue
+		}
+		pluginKMSProviders[kp.Name] = plug.Dir
+	}
+}
+
+// registerFunctionProviders makes the template functions provided by a plugin
+// available to the charts rendered with actionConfig. A function provided by
+// several plugins is taken from the first one.
+func registerFunctionProviders(actionConfig *action.Configuration, plug *plugin.Plugin) {
+	for _, fp := range plug.Metadata.FunctionProviders {
+		commands := strings.Fields(fp.Command)
+		if len(commands) == 0 {
+			fmt.Fprintf(os.Stderr, "function provider of plugin %q has no command\n", plug.Metadata.Name)
+			continue
+		}
+		provider := engine.NewFunctionProvider(plug.Metadata.Name, filepath.Join(plug.Dir, commands[0]), commands[1:])
+		provider.Env = []string{
+			"PATH=" + os.Getenv("PATH"),
+			"HELM_PLUGIN_NAME=" + plug.Metadata.Name,
+			"HELM_PLUGIN_DIR=" + plug.Dir,
+		}
+		if actionConfig.CustomTemplateFuncs == nil {
+			actionConfig.CustomTemplateFuncs = make(template.FuncMap)
+		}
+		for name, fn := range provider.Funcs(fp.Functions...) {
+			if _, ok := actionConfig.CustomTemplateFuncs[name]; ok {
+				fmt.Fprintf(os.Stderr, "template function %q of plugin %q is already provided, ignoring it\n", name, plug.Metadata.Name)
+				continue
+			}
+			actionConfig.CustomTemplateFuncs[name] = fn
+		}
+	}
 }
 
 func processParent(cmd *cobra.Command, args []string) ([]string, error) {
@@ -26,7 +26,9 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 
+	"helm.sh/helm/v3/pkg/plugin"
 	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
 )
 
 func TestManuallyProcessArgs(t *testing.T) {
@@ -161,6 +163,63 @@ func TestLoadPlugins(t *testing.T) {
 	}
 }
 
+func TestRegisterStorageDrivers(t *testing.T) {
+	plug := &plugin.Plugin{
+		Dir: "testdata/helmhome/helm/plugins/store",
+		Metadata: &plugin.Metadata{
+			Name:           "store",
+			StorageDrivers: []plugin.StorageDriver{{Name: "test-plugin-store", Command: "store.sh"}},
+		},
+	}
+
+	var errOut bytes.Buffer
+	// Plugins are loaded again for completions.
+	registerStorageDrivers(plug, &errOut)
+	registerStorageDrivers(plug, &errOut)
+	if errOut.Len() != 0 {
+		t.Errorf("expected no errors, got %q", errOut.String())
+	}
+	found := false
+	for _, name := range driver.Registered() {
+		found = found || name == "test-plugin-store"
+	}
+	if !found {
+		t.Errorf("expected the storage driver to be registered, got %v", driver.Registered())
+	}
+
+	// Another plugin may not replace the driver.
+	other := *plug
+	other.Dir = "testdata/helmhome/helm/plugins/other"
+	registerStorageDrivers(&other, &errOut)
+	if expect := `failed to register storage driver of plugin "store": driver "test-plugin-store" is already registered`; !strings.Contains(errOut.String(), expect) {
+		t.Errorf("expected %q, got %q", expect, errOut.String())
+	}
+}
+
+func TestRegisterKMSProviders(t *testing.T) {
+	plug := &plugin.Plugin{
+		Dir: "testdata/helmhome/helm/plugins/kms",
+		Metadata: &plugin.Metadata{
+			Name:         "kms",
+			KMSProviders: []plugin.KMSProvider{{Name: "test-plugin-kms", Command: "kms.sh"}},
+		},
+	}
+
+	var errOut bytes.Buffer
+	registerKMSProviders(plug, &errOut)
+	registerKMSProviders(plug, &errOut)
+	if errOut.Len() != 0 {
+		t.Errorf("expected no errors, got %q", errOut.String())
+	}
+
+	other := *plug
+	other.Dir = "testdata/helmhome/helm/plugins/other"
+	registerKMSProviders(&other, &errOut)
+	if expect := `failed to register KMS provider of plugin "kms": KMS provider "test-plugin-kms" is already registered`; !strings.Contains(errOut.String(), expect) {
+		t.Errorf("expected %q, got %q", expect, errOut.String())
+	}
+}
+
 func TestLoadPluginsWithSpace(t *testing.T) {
 	settings.PluginsDirectory = "testdata/helm home with space/helm/plugins"
 	settings.RepositoryConfig = "testdata/helm home with space/helm/repositories.yaml"
@@ -0,0 +1,148 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+)
+
+const recoverDesc = `
+This command recovers a release stuck in a pending state, e.g. after helm was
+interrupted during an install, upgrade or rollback.
+
+It compares the resources of the pending revision with the live cluster and
+recovers the release with one of the following strategies:
+
+- roll-forward: apply the pending revision and mark it as deployed
+- restore: mark the pending revision as failed and roll back to the last
+  deployed revision
+- mark-failed: mark the pending revision as failed without changing the cluster
+
+By default, the strategy is chosen automatically: roll-forward if all resources
+of the pending revision match the cluster, otherwise restore if there is a
+deployed revision, otherwise mark-failed. Use '--dry-run' to only print the
+report.
+`
+
+func newRecoverCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewRecover(cfg)
+	var outfmt output.Format
+	var strategy string
+
+	cmd := &cobra.Command{
+		Use:   "recover RELEASE_NAME",
+		Short: "recover a release stuck in a pending state",
+		Long:  recoverDesc,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return noMoreArgsComp()
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			client.Strategy = action.RecoverStrategy(strategy)
+			report, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			return outfmt.Write(out, &recoverPrinter{report})
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&strategy, "strategy", string(action.RecoverAuto), fmt.Sprintf("strategy used to recover the release. Allowed values: %s", strings.Join(recoverStrategies(), ", ")))
+	f.BoolVar(&client.DryRun, "dry-run", false, "only report the state of the pending revision and the strategy that would be used")
+	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
+	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
+	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.DurationVar(&client.LockTimeout, "lock-timeout", 0, "time to wait for the lock of the release held by another client. By default, fail if the release is locked")
+	bindOutputFlag(cmd, &outfmt)
+	bindOutputEventsFlag(cmd, cfg)
+
+	err := cmd.RegisterFlagCompletionFunc("strategy", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return recoverStrategies(), cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return cmd
+}
+
+func recoverStrategies() []string {
+	var strategies []string
+	for _, s := range action.RecoverStrategies {
+		strategies = append(strategies, string(s))
+	}
+	return strategies
+}
+
+type recoverPrinter struct {
+	report *action.RecoverReport
+}
+
+func (p recoverPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.report)
+}
+
+func (p recoverPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.report)
+}
+
+func (p recoverPrinter) WriteTable(out io.Writer) error {
+	r := p.report
+	_, _ = fmt.Fprintf(out, "NAME: %s\n", r.Release)
+	_, _ = fmt.Fprintf(out, "NAMESPACE: %s\n", r.Namespace)
+	_, _ = fmt.Fprintf(out, "REVISION: %d\n", r.Revision)
+	_, _ = fmt.Fprintf(out, "STATUS: %s\n", r.Status)
+	if r.LastDeployed > 0 {
+		_, _ = fmt.Fprintf(out, "LAST DEPLOYED REVISION: %d\n", r.LastDeployed)
+	} else {
+		_, _ = fmt.Fprintln(out, "LAST DEPLOYED REVISION: none")
+	}
+	_, _ = fmt.Fprintln(out, "RESOURCES:")
+	table := uitable.New()
+	table.AddRow("KIND", "NAMESPACE", "NAME", "STATE")
+	for _, res := range r.Resources {
+		state := string(res.State)
+		if len(res.Fields) > 0 {
+			state += " (" + strings.Join(res.Fields, ", ") + ")"
+		}
+		table.AddRow(res.Kind, res.Namespace, res.Name, state)
+	}
+	_, _ = fmt.Fprintln(out, table)
+	_, _ = fmt.Fprintf(out, "STRATEGY: %s\n", r.Strategy)
+	_, _ = fmt.Fprintf(out, "REASON: %s\n", r.Reason)
+	if r.Recovered {
+		_, _ = fmt.Fprintf(out, "\nRelease %q has been recovered.\n", r.Release)
+	} else {
+		_, _ = fmt.Fprintln(out, "\nDry run: run again without --dry-run to recover the release.")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestRecoverCmd(t *testing.T) {
+	rels := func() []*release.Release {
+		return []*release.Release{
+			release.Mock(&release.MockReleaseOptions{Name: "stuck", Version: 1, Status: release.StatusDeployed}),
+			release.Mock(&release.MockReleaseOptions{Name: "stuck", Version: 2, Status: release.StatusPendingUpgrade}),
+		}
+	}
+
+	tests := []cmdTestCase{{
+		name:   "recover a pending release with dry-run",
+		cmd:    "recover stuck --dry-run",
+		golden: "output/recover-dry-run.txt",
+		rels:   rels(),
+	}, {
+		name:   "recover a pending release",
+		cmd:    "recover stuck --strategy mark-failed",
+		golden: "output/recover-mark-failed.txt",
+		rels:   rels(),
+	}, {
+		name:   "recover a pending release with json output",
+		cmd:    "recover stuck --dry-run -o json",
+		golden: "output/recover-dry-run.json",
+		rels:   rels(),
+	}, {
+		name:      "recover a release that is not pending",
+		cmd:       "recover calm",
+		golden:    "output/recover-not-pending.txt",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "calm"})},
+		wantError: true,
+	}, {
+		name:      "recover with an invalid strategy",
+		cmd:       "recover stuck --strategy yolo",
+		golden:    "output/recover-invalid-strategy.txt",
+		rels:      rels(),
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestRecoverCompletion(t *testing.T) {
+	checkReleaseCompletion(t, "recover", false)
+}
+
+func TestRecoverFileCompletion(t *testing.T) {
+	checkFileCompletion(t, "recover", false)
+	checkFileCompletion(t, "recover myrelease", false)
+}
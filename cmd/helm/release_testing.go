@@ -17,17 +17,22 @@ limitations under the License.
 package main
 
 import (
+	"encoding/xml"
 	"fmt"
 	"io"
+	"log"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/release"
 )
 
 const releaseTestHelp = `
@@ -35,11 +40,25 @@ The test command runs the tests for a release.
 
 The argument this command takes is the name of a deployed release.
 The tests to be run are defined in the chart that was installed.
+
+Hooks with the 'pre-test' and 'post-test' events run before and after the
+tests. They are always run, whatever the filters, and post-test hooks are
+skipped if a test fails.
+
+Tests can be selected by name, with shell patterns like 'name=db-*', and by the
+labels of their manifest with '--selector'. Tests with the same weight run at
+the same time, up to '--parallelism' of them.
+
+With '--output json', '--output yaml' or '--output junit', the results of the
+selected tests are printed, with the end of their logs.
 `
 
+// junitFormat is the --output format of test results for CI systems.
+const junitFormat = "junit"
+
 func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewReleaseTesting(cfg)
-	var outfmt = output.Table
+	var format string
 	var outputLogs bool
 	var filter []string
 
@@ -55,6 +74,11 @@ func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
+			if format != junitFormat {
+				if _, err := output.ParseFormat(format); err != nil {
+					return errors.Errorf("invalid format type %q. Allowed values: %s", format, strings.Join(testOutputFormats(), ", "))
+				}
+			}
 			client.Namespace = settings.Namespace()
 			notName := regexp.MustCompile(`^!\s?name=`)
 			for _, f := range filter {
@@ -72,7 +96,22 @@ func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 				return runErr
 			}
 
-			if err := outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, client.HideNotes}); err != nil {
+			if format != output.Table.String() {
+				result, err := client.Result(rel)
+				if err != nil {
+					return err
+				}
+				if format == junitFormat {
+					if err := writeJUnit(out, result); err != nil {
+						return err
+					}
+				} else if err := output.Format(format).Write(out, &testResultPrinter{result}); err != nil {
+					return err
+				}
+				return runErr
+			}
+
+			if err := output.Table.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, client.HideNotes, false}); err != nil {
 				return err
 			}
 
@@ -90,9 +129,150 @@ func newReleaseTestCmd(cfg *action.Configuration, out io.Writer) *cobra.Command
 
 	f := cmd.Flags()
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
-	f.BoolVar(&outputLogs, "logs", false, "dump the logs from test pods (this runs after all tests are complete, but before any cleanup)")
-	f.StringSliceVar(&filter, "filter", []string{}, "specify tests by attribute (currently \"name\") using attribute=value syntax or '!attribute=value' to exclude a test (can specify multiple or separate values with commas: name=test1,name=test2)")
+	f.BoolVar(&outputLogs, "logs", false, "dump the logs from test pods (this runs after all tests are complete, but before any cleanup). Only used with the table output")
+	f.StringSliceVar(&filter, "filter", []string{}, "specify tests by attribute (currently \"name\") using attribute=value syntax or '!attribute=value' to exclude a test (can specify multiple or separate values with commas: name=test1,name=test2). Names may be shell patterns, e.g. name=db-*")
+	f.StringVarP(&client.Selector, "selector", "l", "", "select the tests to run by the labels of their manifest (e.g. -l suite=smoke,tier!=slow)")
+	f.IntVar(&client.Parallelism, "parallelism", 1, "maximum number of tests with the same weight to run at the same time")
 	f.BoolVar(&client.HideNotes, "hide-notes", false, "if set, do not show notes in test output. Does not affect presence in chart metadata")
+	f.StringVarP(&format, outputFlag, "o", output.Table.String(), fmt.Sprintf("prints the output in the specified format. Allowed values: %s", strings.Join(testOutputFormats(), ", ")))
+	err := cmd.RegisterFlagCompletionFunc(outputFlag, func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return testOutputFormats(), cobra.ShellCompDirectiveNoFileComp
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	return cmd
 }
+
+func testOutputFormats() []string {
+	return append(output.Formats(), junitFormat)
+}
+
+type testResultPrinter struct {
+	result *action.TestSuiteResult
+}
+
+func (p testResultPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.result)
+}
+
+func (p testResultPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.result)
+}
+
+func (p testResultPrinter) WriteTable(out io.Writer) error {
+	return output.ErrInvalidFormatType
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+	SystemOut *junitOutput  `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitOutput struct {
+	Text string `xml:",cdata"`
+}
+
+// writeJUnit writes test results as a JUnit XML report. The tests of the
+// release are a test suite named after the release.
+func writeJUnit(out io.Writer, result *action.TestSuiteResult) error {
+	suite := junitTestSuite{
+		Name:     result.Release,
+		Tests:    len(result.Tests),
+		Failures: result.Failed(),
+		Skipped:  result.Skipped(),
+	}
+	var total float64
+	for _, t := range result.Tests {
+		total += t.Duration
+		if suite.Timestamp == "" && !t.StartedAt.IsZero() {
+			suite.Timestamp = t.StartedAt.UTC().Format(time.RFC3339)
+		}
+		c := junitTestCase{
+			Name:      t.Name,
+			ClassName: result.Namespace + "." + result.Release,
+			Time:      junitTime(t.Duration),
+			SystemOut: junitLogs(t.Logs),
+		}
+		switch t.Status {
+		case action.TestFailed, action.TestUnknown:
+			message := t.Reason
+			if message == "" {
+				message = fmt.Sprintf("test %s", t.Status)
+			}
+			c.Failure = &junitFailure{Message: message}
+		case action.TestSkipped:
+			c.Skipped = &struct{}{}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Time = junitTime(total)
+
+	report := junitTestSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintln(out)
+	return err
+}
+
+func junitTime(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 3, 64)
+}
+
+func junitLogs(logs []release.HookLog) *junitOutput {
+	if len(logs) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	for _, l := range logs {
+		truncated := ""
+		if l.Truncated {
+			truncated = " (truncated)"
+		}
+		fmt.Fprintf(&b, "==> pod %s, container %s%s\n%s", l.Pod, l.Container, truncated, l.Log)
+		if !strings.HasSuffix(l.Log, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return &junitOutput{Text: b.String()}
+}
@@ -17,9 +17,77 @@ limitations under the License.
 package main
 
 import (
+	"bytes"
 	"testing"
+	"time"
+
+	"helm.sh/helm/v3/internal/test"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
 )
 
+func TestReleaseTesting(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "test with an invalid output format",
+		cmd:       "test aeneas -o xml",
+		golden:    "output/test-invalid-format.txt",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+		wantError: true,
+	}, {
+		name:      "test with an invalid selector",
+		cmd:       "test aeneas -l 'a=b=c'",
+		golden:    "output/test-invalid-selector.txt",
+		rels:      []*release.Release{release.Mock(&release.MockReleaseOptions{Name: "aeneas"})},
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func testSuiteResult() *action.TestSuiteResult {
+	start := helmtime.Unix(1452902400, 0).UTC()
+	return &action.TestSuiteResult{
+		Release:   "aeneas",
+		Namespace: "default",
+		Revision:  2,
+		Tests: []*action.TestResult{{
+			Name:        "connection",
+			Status:      action.TestPassed,
+			StartedAt:   start,
+			CompletedAt: start.Add(1500 * time.Millisecond),
+			Duration:    1.5,
+			Logs:        []release.HookLog{{Pod: "connection", Container: "curl", Log: "connected\n"}},
+		}, {
+			Name:        "queries",
+			Status:      action.TestFailed,
+			StartedAt:   start.Add(2 * time.Second),
+			CompletedAt: start.Add(5 * time.Second),
+			Duration:    3,
+			Reason:      "pod queries failed",
+			Logs:        []release.HookLog{{Pod: "queries", Container: "psql", Log: "ERROR: relation \"users\" does not exist", Truncated: true}},
+		}, {
+			Name:   "load",
+			Status: action.TestSkipped,
+		}},
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeJUnit(&out, testSuiteResult()); err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, out.String(), "output/test-junit.xml")
+}
+
+func TestTestResultPrinter(t *testing.T) {
+	var out bytes.Buffer
+	if err := (testResultPrinter{testSuiteResult()}).WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, out.String(), "output/test-results.json")
+}
+
 func TestReleaseTestingCompletion(t *testing.T) {
 	checkReleaseCompletion(t, "test", false)
 }
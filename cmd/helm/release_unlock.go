@@ -0,0 +1,64 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const releaseUnlockHelp = `
+This command removes the lock of a release.
+
+Install, upgrade, rollback and uninstall lock the release they modify, so
+concurrent runs on the same release wait for each other (see '--lock-timeout')
+instead of leaving it pending. The lock of a client which was killed expires
+after a minute. Use this command to remove it right away.
+
+Only unlock a release if no other client is modifying it.
+`
+
+func newReleaseUnlockCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewUnlock(cfg)
+
+	cmd := &cobra.Command{
+		Use:   "unlock RELEASE_NAME",
+		Short: "remove the lock of a release",
+		Long:  releaseUnlockHelp,
+		Args:  require.ExactArgs(1),
+		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) != 0 {
+				return noMoreArgsComp()
+			}
+			return compListReleases(toComplete, args, cfg)
+		},
+		RunE: func(_ *cobra.Command, args []string) error {
+			holder, err := client.Run(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "release %q unlocked, it was locked by %q\n", args[0], holder)
+			return nil
+		},
+	}
+	return cmd
+}
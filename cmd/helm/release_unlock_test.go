@@ -0,0 +1,40 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+)
+
+func TestReleaseUnlockCmd(t *testing.T) {
+	tests := []cmdTestCase{{
+		name:      "unlock without locking configured",
+		cmd:       "release unlock thomas-guide",
+		golden:    "output/release-unlock-not-configured.txt",
+		wantError: true,
+	}, {
+		name:      "unlock without args",
+		cmd:       "release unlock",
+		golden:    "output/release-unlock-no-args.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestReleaseUnlockCompletion(t *testing.T) {
+	checkReleaseCompletion(t, "release unlock", false)
+}
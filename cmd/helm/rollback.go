@@ -26,6 +26,8 @@ import (
 
 	"helm.sh/helm/v3/cmd/helm/require"
 	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
 )
 
 const rollbackDesc = `
@@ -36,10 +38,25 @@ second is a revision (version) number. If this argument is omitted or set to
 0, it will roll back to the previous release.
 
 To see revision numbers, run 'helm history RELEASE'.
+
+The '--set' and '-f' flags override values on top of the values of the target
+revision. The chart stored with that revision is then rendered again with the
+merged values. This is not supported for charts with dependencies, since
+subcharts are not stored with a release.
+
+To roll back only some resources, for example a single component that
+regressed, pass them as kind/name with '--resource'. The other resources,
+the values and the hooks of the release keep their current state:
+
+    $ helm rollback myrelease 3 --resource Deployment/web
+
+Use '--diff' to preview the changes of the rollback without making them.
 `
 
 func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewRollback(cfg)
+	valueOpts := &values.Options{}
+	var diff bool
 
 	cmd := &cobra.Command{
 		Use:   "rollback <RELEASE> [REVISION]",
@@ -66,6 +83,20 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				client.Version = ver
 			}
 
+			vals, err := valueOpts.MergeValues(getter.All(settings))
+			if err != nil {
+				return err
+			}
+			client.Values = vals
+
+			if diff {
+				res, err := client.Diff(args[0])
+				if err != nil {
+					return err
+				}
+				return diffPrinter{res}.WriteTable(out)
+			}
+
 			if err := client.Run(args[0]); err != nil {
 				return err
 			}
@@ -77,14 +108,22 @@ func newRollbackCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 	f := cmd.Flags()
 	f.BoolVar(&client.DryRun, "dry-run", false, "simulate a rollback")
+	f.BoolVar(&diff, "diff", false, "show the changes of the rollback instead of rolling back")
+	f.StringSliceVar(&client.Resources, "resource", nil, "roll back only the given resources, as kind/name (can specify multiple or separate values with commas: Deployment/web,Service/web)")
 	f.BoolVar(&client.Recreate, "recreate-pods", false, "performs pods restart for the resource if applicable")
 	f.BoolVar(&client.Force, "force", false, "force resource update through delete/recreate if needed")
+	f.BoolVar(&client.ServerSideApply, "server-side", false, "update resources with server-side apply instead of client-side patches")
+	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if --server-side is set, take ownership of fields managed by other field managers")
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "prevent hooks from running during rollback")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.DurationVar(&client.LockTimeout, "lock-timeout", 0, "time to wait for the lock of the release held by another client. By default, fail if the release is locked")
+	addHookParallelismFlag(f, cfg)
 	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all Pods, PVCs, Services, and minimum number of Pods of a Deployment, StatefulSet, or ReplicaSet are in a ready state before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.WaitForJobs, "wait-for-jobs", false, "if set and --wait enabled, will wait until all Jobs have been completed before marking the release as successful. It will wait for as long as --timeout")
 	f.BoolVar(&client.CleanupOnFail, "cleanup-on-fail", false, "allow deletion of new resources created in this rollback when rollback fails")
 	f.IntVar(&client.MaxHistory, "history-max", settings.MaxHistory, "limit the maximum number of revisions saved per release. Use 0 for no limit")
+	addValueOptionsFlags(f, valueOpts)
+	bindOutputEventsFlag(cmd, cfg)
 
 	return cmd
 }
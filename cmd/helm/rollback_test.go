@@ -61,6 +61,17 @@ func TestRollbackCmd(t *testing.T) {
 		cmd:    "rollback funny-honey 1 --wait --wait-for-jobs",
 		golden: "output/rollback-wait-for-jobs.txt",
 		rels:   rels,
+	}, {
+		name:   "rollback a release with progress events",
+		cmd:    "rollback funny-honey 1 --wait --output-events json",
+		golden: "output/rollback-events.txt",
+		rels:   rels,
+	}, {
+		name:      "rollback a release with invalid progress events format",
+		cmd:       "rollback funny-honey 1 --output-events yaml",
+		golden:    "output/rollback-events-invalid.txt",
+		rels:      rels,
+		wantError: true,
 	}, {
 		name:   "rollback a release without revision",
 		cmd:    "rollback funny-honey",
@@ -82,6 +93,49 @@ func TestRollbackCmd(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestRollbackDiffCmd(t *testing.T) {
+	configMap := func(name, value string) string {
+		return fmt.Sprintf("---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\ndata:\n  value: %q\n", name, value)
+	}
+	rels := []*release.Release{
+		{
+			Name:      "funny-honey",
+			Namespace: "default",
+			Info:      &release.Info{Status: release.StatusSuperseded},
+			Chart:     &chart.Chart{},
+			Version:   1,
+			Manifest:  configMap("web", "1") + configMap("worker", "1"),
+		},
+		{
+			Name:      "funny-honey",
+			Namespace: "default",
+			Info:      &release.Info{Status: release.StatusDeployed},
+			Chart:     &chart.Chart{},
+			Version:   2,
+			Manifest:  configMap("web", "2") + configMap("worker", "2"),
+		},
+	}
+
+	tests := []cmdTestCase{{
+		name:   "diff a rollback",
+		cmd:    "rollback funny-honey 1 --diff",
+		golden: "output/rollback-diff.txt",
+		rels:   rels,
+	}, {
+		name:   "diff a rollback of a single resource",
+		cmd:    "rollback funny-honey 1 --diff --resource configmap/worker",
+		golden: "output/rollback-diff-resource.txt",
+		rels:   rels,
+	}, {
+		name:      "rollback a resource that does not exist",
+		cmd:       "rollback funny-honey 1 --resource Deployment/web",
+		golden:    "output/rollback-resource-not-found.txt",
+		rels:      rels,
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
+
 func TestRollbackRevisionCompletion(t *testing.T) {
 	mk := func(name string, vers int, status release.Status) *release.Release {
 		return release.Mock(&release.MockReleaseOptions{
@@ -51,8 +51,12 @@ Environment variables:
 | $HELM_CONFIG_HOME                  | set an alternative location for storing Helm configuration.                                                |
 | $HELM_DATA_HOME                    | set an alternative location for storing Helm data.                                                         |
 | $HELM_DEBUG                        | indicate whether or not Helm is running in Debug mode                                                      |
-| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, sql.                                |
+| $HELM_DRIVER                       | set the backend storage driver. Values are: configmap, secret, memory, sql or a plugin storage driver.     |
+| $HELM_DRIVER_ENCRYPTION_KEY        | set comma separated, base64 encoded AES keys encrypting release records. The first key encrypts.           |
+| $HELM_DRIVER_ENCRYPTION_KEY_FILE   | set the path to a file holding the encryption keys, one per line.                                          |
+| $HELM_DRIVER_ENCRYPTION_KMS        | set the name of a plugin KMS provider encrypting release records.                                          |
 | $HELM_DRIVER_SQL_CONNECTION_STRING | set the connection string the SQL storage driver should use.                                               |
+| $HELM_LOCK_HOLDER                  | set the identity recorded in release locks. Defaults to the user, host and process ID.                     |
 | $HELM_MAX_HISTORY                  | set the maximum number of helm release history.                                                            |
 | $HELM_NAMESPACE                    | set the namespace used for the helm operations.                                                            |
 | $HELM_NO_PLUGINS                   | disable plugins. Set HELM_NO_PLUGINS=1 to disable plugins.                                                 |
@@ -173,13 +177,18 @@ func newRootCmd(actionConfig *action.Configuration, out io.Writer, args []string
 		newVerifyCmd(out),
 
 		// release commands
+		newApplyCmd(actionConfig, out),
+		newDiffCmd(actionConfig, out),
 		newGetCmd(actionConfig, out),
 		newHistoryCmd(actionConfig, out),
 		newInstallCmd(actionConfig, out),
 		newListCmd(actionConfig, out),
 		newReleaseTestCmd(actionConfig, out),
+		newRecoverCmd(actionConfig, out),
 		newRollbackCmd(actionConfig, out),
+		newReleaseCmd(actionConfig, out),
 		newStatusCmd(actionConfig, out),
+		newStorageCmd(actionConfig, out),
 		newTemplateCmd(actionConfig, out),
 		newUninstallCmd(actionConfig, out),
 		newUpgradeCmd(actionConfig, out),
@@ -199,7 +208,9 @@ func newRootCmd(actionConfig *action.Configuration, out io.Writer, args []string
 	)
 
 	// Find and add plugins
-	loadPlugins(cmd, out)
+	for _, plug := range loadPlugins(cmd, out) {
+		registerFunctionProviders(actionConfig, plug)
+	}
 
 	// Check for expired repositories
 	checkForExpiredRepos(settings.RepositoryConfig)
@@ -24,6 +24,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"k8s.io/kubectl/pkg/cmd/get"
@@ -45,13 +46,22 @@ The status consists of:
 - revision of the release
 - description of the release (can be completion message or error message, need to enable --show-desc)
 - list of resources that this release consists of (need to enable --show-resources)
+- logs captured from the Pod and Job hooks of the release (need to enable --show-hook-logs)
 - details on last test suite run, if applicable
 - additional notes provided by the chart
+
+With --drift, the resources of the release are compared with the live
+cluster and resources changed, deleted or left behind outside of Helm are
+reported. The command exits with a non-zero status if drift is found. When
+--output is json or yaml, only the drift report is printed.
 `
 
 func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	client := action.NewStatus(cfg)
+	drift := action.NewDrift(cfg)
 	var outfmt output.Format
+	var showDrift bool
+	var showHookLogs bool
 
 	cmd := &cobra.Command{
 		Use:   "status RELEASE_NAME",
@@ -65,6 +75,9 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			return compListReleases(toComplete, args, cfg)
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
+			if showDrift {
+				return runDrift(drift, client.Version, args[0], outfmt, out)
+			}
 
 			// When the output format is a table the resources should be fetched
 			// and displayed as a table. When YAML or JSON the resources will be
@@ -79,8 +92,13 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 			// strip chart metadata from the output
 			rel.Chart = nil
+			if !showHookLogs {
+				for _, h := range rel.Hooks {
+					h.LastRun.Logs = nil
+				}
+			}
 
-			return outfmt.Write(out, &statusPrinter{rel, false, client.ShowDescription, client.ShowResources, false, false})
+			return outfmt.Write(out, &statusPrinter{rel, false, client.ShowDescription, client.ShowResources, false, false, showHookLogs})
 		},
 	}
 
@@ -103,10 +121,62 @@ func newStatusCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.ShowDescription, "show-desc", false, "if set, display the description message of the named release")
 
 	f.BoolVar(&client.ShowResources, "show-resources", false, "if set, display the resources of the named release")
+	f.BoolVar(&showHookLogs, "show-hook-logs", false, "if set, display the logs captured from the hooks of the named release")
+	f.BoolVar(&showDrift, "drift", false, "if set, report resources of the named release that were changed outside of Helm and exit with a non-zero status if there are any")
 
 	return cmd
 }
 
+func runDrift(client *action.Drift, version int, name string, outfmt output.Format, out io.Writer) error {
+	client.Version = version
+	res, err := client.Run(name)
+	if err != nil {
+		return err
+	}
+	if err := outfmt.Write(out, &driftPrinter{res}); err != nil {
+		return err
+	}
+	if res.Drifted() {
+		return errors.Errorf("release %q has drifted from its manifest", name)
+	}
+	return nil
+}
+
+type driftPrinter struct {
+	result *action.DriftResult
+}
+
+func (p driftPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.result)
+}
+
+func (p driftPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.result)
+}
+
+func (p driftPrinter) WriteTable(out io.Writer) error {
+	_, _ = fmt.Fprintf(out, "NAME: %s\n", p.result.Release)
+	_, _ = fmt.Fprintf(out, "NAMESPACE: %s\n", p.result.Namespace)
+	_, _ = fmt.Fprintf(out, "REVISION: %d\n", p.result.Revision)
+	if !p.result.Drifted() {
+		_, _ = fmt.Fprintln(out, "DRIFT: None")
+		return nil
+	}
+	_, _ = fmt.Fprintln(out, "DRIFT:")
+	for _, r := range p.result.Resources {
+		switch r.Drift {
+		case action.DriftModified:
+			_, _ = fmt.Fprintf(out, "%s %q in namespace %q was modified: %s\n", r.Kind, r.Name, r.Namespace, strings.Join(r.Fields, ", "))
+			_, _ = fmt.Fprint(out, r.Diff)
+		case action.DriftDeleted:
+			_, _ = fmt.Fprintf(out, "%s %q in namespace %q was deleted\n", r.Kind, r.Name, r.Namespace)
+		case action.DriftOrphaned:
+			_, _ = fmt.Fprintf(out, "%s %q in namespace %q is owned by the release but not in its manifest\n", r.Kind, r.Name, r.Namespace)
+		}
+	}
+	return nil
+}
+
 type statusPrinter struct {
 	release         *release.Release
 	debug           bool
@@ -114,6 +184,7 @@ type statusPrinter struct {
 	showResources   bool
 	showMetadata    bool
 	hideNotes       bool
+	showHookLogs    bool
 }
 
 func (s statusPrinter) WriteJSON(out io.Writer) error {
@@ -189,6 +260,10 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 		}
 	}
 
+	if s.showHookLogs {
+		writeHookLogs(out, s.release)
+	}
+
 	if s.debug {
 		_, _ = fmt.Fprintln(out, "USER-SUPPLIED VALUES:")
 		err := output.EncodeYAML(out, s.release.Config)
@@ -227,6 +302,35 @@ func (s statusPrinter) WriteTable(out io.Writer) error {
 	return nil
 }
 
+// writeHookLogs writes the logs captured from the hooks of a release.
+func writeHookLogs(out io.Writer, rel *release.Release) {
+	var hooks []*release.Hook
+	for _, h := range rel.Hooks {
+		if len(h.LastRun.Logs) > 0 {
+			hooks = append(hooks, h)
+		}
+	}
+	if len(hooks) == 0 {
+		_, _ = fmt.Fprintln(out, "HOOK LOGS: None")
+		return
+	}
+	_, _ = fmt.Fprintln(out, "HOOK LOGS:")
+	for _, h := range hooks {
+		_, _ = fmt.Fprintf(out, "==> %s %q (%s)\n", h.Kind, h.Name, h.LastRun.Phase)
+		for _, l := range h.LastRun.Logs {
+			truncated := ""
+			if l.Truncated {
+				truncated = " (truncated)"
+			}
+			_, _ = fmt.Fprintf(out, "--- pod %s, container %s%s\n", l.Pod, l.Container, truncated)
+			_, _ = fmt.Fprint(out, l.Log)
+			if l.Log != "" && !strings.HasSuffix(l.Log, "\n") {
+				_, _ = fmt.Fprintln(out)
+			}
+		}
+	}
+}
+
 func executionsByHookEvent(rel *release.Release) map[release.HookEvent][]*release.Hook {
 	result := make(map[release.HookEvent][]*release.Hook)
 	for _, h := range rel.Hooks {
@@ -37,6 +37,32 @@ func TestStatusCmd(t *testing.T) {
 		}}
 	}
 
+	hookLogsRelease := func() []*release.Release {
+		return releasesMockWithStatus(
+			&release.Info{
+				Status: release.StatusDeployed,
+			},
+			&release.Hook{
+				Name:   "migrate",
+				Kind:   "Job",
+				Events: []release.HookEvent{release.HookPreUpgrade},
+				LastRun: release.HookExecution{
+					StartedAt:   mustParseTime("2006-01-02T15:00:05Z"),
+					CompletedAt: mustParseTime("2006-01-02T15:00:07Z"),
+					Phase:       release.HookPhaseFailed,
+					Logs: []release.HookLog{
+						{Pod: "migrate-abcde", Container: "migrate", Log: "...table users exists\nmigration failed", Truncated: true},
+					},
+				},
+			},
+			&release.Hook{
+				Name:   "config",
+				Kind:   "ConfigMap",
+				Events: []release.HookEvent{release.HookPreUpgrade},
+			},
+		)
+	}
+
 	tests := []cmdTestCase{{
 		name:   "get status of a deployed release",
 		cmd:    "status flummoxed-chickadee",
@@ -86,6 +112,20 @@ func TestStatusCmd(t *testing.T) {
 				Status: release.StatusDeployed,
 			},
 		),
+	}, {
+		name:   "get drift of a deployed release",
+		cmd:    "status --drift flummoxed-chickadee",
+		golden: "output/status-drift.txt",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusDeployed,
+		}),
+	}, {
+		name:   "get drift of a deployed release in json",
+		cmd:    "status --drift flummoxed-chickadee -o json",
+		golden: "output/status-drift.json",
+		rels: releasesMockWithStatus(&release.Info{
+			Status: release.StatusDeployed,
+		}),
 	}, {
 		name:   "get status of a deployed release with test suite",
 		cmd:    "status flummoxed-chickadee",
@@ -126,6 +166,21 @@ func TestStatusCmd(t *testing.T) {
 				},
 			},
 		),
+	}, {
+		name:   "get status of a deployed release with hook logs",
+		cmd:    "status --show-hook-logs flummoxed-chickadee",
+		golden: "output/status-with-hook-logs.txt",
+		rels:   hookLogsRelease(),
+	}, {
+		name:   "get status of a deployed release without hook logs in json",
+		cmd:    "status flummoxed-chickadee -o json",
+		golden: "output/status-without-hook-logs.json",
+		rels:   hookLogsRelease(),
+	}, {
+		name:   "get status of a deployed release with hook logs in json",
+		cmd:    "status --show-hook-logs flummoxed-chickadee -o json",
+		golden: "output/status-with-hook-logs.json",
+		rels:   hookLogsRelease(),
 	}}
 	runTestCmd(t, tests)
 }
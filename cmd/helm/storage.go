@@ -0,0 +1,42 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/pkg/action"
+)
+
+const storageHelp = `
+This command consists of multiple subcommands to manage the storage of release records.
+`
+
+func newStorageCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage",
+		Short: "manage the storage of release records",
+		Long:  storageHelp,
+	}
+	cmd.AddCommand(
+		newStorageMigrateCmd(cfg, out),
+		newStorageRekeyCmd(cfg, out),
+	)
+	return cmd
+}
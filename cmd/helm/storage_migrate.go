@@ -0,0 +1,120 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+const storageMigrateHelp = `
+This command copies every revision of every release in the current namespace
+from one storage driver to another, e.g. from Secrets to SQL:
+
+    $ helm storage migrate --from secret --to sql
+
+Custom labels are copied along with the releases. Each copied revision is read
+back and compared with the original. Revisions already present and identical
+in the target storage are skipped, so an interrupted migration can be run
+again.
+
+Use '--delete-source' to delete the records from the source storage once all
+of them were copied and verified. Use '--dry-run' to list the revisions which
+would be migrated.
+`
+
+func newStorageMigrateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewStorageMigrate(cfg)
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:               "migrate",
+		Short:             "copy release records from one storage driver to another",
+		Long:              storageMigrateHelp,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noMoreArgsCompFunc,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if from == "" {
+				from = "secret"
+			}
+			if from == to {
+				return errors.Errorf("source and target storage drivers must differ, both are %q", from)
+			}
+			// The source may be the driver already in use, e.g. the memory
+			// driver holding releases loaded from files.
+			var current driver.Driver
+			if cfg.Releases != nil {
+				current = cfg.Releases.Driver
+			}
+			src, err := newStorageDriver(from, current)
+			if err != nil {
+				return err
+			}
+			dst, err := newStorageDriver(to, nil)
+			if err != nil {
+				return err
+			}
+
+			migrated, err := client.Run(src, dst)
+			verb := "migrated"
+			if client.DryRun {
+				verb = "would be migrated"
+			}
+			for _, rel := range migrated {
+				fmt.Fprintf(out, "revision %d of release %q %s\n", rel.Version, rel.Name, verb)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%d revisions %s from %s to %s storage\n", len(migrated), verb, src.Name(), dst.Name())
+			if client.DeleteSource && !client.DryRun {
+				fmt.Fprintf(out, "%d revisions deleted from %s storage\n", len(migrated), src.Name())
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&from, "from", os.Getenv("HELM_DRIVER"), "storage driver to copy the releases from. Defaults to $HELM_DRIVER")
+	f.StringVar(&to, "to", "", "storage driver to copy the releases to")
+	f.BoolVar(&client.DeleteSource, "delete-source", false, "delete the releases from the source storage once all of them were copied and verified")
+	f.BoolVar(&client.DryRun, "dry-run", false, "list the revisions which would be migrated without copying them")
+	cmd.MarkFlagRequired("to")
+
+	return cmd
+}
+
+// newStorageDriver creates the named storage driver for the current namespace.
+func newStorageDriver(name string, current driver.Driver) (driver.Driver, error) {
+	config := driver.Config{
+		KubernetesClientSet: func() (*kubernetes.Clientset, error) {
+			return kube.New(settings.RESTClientGetter()).Factory.KubernetesClientSet()
+		},
+		Current: current,
+	}
+	return driver.New(name, settings.Namespace(), debug, config)
+}
@@ -0,0 +1,49 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestStorageMigrateCmd(t *testing.T) {
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "thomas-guide", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "thomas-guide", Version: 2}),
+		release.Mock(&release.MockReleaseOptions{Name: "atlas-guide"}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "dry-run lists the revisions to migrate",
+		cmd:    "storage migrate --from memory --to configmap --dry-run",
+		golden: "output/storage-migrate-dry-run.txt",
+		rels:   rels,
+	}, {
+		name:      "source and target must differ",
+		cmd:       "storage migrate --from memory --to memory",
+		golden:    "output/storage-migrate-same.txt",
+		wantError: true,
+	}, {
+		name:      "target is required",
+		cmd:       "storage migrate --from memory",
+		golden:    "output/storage-migrate-no-target.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"helm.sh/helm/v3/cmd/helm/require"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+const storageRekeyHelp = `
+This command re-encrypts every revision of every release in the current
+namespace with the current encryption key.
+
+Release records are encrypted when one of $HELM_DRIVER_ENCRYPTION_KEY,
+$HELM_DRIVER_ENCRYPTION_KEY_FILE or $HELM_DRIVER_ENCRYPTION_KMS is set. To
+rotate a local key, put the new key first and keep the old one after it until
+the history was re-encrypted:
+
+    $ export HELM_DRIVER_ENCRYPTION_KEY=<new key>,<old key>
+    $ helm storage rekey
+
+Records stored before encryption was enabled are encrypted as well. Records
+which cannot be decrypted with the configured keys are reported as errors,
+once the other records were re-encrypted.
+`
+
+func newStorageRekeyCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
+	client := action.NewStorageRekey(cfg)
+
+	cmd := &cobra.Command{
+		Use:               "rekey",
+		Short:             "re-encrypt release records with the current encryption key",
+		Long:              storageRekeyHelp,
+		Args:              require.NoArgs,
+		ValidArgsFunction: noMoreArgsCompFunc,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			kp, err := driver.KeyProviderFromEnv()
+			if err != nil {
+				return err
+			}
+			if kp == nil {
+				return errors.Errorf("no encryption key configured: set $%s, $%s or $%s", driver.EncryptionKeyEnvVar, driver.EncryptionKeyFileEnvVar, driver.EncryptionKMSEnvVar)
+			}
+
+			rekeyed, err := client.Run()
+			for _, rel := range rekeyed {
+				fmt.Fprintf(out, "revision %d of release %q re-encrypted\n", rel.Version, rel.Name)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "%d revisions re-encrypted with key %q\n", len(rekeyed), kp.KeyID())
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,55 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestStorageRekeyCmd(t *testing.T) {
+	t.Setenv(driver.EncryptionKeyEnvVar, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	rels := []*release.Release{
+		release.Mock(&release.MockReleaseOptions{Name: "thomas-guide", Version: 1, Status: release.StatusSuperseded}),
+		release.Mock(&release.MockReleaseOptions{Name: "thomas-guide", Version: 2}),
+	}
+
+	tests := []cmdTestCase{{
+		name:   "rekey all revisions",
+		cmd:    "storage rekey",
+		golden: "output/storage-rekey.txt",
+		rels:   rels,
+	}}
+	runTestCmd(t, tests)
+}
+
+func TestStorageRekeyCmdNoKey(t *testing.T) {
+	t.Setenv(driver.EncryptionKeyEnvVar, "")
+	t.Setenv(driver.EncryptionKeyFileEnvVar, "")
+	t.Setenv(driver.EncryptionKMSEnvVar, "")
+
+	tests := []cmdTestCase{{
+		name:      "rekey requires a key",
+		cmd:       "storage rekey",
+		golden:    "output/storage-rekey-no-key.txt",
+		wantError: true,
+	}}
+	runTestCmd(t, tests)
+}
@@ -26,6 +26,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"helm.sh/helm/v3/pkg/release"
 
@@ -35,6 +36,7 @@ import (
 	"helm.sh/helm/v3/pkg/action"
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/engine"
 	"helm.sh/helm/v3/pkg/releaseutil"
 )
 
@@ -44,6 +46,11 @@ Render chart templates locally and display the output.
 Any values that would normally be looked up or retrieved in-cluster will be
 faked locally. Additionally, none of the server-side testing of chart validity
 (e.g. whether an API is supported) is done.
+
+With '--trace', a report of how each file was rendered is written to standard
+error: the templates included with 'include' and 'tpl', with a summary of
+their arguments, their call depth and the time spent in them, and the values
+each file read. It ends with the values that no template read.
 `
 
 func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
@@ -55,6 +62,7 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	var kubeVersion string
 	var extraAPIs []string
 	var showFiles []string
+	var trace bool
 
 	cmd := &cobra.Command{
 		Use:   "template [NAME] [CHART]",
@@ -64,7 +72,7 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			return compInstall(args, toComplete, client)
 		},
-		RunE: func(_ *cobra.Command, args []string) error {
+		RunE: func(cmd *cobra.Command, args []string) error {
 			if kubeVersion != "" {
 				parsedKubeVersion, err := chartutil.ParseKubeVersion(kubeVersion)
 				if err != nil {
@@ -92,7 +100,13 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			client.ClientOnly = !validate
 			client.APIVersions = chartutil.VersionSet(extraAPIs)
 			client.IncludeCRDs = includeCrds
+			if trace {
+				client.Trace = engine.NewTrace()
+			}
 			rel, err := runInstall(args, client, valueOpts, out)
+			if trace {
+				printTrace(cmd.ErrOrStderr(), client.Trace)
+			}
 
 			if err != nil && !settings.Debug {
 				if rel != nil {
@@ -200,6 +214,8 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.StringVar(&kubeVersion, "kube-version", "", "Kubernetes version used for Capabilities.KubeVersion")
 	f.StringSliceVarP(&extraAPIs, "api-versions", "a", []string{}, "Kubernetes api versions used for Capabilities.APIVersions")
 	f.BoolVar(&client.UseReleaseName, "release-name", false, "use release name in the output-dir path.")
+	f.IntVar(&cfg.RenderParallelism, "render-parallelism", 1, "maximum number of charts, the chart and its subcharts, rendered at the same time. Each chart renders with its own copy of the values")
+	f.BoolVar(&trace, "trace", false, "write a report of the templates included and the values read by each file to standard error")
 	bindPostRenderFlag(cmd, &client.PostRenderer)
 
 	return cmd
@@ -207,7 +223,8 @@ func newTemplateCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 
 func isTestHook(h *release.Hook) bool {
 	for _, e := range h.Events {
-		if e == release.HookTest {
+		switch e {
+		case release.HookTest, release.HookPreTest, release.HookPostTest:
 			return true
 		}
 	}
@@ -260,3 +277,37 @@ func ensureDirectoryForFile(file string) error {
 
 	return os.MkdirAll(baseDir, 0755)
 }
+
+// printTrace writes a report of how the templates were rendered.
+func printTrace(out io.Writer, trace *engine.Trace) {
+	names := make([]string, 0, len(trace.Files))
+	for name := range trace.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file := trace.Files[name]
+		fmt.Fprintf(out, "TRACE %s (%s)\n", name, file.Duration.Round(time.Microsecond))
+		for _, call := range file.Calls {
+			fmt.Fprintf(out, "%s%s %q %s (%s)\n", strings.Repeat("  ", call.Depth), call.Func, call.Name, call.Args, call.Duration.Round(time.Microsecond))
+		}
+		if len(file.Values) > 0 {
+			paths := make([]string, len(file.Values))
+			for i, p := range file.Values {
+				paths[i] = ".Values." + p
+				if p == "" {
+					paths[i] = ".Values"
+				}
+			}
+			fmt.Fprintf(out, "  values: %s\n", strings.Join(paths, ", "))
+		}
+	}
+
+	if len(trace.UnusedValues) > 0 {
+		fmt.Fprintln(out, "UNUSED VALUES")
+		for _, p := range trace.UnusedValues {
+			fmt.Fprintf(out, "  %s\n", p)
+		}
+	}
+}
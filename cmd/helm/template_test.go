@@ -19,6 +19,7 @@ package main
 import (
 	"fmt"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -147,6 +148,11 @@ func TestTemplateCmd(t *testing.T) {
 			cmd:    fmt.Sprintf("template '%s' --set configmap.enabled=true --set subchartb.enabled=true", chartPath),
 			golden: "output/template-subchart-cm.txt",
 		},
+		{
+			name:   "template with subcharts rendered in parallel",
+			cmd:    fmt.Sprintf("template '%s' --set configmap.enabled=true --set subchartb.enabled=true --render-parallelism 4", chartPath),
+			golden: "output/template-subchart-cm.txt",
+		},
 		{
 			// Ensure that user input values take precedence over imported
 			// values from sub-charts.
@@ -165,6 +171,30 @@ func TestTemplateCmd(t *testing.T) {
 	runTestCmd(t, tests)
 }
 
+func TestTemplateTrace(t *testing.T) {
+	_, out, err := executeActionCommand(fmt.Sprintf("template '%s' --trace", chartPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{
+		"TRACE subchart/templates/service.yaml (",
+		"  values: .Values.service.externalPort, .Values.service.internalPort, .Values.service.name, .Values.service.type\n",
+		"TRACE subchart/templates/subdir/configmap.yaml (",
+		"  values: .Values.configmap.enabled\n",
+		"UNUSED VALUES\n",
+		// The config map is disabled, so its value is not read.
+		"  configmap.value\n",
+	} {
+		if !strings.Contains(out, expect) {
+			t.Errorf("expected the trace to contain %q, got:\n%s", expect, out)
+		}
+	}
+	// The manifests are rendered as without tracing.
+	if !strings.Contains(out, "# Source: subchart/templates/service.yaml\n") {
+		t.Errorf("expected the manifests, got:\n%s", out)
+	}
+}
+
 func TestTemplateVersionCompletion(t *testing.T) {
 	repoFile := "testdata/helmhome/helm/repositories.yaml"
 	repoCache := "testdata/helmhome/helm/repository"
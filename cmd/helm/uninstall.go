@@ -79,7 +79,10 @@ func newUninstallCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.Wait, "wait", false, "if set, will wait until all the resources are deleted before returning. It will wait for as long as --timeout")
 	f.StringVar(&client.DeletionPropagation, "cascade", "background", "Must be \"background\", \"orphan\", or \"foreground\". Selects the deletion cascading strategy for the dependents. Defaults to background.")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.DurationVar(&client.LockTimeout, "lock-timeout", 0, "time to wait for the lock of the release held by another client. By default, fail if the release is locked")
+	addHookParallelismFlag(f, cfg)
 	f.StringVar(&client.Description, "description", "", "add a custom description")
+	bindOutputEventsFlag(cmd, cfg)
 
 	return cmd
 }
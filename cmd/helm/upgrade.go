@@ -23,6 +23,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -74,6 +75,29 @@ or '--set' flags. Priority is given to new values.
 
     $ helm upgrade --reuse-values --set foo=bar --set foo=newbar redis ./redis
 
+With '--strategy canary', the resources annotated with 'helm.sh/canary: "true"'
+are upgraded first. Once they are ready, have baked for '--canary-bake-time' and
+the '--canary-analysis' command succeeded, the rest of the release is upgraded.
+If any of these steps fails, the release is rolled back. The analysis command
+gets the release in HELM_RELEASE_NAME, HELM_RELEASE_NAMESPACE and
+HELM_RELEASE_REVISION, and the canary resources in HELM_CANARY_RESOURCES:
+
+    $ helm upgrade --strategy canary --canary-bake-time 5m \
+        --canary-analysis ./check-error-rate.sh redis ./redis
+
+With '--plan', the changes the upgrade would make are printed without making
+them: the resources created, patched (with the patch sent to the API server),
+replaced, deleted and adopted, the hooks run and the CRDs changed. A plan saved
+with '-o json' is applied with '--apply-plan', which fails if the release or
+its resources changed since the plan was made:
+
+    $ helm upgrade --plan -o json redis ./redis > plan.json
+    $ helm upgrade --apply-plan plan.json
+
+A plan printed with '-o json' or '-o yaml' holds the patches and the manifest
+applied by the upgrade, including the data of Secrets, which is not hidden. Keep
+saved plans as private as the values of the release.
+
 The --dry-run flag will output all generated chart manifests, including Secrets
 which can contain sensitive values. To hide Kubernetes Secrets use the
 --hide-secret flag. Please carefully consider how and when these flags are used.
@@ -84,12 +108,20 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	valueOpts := &values.Options{}
 	var outfmt output.Format
 	var createNamespace bool
+	var strategy string
+	var plan bool
+	var applyPlan string
 
 	cmd := &cobra.Command{
 		Use:   "upgrade [RELEASE] [CHART]",
 		Short: "upgrade a release",
 		Long:  upgradeDesc,
-		Args:  require.ExactArgs(2),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if applyPlan != "" {
+				return require.MaximumNArgs(1)(cmd, args)
+			}
+			return require.ExactArgs(2)(cmd, args)
+		},
 		ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) == 0 {
 				return compListReleases(toComplete, args, cfg)
@@ -101,6 +133,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		},
 		RunE: func(_ *cobra.Command, args []string) error {
 			client.Namespace = settings.Namespace()
+			client.Strategy = action.UpgradeStrategy(strategy)
 
 			registryClient, err := newRegistryClient(client.CertFile, client.KeyFile, client.CaFile,
 				client.InsecureSkipTLSverify, client.PlainHTTP)
@@ -115,6 +148,9 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 			if client.DryRunOption == "" {
 				client.DryRunOption = "none"
 			}
+			if applyPlan != "" {
+				return runApplyPlan(client, applyPlan, args, outfmt, out)
+			}
 			// Fixes #7002 - Support reading values from STDIN for `upgrade` command
 			// Must load values AFTER determining if we have to call install so that values loaded from stdin are not read twice
 			if client.Install {
@@ -123,6 +159,9 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				histClient.Max = 1
 				versions, err := histClient.Run(args[0])
 				if err == driver.ErrReleaseNotFound || isReleaseUninstalled(versions) {
+					if plan {
+						return errors.Errorf("release %q does not exist, only upgrades can be planned", args[0])
+					}
 					// Only print this to stdout for table output
 					if outfmt == output.Table {
 						fmt.Fprintf(out, "Release %q does not exist. Installing it now.\n", args[0])
@@ -131,11 +170,14 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					instClient.CreateNamespace = createNamespace
 					instClient.ChartPathOptions = client.ChartPathOptions
 					instClient.Force = client.Force
+					instClient.ServerSideApply = client.ServerSideApply
+					instClient.ForceConflicts = client.ForceConflicts
 					instClient.DryRun = client.DryRun
 					instClient.DryRunOption = client.DryRunOption
 					instClient.DisableHooks = client.DisableHooks
 					instClient.SkipCRDs = client.SkipCRDs
 					instClient.Timeout = client.Timeout
+					instClient.LockTimeout = client.LockTimeout
 					instClient.Wait = client.Wait
 					instClient.WaitForJobs = client.WaitForJobs
 					instClient.Devel = client.Devel
@@ -160,7 +202,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 					if err != nil {
 						return err
 					}
-					return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, instClient.HideNotes})
+					return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, instClient.HideNotes, false})
 				} else if err != nil {
 					return err
 				}
@@ -222,6 +264,14 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				warning("This chart is deprecated")
 			}
 
+			if plan {
+				p, err := client.Plan(args[0], ch, vals)
+				if err != nil {
+					return errors.Wrap(err, "PLAN FAILED")
+				}
+				return outfmt.Write(out, &planPrinter{p})
+			}
+
 			// Create context and prepare the handle of SIGTERM
 			ctx := context.Background()
 			ctx, cancel := context.WithCancel(ctx)
@@ -247,7 +297,7 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 				fmt.Fprintf(out, "Release %q has been upgraded. Happy Helming!\n", args[0])
 			}
 
-			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, client.HideNotes})
+			return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, client.HideNotes, false})
 		},
 	}
 
@@ -261,10 +311,14 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.BoolVar(&client.Recreate, "recreate-pods", false, "performs pods restart for the resource if applicable")
 	f.MarkDeprecated("recreate-pods", "functionality will no longer be updated. Consult the documentation for other methods to recreate pods")
 	f.BoolVar(&client.Force, "force", false, "force resource updates through a replacement strategy")
+	f.BoolVar(&client.ServerSideApply, "server-side", false, "update resources with server-side apply instead of client-side patches")
+	f.BoolVar(&client.ForceConflicts, "force-conflicts", false, "if --server-side is set, take ownership of fields managed by other field managers")
 	f.BoolVar(&client.DisableHooks, "no-hooks", false, "disable pre/post upgrade hooks")
 	f.BoolVar(&client.DisableOpenAPIValidation, "disable-openapi-validation", false, "if set, the upgrade process will not validate rendered templates against the Kubernetes OpenAPI Schema")
 	f.BoolVar(&client.SkipCRDs, "skip-crds", false, "if set, no CRDs will be installed when an upgrade is performed with install flag enabled. By default, CRDs are installed if not already present, when an upgrade is performed with install flag enabled")
 	f.DurationVar(&client.Timeout, "timeout", 300*time.Second, "time to wait for any individual Kubernetes operation (like Jobs for hooks)")
+	f.DurationVar(&client.LockTimeout, "lock-timeout", 0, "time to wait for the lock of the release held by another client. By default, fail if the release is locked")
+	addHookParallelismFlag(f, cfg)
 	f.BoolVar(&client.ResetValues, "reset-values", false, "when upgrading, reset the values to the ones built into the chart")
 	f.BoolVar(&client.ReuseValues, "reuse-values", false, "when upgrading, reuse the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' is specified, this is ignored")
 	f.BoolVar(&client.ResetThenReuseValues, "reset-then-reuse-values", false, "when upgrading, reset the values to the ones built into the chart, apply the last release's values and merge in any overrides from the command line via --set and -f. If '--reset-values' or '--reuse-values' is specified, this is ignored")
@@ -280,10 +334,18 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 	f.StringVar(&client.Description, "description", "", "add a custom description")
 	f.BoolVar(&client.DependencyUpdate, "dependency-update", false, "update dependencies if they are missing before installing the chart")
 	f.BoolVar(&client.EnableDNS, "enable-dns", false, "enable DNS lookups when rendering templates")
+	f.BoolVar(&plan, "plan", false, "print the changes the upgrade would make to the cluster without making them. Save the plan with '-o json' to apply it with --apply-plan")
+	f.StringVar(&applyPlan, "apply-plan", "", "upgrade the release as planned in the given file saved by '--plan -o json'. Fails if the release or its resources changed since the plan was made")
+	f.StringVar(&strategy, "strategy", string(action.UpgradeAll), fmt.Sprintf("how to upgrade the resources of the release. Allowed values: %s", strings.Join(upgradeStrategies(), ", ")))
+	f.DurationVar(&client.CanaryBakeTime, "canary-bake-time", 0, "if --strategy=canary is set, time to wait once the canary resources are ready before running the analysis")
+	f.StringVar(&client.CanaryAnalysis, "canary-analysis", "", "if --strategy=canary is set, the path to an executable checking the canary. The upgrade is rolled back if it fails")
+	f.StringArrayVar(&client.CanaryAnalysisArgs, "canary-analysis-args", []string{}, "an argument to the canary analysis executable (can specify multiple)")
 	addChartPathOptionsFlags(f, &client.ChartPathOptions)
 	addValueOptionsFlags(f, valueOpts)
 	bindOutputFlag(cmd, &outfmt)
 	bindPostRenderFlag(cmd, &client.PostRenderer)
+	bindOutputEventsFlag(cmd, cfg)
+	cmd.MarkFlagsMutuallyExclusive("plan", "apply-plan")
 
 	err := cmd.RegisterFlagCompletionFunc("version", func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		if len(args) != 2 {
@@ -296,9 +358,25 @@ func newUpgradeCmd(cfg *action.Configuration, out io.Writer) *cobra.Command {
 		log.Fatal(err)
 	}
 
+	err = cmd.RegisterFlagCompletionFunc("strategy", func(_ *cobra.Command, _ []string, _ string) ([]string, cobra.ShellCompDirective) {
+		return upgradeStrategies(), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	return cmd
 }
 
+func upgradeStrategies() []string {
+	var strategies []string
+	for _, s := range action.UpgradeStrategies {
+		strategies = append(strategies, string(s))
+	}
+	return strategies
+}
+
 func isReleaseUninstalled(versions []*release.Release) bool {
 	return len(versions) > 0 && versions[len(versions)-1].Info.Status == release.StatusUninstalled
 }
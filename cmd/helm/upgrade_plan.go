@@ -0,0 +1,120 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/cli/output"
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// runApplyPlan applies the upgrade plan saved in path.
+func runApplyPlan(client *action.Upgrade, path string, args []string, outfmt output.Format, out io.Writer) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var plan action.UpgradePlan
+	if err := yaml.Unmarshal(data, &plan); err != nil {
+		return errors.Wrapf(err, "unable to parse the upgrade plan %s", path)
+	}
+	if len(args) > 0 && args[0] != plan.Release {
+		return errors.Errorf("the upgrade plan is for release %q, not %q", plan.Release, args[0])
+	}
+	if plan.Namespace != client.Namespace {
+		return errors.Errorf("the upgrade plan is for namespace %q, not %q", plan.Namespace, client.Namespace)
+	}
+
+	rel, err := client.ApplyPlan(context.Background(), &plan)
+	if err != nil {
+		return errors.Wrap(err, "UPGRADE FAILED")
+	}
+	if outfmt == output.Table {
+		fmt.Fprintf(out, "Release %q has been upgraded. Happy Helming!\n", plan.Release)
+	}
+	return outfmt.Write(out, &statusPrinter{rel, settings.Debug, false, false, false, client.HideNotes, false})
+}
+
+type planPrinter struct {
+	plan *action.UpgradePlan
+}
+
+func (p planPrinter) WriteJSON(out io.Writer) error {
+	return output.EncodeJSON(out, p.plan)
+}
+
+func (p planPrinter) WriteYAML(out io.Writer) error {
+	return output.EncodeYAML(out, p.plan)
+}
+
+func (p planPrinter) WriteTable(out io.Writer) error {
+	_, _ = fmt.Fprintf(out, "NAME: %s\n", p.plan.Release)
+	_, _ = fmt.Fprintf(out, "NAMESPACE: %s\n", p.plan.Namespace)
+	_, _ = fmt.Fprintf(out, "REVISION: %d -> %d\n", p.plan.FromRevision, p.plan.Revision)
+
+	counts := make(map[kube.PlanAction]int)
+	table := uitable.New()
+	table.AddRow("ACTION", "KIND", "NAMESPACE", "NAME", "PATCH TYPE", "ADOPTED")
+	for _, r := range p.plan.Resources {
+		counts[r.Action]++
+		if r.Action == kube.PlanNone && !r.Adopted {
+			continue
+		}
+		table.AddRow(r.Action, r.Kind, r.Namespace, r.Name, r.PatchType, strconv.FormatBool(r.Adopted))
+	}
+	if p.plan.Changed() {
+		_, _ = fmt.Fprintln(out, "RESOURCES:")
+		if err := output.EncodeTable(out, table); err != nil {
+			return err
+		}
+	}
+
+	if len(p.plan.Hooks) > 0 {
+		_, _ = fmt.Fprintln(out, "HOOKS:")
+		for _, h := range p.plan.Hooks {
+			_, _ = fmt.Fprintf(out, "%s: %s %q (weight %d)\n", h.Events[0], h.Kind, h.Name, h.Weight)
+		}
+	}
+	if len(p.plan.CRDs) > 0 {
+		_, _ = fmt.Fprintln(out, "CRDS:")
+		for _, c := range p.plan.CRDs {
+			note := "not changed by upgrades"
+			if c.Applied {
+				note = "applied"
+			}
+			_, _ = fmt.Fprintf(out, "%s %s (%s)\n", c.Name, c.Change, note)
+		}
+	}
+
+	var summary []string
+	for _, a := range []kube.PlanAction{kube.PlanCreate, kube.PlanPatch, kube.PlanApply, kube.PlanReplace, kube.PlanDelete} {
+		summary = append(summary, fmt.Sprintf("%d to %s", counts[a], a))
+	}
+	_, _ = fmt.Fprintf(out, "PLAN: %s.\n", strings.Join(summary, ", "))
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"helm.sh/helm/v3/internal/test"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestUpgradePlanCmd(t *testing.T) {
+	releaseName := "funny-bunny-plan"
+	relMock, ch, chartPath := prepareMockRelease(releaseName, t)
+
+	tests := []cmdTestCase{
+		{
+			name:   "plan an upgrade",
+			cmd:    fmt.Sprintf("upgrade %s --plan '%s'", releaseName, chartPath),
+			golden: "output/upgrade-plan.txt",
+			rels:   []*release.Release{relMock(releaseName, 3, ch)},
+		},
+		{
+			name:      "plan the upgrade of a missing release",
+			cmd:       fmt.Sprintf("upgrade %s --install --plan '%s'", releaseName, chartPath),
+			golden:    "output/upgrade-plan-missing-release.txt",
+			wantError: true,
+		},
+		{
+			name:      "plan and apply a plan",
+			cmd:       fmt.Sprintf("upgrade %s --plan --apply-plan plan.json", releaseName),
+			golden:    "output/upgrade-plan-and-apply-plan.txt",
+			wantError: true,
+		},
+	}
+	runTestCmd(t, tests)
+}
+
+func TestUpgradeApplyPlanCmd(t *testing.T) {
+	defer resetEnv()()
+
+	releaseName := "funny-bunny-plan"
+	relMock, ch, chartPath := prepareMockRelease(releaseName, t)
+	store := storageFixture()
+	if err := store.Create(relMock(releaseName, 3, ch)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, plan, err := executeActionCommandC(store, fmt.Sprintf("upgrade %s --plan -o json '%s'", releaseName, chartPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	planPath := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(planPath, []byte(plan), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, out, err := executeActionCommandC(store, fmt.Sprintf("upgrade --apply-plan %s", planPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.AssertGoldenString(t, out, "output/upgrade-apply-plan.txt")
+	if rel, err := store.Get(releaseName, 4); err != nil || rel.Info.Status != release.StatusDeployed {
+		t.Errorf("expected revision 4 to be deployed, got %v, %v", rel, err)
+	}
+
+	_, out, err = executeActionCommandC(store, fmt.Sprintf("upgrade other --apply-plan %s", planPath))
+	if err == nil {
+		t.Error("expected an error applying the plan to another release")
+	}
+	test.AssertGoldenString(t, out, "output/upgrade-apply-plan-other-release.txt")
+
+	_, out, err = executeActionCommandC(store, fmt.Sprintf("upgrade %s --apply-plan %s", releaseName, planPath))
+	if err == nil {
+		t.Error("expected an error applying the plan twice")
+	}
+	test.AssertGoldenString(t, out, "output/upgrade-apply-plan-stale.txt")
+}
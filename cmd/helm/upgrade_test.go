@@ -175,6 +175,13 @@ func TestUpgradeCmd(t *testing.T) {
 			wantError: true,
 			rels:      []*release.Release{relWithStatusMock("funny-bunny", 2, ch, release.StatusPendingInstall)},
 		},
+		{
+			name:      "upgrade a release with an invalid strategy",
+			cmd:       fmt.Sprintf("upgrade funny-bunny --strategy blue-green '%s'", chartPath),
+			golden:    "output/upgrade-with-invalid-strategy.txt",
+			wantError: true,
+			rels:      []*release.Release{relMock("funny-bunny", 2, ch)},
+		},
 		{
 			name:   "install a previously uninstalled release with '--keep-history' using 'upgrade --install'",
 			cmd:    fmt.Sprintf("upgrade funny-bunny -i '%s'", chartPath),
@@ -19,11 +19,11 @@ package action
 import (
 	"bytes"
 	"fmt"
-	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"text/template"
 
 	"github.com/pkg/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -95,6 +95,32 @@ type Configuration struct {
 	Capabilities *chartutil.Capabilities
 
 	Log func(string, ...interface{})
+
+	// EventHandler, if set, is called with the progress events of install,
+	// upgrade, rollback and uninstall actions as they happen.
+	EventHandler func(Event)
+
+	// Locker, if set, locks releases while install, upgrade, rollback and
+	// uninstall actions modify them.
+	Locker ReleaseLocker
+	// LockHolder identifies this client in the locks it holds. The user,
+	// host and process ID are used if it is not set.
+	LockHolder string
+
+	// HookParallelism is the maximum number of hooks with the same weight
+	// that are run at the same time. Hooks are run one at a time if it is
+	// less than 2.
+	HookParallelism int
+
+	// RenderParallelism is the maximum number of charts, the chart and its
+	// subcharts, whose templates are rendered at the same time. Each chart
+	// then renders with its own copy of the values. Charts are rendered one
+	// at a time if it is less than 2.
+	RenderParallelism int
+
+	// CustomTemplateFuncs are template functions made available to the
+	// charts rendered, in addition to the functions built into Helm.
+	CustomTemplateFuncs template.FuncMap
 }
 
 // renderResources renders the templates in a chart
@@ -103,7 +129,7 @@ type Configuration struct {
 // TODO: As part of the refactor the duplicate code in cmd/helm/template.go should be removed
 //
 //	This code has to do with writing files to disk.
-func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Values, releaseName, outputDir string, subNotes, useReleaseName, includeCrds bool, pr postrender.PostRenderer, interactWithRemote, enableDNS, hideSecret bool) ([]*release.Hook, *bytes.Buffer, string, error) {
+func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Values, releaseName, outputDir string, subNotes, useReleaseName, includeCrds bool, pr postrender.PostRenderer, interactWithRemote, enableDNS, hideSecret bool, trace *engine.Trace) ([]*release.Hook, *bytes.Buffer, string, error) {
 	hs := []*release.Hook{}
 	b := bytes.NewBuffer(nil)
 
@@ -118,28 +144,14 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 		}
 	}
 
-	var files map[string]string
-	var err2 error
-
-	// A `helm template` should not talk to the remote cluster. However, commands with the flag
-	//`--dry-run` with the value of `false`, `none`, or `server` should try to interact with the cluster.
-	// It may break in interesting and exotic ways because other data (e.g. discovery) is mocked.
-	if interactWithRemote && cfg.RESTClientGetter != nil {
-		restConfig, err := cfg.RESTClientGetter.ToRESTConfig()
-		if err != nil {
-			return hs, b, "", err
-		}
-		e := engine.New(restConfig)
-		e.EnableDNS = enableDNS
-		files, err2 = e.Render(ch, values)
-	} else {
-		var e engine.Engine
-		e.EnableDNS = enableDNS
-		files, err2 = e.Render(ch, values)
+	e, err := cfg.newEngine(interactWithRemote, enableDNS)
+	if err != nil {
+		return hs, b, "", err
 	}
-
-	if err2 != nil {
-		return hs, b, "", err2
+	e.Trace = trace
+	files, err := e.Render(ch, values)
+	if err != nil {
+		return hs, b, "", err
 	}
 
 	// NOTES.txt gets rendered like all the other files, but because it's not a hook nor a resource,
@@ -178,7 +190,7 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 			}
 			fmt.Fprintf(b, "---\n# Source: %s\n%s\n", name, content)
 		}
-		return hs, b, "", err
+		return hs, b, "", locateManifestError(err, e, ch, values)
 	}
 
 	// Aggregate all valid manifests into one big doc.
@@ -232,6 +244,26 @@ func (cfg *Configuration) renderResources(ch *chart.Chart, values chartutil.Valu
 	return hs, b, notes, nil
 }
 
+// newEngine returns an engine to render charts with the settings of the
+// configuration.
+func (cfg *Configuration) newEngine(interactWithRemote, enableDNS bool) (engine.Engine, error) {
+	// A `helm template` should not talk to the remote cluster. However, commands with the flag
+	//`--dry-run` with the value of `false`, `none`, or `server` should try to interact with the cluster.
+	// It may break in interesting and exotic ways because other data (e.g. discovery) is mocked.
+	var e engine.Engine
+	if interactWithRemote && cfg.RESTClientGetter != nil {
+		restConfig, err := cfg.RESTClientGetter.ToRESTConfig()
+		if err != nil {
+			return e, err
+		}
+		e = engine.New(restConfig)
+	}
+	e.EnableDNS = enableDNS
+	e.Parallelism = cfg.RenderParallelism
+	e.CustomTemplateFuncs = cfg.CustomTemplateFuncs
+	return e, nil
+}
+
 // RESTClientGetter gets the rest client
 type RESTClientGetter interface {
 	ToRESTConfig() (*rest.Config, error)
@@ -374,53 +406,25 @@ func (cfg *Configuration) Init(getter genericclioptions.RESTClientGetter, namesp
 	kc := kube.New(getter)
 	kc.Log = log
 
-	lazyClient := &lazyClient{
-		namespace: namespace,
-		clientFn:  kc.Factory.KubernetesClientSet,
+	config := driver.Config{KubernetesClientSet: kc.Factory.KubernetesClientSet}
+	if cfg.Releases != nil {
+		config.Current = cfg.Releases.Driver
 	}
-
-	var store *storage.Storage
-	switch helmDriver {
-	case "secret", "secrets", "":
-		d := driver.NewSecrets(newSecretClient(lazyClient))
-		d.Log = log
-		store = storage.Init(d)
-	case "configmap", "configmaps":
-		d := driver.NewConfigMaps(newConfigMapClient(lazyClient))
-		d.Log = log
-		store = storage.Init(d)
-	case "memory":
-		var d *driver.Memory
-		if cfg.Releases != nil {
-			if mem, ok := cfg.Releases.Driver.(*driver.Memory); ok {
-				// This function can be called more than once (e.g., helm list --all-namespaces).
-				// If a memory driver was already initialized, re-use it but set the possibly new namespace.
-				// We re-use it in case some releases where already created in the existing memory driver.
-				d = mem
-			}
-		}
-		if d == nil {
-			d = driver.NewMemory()
-		}
-		d.SetNamespace(namespace)
-		store = storage.Init(d)
-	case "sql":
-		d, err := driver.NewSQL(
-			os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING"),
-			log,
-			namespace,
-		)
-		if err != nil {
-			return errors.Wrap(err, "unable to instantiate SQL driver")
-		}
-		store = storage.Init(d)
-	default:
-		return errors.Errorf("unknown driver %q", helmDriver)
+	d, err := driver.New(helmDriver, namespace, log, config)
+	if err != nil {
+		return err
 	}
+	store := storage.Init(d)
+
+	locker := kube.NewLeaseLocker(func() (kubernetes.Interface, error) {
+		return kc.Factory.KubernetesClientSet()
+	}, namespace)
+	locker.Log = log
 
 	cfg.RESTClientGetter = getter
 	cfg.KubeClient = kc
 	cfg.Releases = store
+	cfg.Locker = locker
 	cfg.Log = log
 
 	return nil
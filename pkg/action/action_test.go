@@ -66,6 +66,15 @@ metadata:
 data:
   name: value`
 
+var manifestWithFailureHook = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: notify
+  annotations:
+    "helm.sh/hook": on-failure
+    "helm.sh/hook-delete-policy": hook-failed
+`
+
 var manifestWithTestHook = `kind: Pod
   metadata:
 	name: finding-nemo,
@@ -227,12 +236,39 @@ func withMultipleManifestTemplate() chartOption {
 	}
 }
 
+func withFailureHook() chartOption {
+	return func(opts *chartOptions) {
+		opts.Templates = append(opts.Templates, &chart.File{Name: "templates/notify", Data: []byte(manifestWithFailureHook)})
+	}
+}
+
 func withKube(version string) chartOption {
 	return func(opts *chartOptions) {
 		opts.Metadata.KubeVersion = version
 	}
 }
 
+// recordPhases records the phases started by the actions run with config.
+func recordPhases(config *Configuration) *[]string {
+	var phases []string
+	config.EventHandler = func(e Event) {
+		if e.Type == EventPhaseStarted {
+			phases = append(phases, e.Phase)
+		}
+	}
+	return &phases
+}
+
+// findHook returns the hook of rel with the given name.
+func findHook(rel *release.Release, name string) *release.Hook {
+	for _, h := range rel.Hooks {
+		if h.Name == name {
+			return h
+		}
+	}
+	return nil
+}
+
 // releaseStub creates a release stub, complete with the chartStub as its chart.
 func releaseStub() *release.Release {
 	return namedReleaseStub("angry-panda", release.StatusDeployed)
@@ -0,0 +1,474 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/copystructure"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	clivalues "helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// ReleaseSet is a set of releases that are deployed together, e.g. all the
+// releases of an environment.
+type ReleaseSet struct {
+	Releases []*ReleaseSpec `json:"releases"`
+}
+
+// ReleaseSpec describes a release of a ReleaseSet.
+type ReleaseSpec struct {
+	Name string `json:"name"`
+	// Namespace defaults to the namespace of the Apply action.
+	Namespace string `json:"namespace,omitempty"`
+	// Chart is a chart reference as accepted by 'helm install'.
+	Chart   string `json:"chart"`
+	Version string `json:"version,omitempty"`
+	// ValuesFiles are merged in order, and Values are merged on top of them.
+	ValuesFiles []string               `json:"valuesFiles,omitempty"`
+	Values      map[string]interface{} `json:"values,omitempty"`
+	// Needs lists the releases which must be deployed before this one, by
+	// name or by namespace/name.
+	Needs []string `json:"needs,omitempty"`
+}
+
+// LoadReleaseSet reads a ReleaseSet from a file. Values files and local chart
+// paths are relative to the directory of the file.
+func LoadReleaseSet(filename string) (*ReleaseSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	set := &ReleaseSet{}
+	if err := yaml.UnmarshalStrict(data, set); err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %s", filename)
+	}
+
+	dir := filepath.Dir(filename)
+	for _, r := range set.Releases {
+		if r == nil {
+			return nil, errors.Errorf("unable to parse %s: empty release", filename)
+		}
+		if strings.HasPrefix(r.Chart, ".") {
+			r.Chart = filepath.Join(dir, r.Chart)
+		}
+		for i, f := range r.ValuesFiles {
+			if !filepath.IsAbs(f) && !strings.Contains(f, "://") {
+				r.ValuesFiles[i] = filepath.Join(dir, f)
+			}
+		}
+	}
+	return set, nil
+}
+
+// Operations of an ApplyStep.
+const (
+	ApplyInstall = "install"
+	ApplyUpgrade = "upgrade"
+)
+
+// Statuses of an ApplyStep.
+const (
+	ApplyPlanned  = "planned"
+	ApplyDeployed = "deployed"
+	ApplyFailed   = "failed"
+	ApplySkipped  = "skipped"
+)
+
+// ApplyStep is the installation or upgrade of a release of a ReleaseSet.
+type ApplyStep struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Chart     string `json:"chart"`
+	// Needs lists the releases this one depends on, as namespace/name.
+	Needs []string `json:"needs,omitempty"`
+	// Stage is the length of the longest dependency chain leading to the
+	// release. Releases of the same stage don't depend on each other.
+	Stage     int    `json:"stage"`
+	Operation string `json:"operation"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	// Revision is the revision of the deployed release.
+	Revision int `json:"revision,omitempty"`
+
+	spec *ReleaseSpec
+	cfg  *Configuration
+}
+
+// ID returns the namespace/name of the release.
+func (s *ApplyStep) ID() string {
+	return s.Namespace + "/" + s.Name
+}
+
+// ApplyResult holds the steps of an Apply, ordered by stage.
+type ApplyResult struct {
+	Steps []*ApplyStep `json:"steps"`
+}
+
+// Apply is the action for installing or upgrading a set of releases in the
+// order of their dependencies.
+//
+// It provides the implementation of 'helm apply'.
+type Apply struct {
+	cfg *Configuration
+
+	// ConfigurationFor returns a new configuration for a release of the
+	// given namespace. If it is not set, the configuration of the action is
+	// shared by all releases, which must then be of Namespace and are
+	// deployed one at a time.
+	ConfigurationFor func(namespace string) (*Configuration, error)
+	Settings         *cli.EnvSettings // TODO: refactor this out of pkg/action
+
+	Namespace       string
+	Parallelism     int
+	DryRun          bool
+	Wait            bool
+	WaitForJobs     bool
+	Atomic          bool
+	CreateNamespace bool
+	Timeout         time.Duration
+}
+
+// NewApply creates a new Apply object with the given configuration.
+func NewApply(cfg *Configuration) *Apply {
+	return &Apply{
+		cfg:         cfg,
+		Parallelism: 4,
+		Wait:        true,
+	}
+}
+
+// Run installs or upgrades the releases of the set. A release is deployed
+// once all the releases it needs are deployed, and releases that don't depend
+// on each other are deployed in parallel. If a release fails, the releases
+// depending on it are skipped. With DryRun only the plan is returned.
+func (a *Apply) Run(set *ReleaseSet) (*ApplyResult, error) {
+	steps, err := a.plan(set)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range steps {
+		if s.cfg, err = a.configuration(s.Namespace); err != nil {
+			return nil, err
+		}
+		if s.Operation, err = a.operation(s); err != nil {
+			return nil, err
+		}
+	}
+	result := &ApplyResult{Steps: steps}
+	if a.DryRun {
+		return result, nil
+	}
+
+	parallelism := a.Parallelism
+	if parallelism < 1 || a.ConfigurationFor == nil {
+		parallelism = 1
+	}
+	byID := make(map[string]*ApplyStep, len(steps))
+	for _, s := range steps {
+		byID[s.ID()] = s
+	}
+
+	if parallelism == 1 {
+		// Steps are ordered by stage, so the releases a step needs are
+		// deployed before it.
+		for _, s := range steps {
+			if skipped(s, byID) {
+				continue
+			}
+			a.apply(s)
+		}
+	} else {
+		sem := make(chan struct{}, parallelism)
+		done := make(map[string]chan struct{}, len(steps))
+		for _, s := range steps {
+			done[s.ID()] = make(chan struct{})
+		}
+
+		var wg sync.WaitGroup
+		for _, s := range steps {
+			wg.Add(1)
+			go func(s *ApplyStep) {
+				defer wg.Done()
+				defer close(done[s.ID()])
+				for _, need := range s.Needs {
+					<-done[need]
+				}
+				if skipped(s, byID) {
+					return
+				}
+				sem <- struct{}{}
+				defer func() { <-sem }()
+				a.apply(s)
+			}(s)
+		}
+		wg.Wait()
+	}
+
+	var failed []string
+	for _, s := range steps {
+		if s.Status != ApplyDeployed {
+			failed = append(failed, s.ID())
+		}
+	}
+	if len(failed) > 0 {
+		return result, errors.Errorf("%d of %d releases were not deployed: %s", len(failed), len(steps), strings.Join(failed, ", "))
+	}
+	return result, nil
+}
+
+// skipped marks the step as skipped if a release it needs was not deployed.
+func skipped(s *ApplyStep, byID map[string]*ApplyStep) bool {
+	for _, need := range s.Needs {
+		if byID[need].Status != ApplyDeployed {
+			s.Status = ApplySkipped
+			s.Error = fmt.Sprintf("release %s was not deployed", need)
+			return true
+		}
+	}
+	return false
+}
+
+// plan validates the dependencies of the releases and orders them by stage.
+func (a *Apply) plan(set *ReleaseSet) ([]*ApplyStep, error) {
+	steps := make([]*ApplyStep, 0, len(set.Releases))
+	byID := map[string]*ApplyStep{}
+	byName := map[string][]*ApplyStep{}
+	for _, r := range set.Releases {
+		if err := chartutil.ValidateReleaseName(r.Name); err != nil {
+			return nil, errors.Wrapf(err, "invalid release %q", r.Name)
+		}
+		if r.Chart == "" {
+			return nil, errors.Errorf("release %q has no chart", r.Name)
+		}
+		s := &ApplyStep{
+			Name:      r.Name,
+			Namespace: r.Namespace,
+			Chart:     r.Chart,
+			Status:    ApplyPlanned,
+			spec:      r,
+		}
+		if s.Namespace == "" {
+			s.Namespace = a.Namespace
+		}
+		if byID[s.ID()] != nil {
+			return nil, errors.Errorf("release %s is defined more than once", s.ID())
+		}
+		byID[s.ID()] = s
+		byName[s.Name] = append(byName[s.Name], s)
+		steps = append(steps, s)
+	}
+
+	for _, s := range steps {
+		for _, need := range s.spec.Needs {
+			dep, err := resolveNeed(s, need, byID, byName)
+			if err != nil {
+				return nil, err
+			}
+			s.Needs = append(s.Needs, dep.ID())
+		}
+	}
+
+	// Compute the stages with a depth first search, detecting cycles.
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	var visit func(s *ApplyStep, path []string) error
+	visit = func(s *ApplyStep, path []string) error {
+		switch state[s.ID()] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("dependency cycle: %s", strings.Join(append(path, s.ID()), " -> "))
+		}
+		state[s.ID()] = visiting
+		for _, need := range s.Needs {
+			dep := byID[need]
+			if err := visit(dep, append(path, s.ID())); err != nil {
+				return err
+			}
+			if dep.Stage+1 > s.Stage {
+				s.Stage = dep.Stage + 1
+			}
+		}
+		state[s.ID()] = visited
+		return nil
+	}
+	for _, s := range steps {
+		if err := visit(s, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.SliceStable(steps, func(i, j int) bool {
+		return steps[i].Stage < steps[j].Stage
+	})
+	return steps, nil
+}
+
+func resolveNeed(s *ApplyStep, need string, byID map[string]*ApplyStep, byName map[string][]*ApplyStep) (*ApplyStep, error) {
+	if strings.Contains(need, "/") {
+		if dep, ok := byID[need]; ok {
+			return dep, nil
+		}
+		return nil, errors.Errorf("release %s needs unknown release %s", s.ID(), need)
+	}
+	if dep, ok := byID[s.Namespace+"/"+need]; ok {
+		return dep, nil
+	}
+	switch deps := byName[need]; len(deps) {
+	case 0:
+		return nil, errors.Errorf("release %s needs unknown release %s", s.ID(), need)
+	case 1:
+		return deps[0], nil
+	default:
+		return nil, errors.Errorf("release %s needs release %s, which is ambiguous: use namespace/name", s.ID(), need)
+	}
+}
+
+// configuration returns the configuration for a release of a namespace.
+// Releases are deployed concurrently, so each of them gets its own
+// configuration. Without ConfigurationFor, the configuration of the action,
+// which is bound to its namespace, is only used for releases of that
+// namespace.
+func (a *Apply) configuration(namespace string) (*Configuration, error) {
+	if a.ConfigurationFor == nil {
+		if namespace != a.Namespace {
+			return nil, errors.Errorf("unable to configure namespace %q: only releases of namespace %q can be applied", namespace, a.Namespace)
+		}
+		return a.cfg, nil
+	}
+	cfg, err := a.ConfigurationFor(namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to configure namespace %q", namespace)
+	}
+	return cfg, nil
+}
+
+// operation returns whether the release of the step is installed or upgraded.
+func (a *Apply) operation(s *ApplyStep) (string, error) {
+	history := NewHistory(s.cfg)
+	history.Max = 1
+	versions, err := history.Run(s.Name)
+	if errors.Is(err, driver.ErrReleaseNotFound) || isUninstalled(versions) {
+		return ApplyInstall, nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get history of release %s", s.ID())
+	}
+	return ApplyUpgrade, nil
+}
+
+func isUninstalled(versions []*release.Release) bool {
+	return len(versions) > 0 && versions[len(versions)-1].Info.Status == release.StatusUninstalled
+}
+
+// apply installs or upgrades the release of the step and records the outcome.
+func (a *Apply) apply(s *ApplyStep) {
+	rel, err := a.deploy(s)
+	if err != nil {
+		s.Status = ApplyFailed
+		s.Error = err.Error()
+		return
+	}
+	s.Status = ApplyDeployed
+	s.Revision = rel.Version
+}
+
+func (a *Apply) deploy(s *ApplyStep) (*release.Release, error) {
+	cfg := s.cfg
+	chrt, vals, err := a.loadChart(cfg, s.spec)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Log("apply: %s %s", s.Operation, s.ID())
+	if s.Operation == ApplyInstall {
+		install := NewInstall(cfg)
+		install.ReleaseName = s.Name
+		install.Namespace = s.Namespace
+		install.Version = s.spec.Version
+		install.CreateNamespace = a.CreateNamespace
+		install.Wait = a.Wait
+		install.WaitForJobs = a.WaitForJobs
+		install.Atomic = a.Atomic
+		install.Timeout = a.Timeout
+		install.Replace = true
+		return install.Run(chrt, vals)
+	}
+
+	upgrade := NewUpgrade(cfg)
+	upgrade.Namespace = s.Namespace
+	upgrade.Version = s.spec.Version
+	upgrade.Wait = a.Wait
+	upgrade.WaitForJobs = a.WaitForJobs
+	upgrade.Atomic = a.Atomic
+	upgrade.Timeout = a.Timeout
+	return upgrade.Run(s.Name, chrt, vals)
+}
+
+// loadChart locates and loads the chart of a release, and merges its values.
+func (a *Apply) loadChart(cfg *Configuration, spec *ReleaseSpec) (*chart.Chart, map[string]interface{}, error) {
+	settings := a.Settings
+	if settings == nil {
+		settings = cli.New()
+	}
+	cpo := ChartPathOptions{Version: spec.Version, registryClient: cfg.RegistryClient}
+	path, err := cpo.LocateChart(spec.Chart, settings)
+	if err != nil {
+		return nil, nil, err
+	}
+	chrt, err := loader.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req := chrt.Metadata.Dependencies; req != nil {
+		if err := CheckDependencies(chrt, req); err != nil {
+			return nil, nil, errors.Wrapf(err, "chart %s has missing dependencies", spec.Chart)
+		}
+	}
+
+	opts := clivalues.Options{ValueFiles: spec.ValuesFiles}
+	vals, err := opts.MergeValues(getter.All(settings))
+	if err != nil {
+		return nil, nil, err
+	}
+	// The inline values of the spec take precedence over the values files.
+	inline, err := copystructure.Copy(spec.Values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return chrt, chartutil.MergeTables(inline.(map[string]interface{}), vals), nil
+}
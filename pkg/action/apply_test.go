@@ -0,0 +1,249 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+const applyChart = "testdata/charts/decompressedchart"
+
+func applyReleaseSet() *ReleaseSet {
+	return &ReleaseSet{Releases: []*ReleaseSpec{
+		{Name: "app", Chart: applyChart, Needs: []string{"db", "cache"}},
+		{Name: "db", Chart: applyChart, Values: map[string]interface{}{"replicas": 3}},
+		{Name: "cache", Namespace: "caching", Chart: applyChart},
+		{Name: "frontend", Chart: applyChart, Needs: []string{"app"}},
+		{Name: "monitoring", Chart: applyChart},
+	}}
+}
+
+// applyAction returns an Apply action whose releases get their own
+// configuration, backed by a memory driver per namespace. The releases of the
+// "spaced" namespace are stored by the driver of the action.
+func applyAction(t *testing.T) *Apply {
+	config := actionConfigFixture(t)
+	client := NewApply(config)
+	client.Namespace = "spaced"
+
+	// The memory driver holds the namespace it reads from, so it can't be
+	// shared by releases of different namespaces applied in parallel.
+	var mu sync.Mutex
+	drivers := map[string]driver.Driver{"spaced": config.Releases.Driver}
+	client.ConfigurationFor = func(namespace string) (*Configuration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		d, ok := drivers[namespace]
+		if !ok {
+			mem := driver.NewMemory()
+			mem.SetNamespace(namespace)
+			d = mem
+			drivers[namespace] = d
+		}
+		cfg := actionConfigFixture(t)
+		cfg.Releases = storage.Init(d)
+		return cfg, nil
+	}
+	return client
+}
+
+func stepsByID(result *ApplyResult) map[string]*ApplyStep {
+	steps := map[string]*ApplyStep{}
+	for _, s := range result.Steps {
+		steps[s.ID()] = s
+	}
+	return steps
+}
+
+func TestApply_Plan(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	client := applyAction(t)
+	client.DryRun = true
+
+	existing := releaseStub()
+	existing.Name = "db"
+	existing.Namespace = "spaced"
+	req.NoError(client.cfg.Releases.Create(existing))
+
+	result, err := client.Run(applyReleaseSet())
+	req.NoError(err)
+
+	var order []string
+	for _, s := range result.Steps {
+		order = append(order, s.ID())
+	}
+	is.Equal([]string{"spaced/db", "caching/cache", "spaced/monitoring", "spaced/app", "spaced/frontend"}, order)
+
+	steps := stepsByID(result)
+	is.Equal(0, steps["spaced/db"].Stage)
+	is.Equal(1, steps["spaced/app"].Stage)
+	is.Equal(2, steps["spaced/frontend"].Stage)
+	is.Equal([]string{"spaced/db", "caching/cache"}, steps["spaced/app"].Needs)
+	is.Equal(ApplyUpgrade, steps["spaced/db"].Operation)
+	is.Equal(ApplyInstall, steps["spaced/app"].Operation)
+	for _, s := range result.Steps {
+		is.Equal(ApplyPlanned, s.Status)
+	}
+}
+
+func TestApply_PlanErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		releases []*ReleaseSpec
+		err      string
+	}{{
+		name: "cycle",
+		releases: []*ReleaseSpec{
+			{Name: "a", Chart: applyChart, Needs: []string{"c"}},
+			{Name: "b", Chart: applyChart, Needs: []string{"a"}},
+			{Name: "c", Chart: applyChart, Needs: []string{"b"}},
+		},
+		err: "dependency cycle: spaced/a -> spaced/c -> spaced/b -> spaced/a",
+	}, {
+		name:     "unknown",
+		releases: []*ReleaseSpec{{Name: "a", Chart: applyChart, Needs: []string{"b"}}},
+		err:      "release spaced/a needs unknown release b",
+	}, {
+		name: "ambiguous",
+		releases: []*ReleaseSpec{
+			{Name: "a", Chart: applyChart, Needs: []string{"b"}},
+			{Name: "b", Namespace: "one", Chart: applyChart},
+			{Name: "b", Namespace: "two", Chart: applyChart},
+		},
+		err: "release spaced/a needs release b, which is ambiguous: use namespace/name",
+	}, {
+		name: "duplicate",
+		releases: []*ReleaseSpec{
+			{Name: "a", Chart: applyChart},
+			{Name: "a", Namespace: "spaced", Chart: applyChart},
+		},
+		err: "release spaced/a is defined more than once",
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := applyAction(t)
+			client.DryRun = true
+			_, err := client.Run(&ReleaseSet{Releases: tt.releases})
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	client := applyAction(t)
+	existing := releaseStub()
+	existing.Name = "db"
+	existing.Namespace = "spaced"
+	req.NoError(client.cfg.Releases.Create(existing))
+
+	result, err := client.Run(applyReleaseSet())
+	req.NoError(err)
+	for _, s := range result.Steps {
+		is.Equal(ApplyDeployed, s.Status, s.ID())
+	}
+
+	steps := stepsByID(result)
+	is.Equal(2, steps["spaced/db"].Revision)
+	is.Equal(1, steps["spaced/app"].Revision)
+
+	db, err := client.cfg.Releases.Last("db")
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, db.Info.Status)
+	is.Equal(map[string]interface{}{"replicas": 3}, db.Config)
+}
+
+func TestApply_Failure(t *testing.T) {
+	is := assert.New(t)
+
+	client := applyAction(t)
+	set := applyReleaseSet()
+	set.Releases[1].Chart = "./testdata/charts/missing"
+
+	result, err := client.Run(set)
+	is.EqualError(err, "3 of 5 releases were not deployed: spaced/db, spaced/app, spaced/frontend")
+
+	steps := stepsByID(result)
+	is.Equal(ApplyFailed, steps["spaced/db"].Status)
+	is.Contains(steps["spaced/db"].Error, "not found")
+	is.Equal(ApplySkipped, steps["spaced/app"].Status)
+	is.Equal("release spaced/db was not deployed", steps["spaced/app"].Error)
+	is.Equal(ApplySkipped, steps["spaced/frontend"].Status)
+	is.Equal(ApplyDeployed, steps["caching/cache"].Status)
+	is.Equal(ApplyDeployed, steps["spaced/monitoring"].Status)
+}
+
+func TestApply_SharedConfiguration(t *testing.T) {
+	is := assert.New(t)
+
+	// Without ConfigurationFor, releases of other namespaces can't be
+	// applied with the configuration of the action.
+	client := applyAction(t)
+	client.ConfigurationFor = nil
+	_, err := client.Run(applyReleaseSet())
+	is.EqualError(err, `unable to configure namespace "caching": only releases of namespace "spaced" can be applied`)
+
+	set := applyReleaseSet()
+	set.Releases = []*ReleaseSpec{set.Releases[1], set.Releases[4]}
+	result, err := client.Run(set)
+	is.NoError(err)
+	for _, s := range result.Steps {
+		is.Equal(ApplyDeployed, s.Status, s.ID())
+	}
+}
+
+func TestLoadReleaseSet(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "releases.yaml")
+	req.NoError(os.WriteFile(filename, []byte(`releases:
+- name: db
+  chart: ./charts/db
+  valuesFiles: [values/db.yaml, /etc/db.yaml]
+- name: app
+  chart: repo/app
+  version: 1.0.0
+  needs: [db]
+`), 0644))
+
+	set, err := LoadReleaseSet(filename)
+	req.NoError(err)
+	req.Len(set.Releases, 2)
+	is.Equal(filepath.Join(dir, "charts/db"), set.Releases[0].Chart)
+	is.Equal([]string{filepath.Join(dir, "values/db.yaml"), "/etc/db.yaml"}, set.Releases[0].ValuesFiles)
+	is.Equal("repo/app", set.Releases[1].Chart)
+	is.Equal([]string{"db"}, set.Releases[1].Needs)
+
+	req.NoError(os.WriteFile(filename, []byte("releases:\n- name: db\n  chart: db\n  value: {}\n"), 0644))
+	_, err = LoadReleaseSet(filename)
+	is.ErrorContains(err, `unknown field "value"`)
+}
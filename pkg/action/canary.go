@@ -0,0 +1,149 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// UpgradeStrategy is how an upgrade applies the changes to the resources of a
+// release.
+type UpgradeStrategy string
+
+const (
+	// UpgradeAll applies the changes to all the resources at once.
+	UpgradeAll UpgradeStrategy = "all"
+	// UpgradeCanary first applies the changes to the resources annotated with
+	// CanaryAnnotation. Once they are ready, have baked and passed the
+	// analysis, the rest of the resources are upgraded. The release is rolled
+	// back if the upgrade fails.
+	UpgradeCanary UpgradeStrategy = "canary"
+)
+
+// UpgradeStrategies are the supported upgrade strategies.
+var UpgradeStrategies = []UpgradeStrategy{UpgradeAll, UpgradeCanary}
+
+// CanaryAnnotation marks the resources upgraded first by the canary strategy.
+const CanaryAnnotation = "helm.sh/canary"
+
+func validateUpgradeStrategy(strategy UpgradeStrategy) error {
+	if strategy == "" {
+		return nil
+	}
+	for _, s := range UpgradeStrategies {
+		if strategy == s {
+			return nil
+		}
+	}
+	return errors.Errorf("invalid upgrade strategy %q", strategy)
+}
+
+// canaryResources returns the resources annotated with CanaryAnnotation.
+func canaryResources(resources kube.ResourceList) kube.ResourceList {
+	return resources.Filter(func(info *resource.Info) bool {
+		accessor, err := meta.Accessor(info.Object)
+		if err != nil {
+			return false
+		}
+		canary, _ := strconv.ParseBool(accessor.GetAnnotations()[CanaryAnnotation])
+		return canary
+	})
+}
+
+// upgradeCanary applies the changes to the canary resources of the release,
+// waits for them to be ready and bake, and runs the canary analysis. It returns
+// the resources it created. Baking stops early when ctx is done.
+func (u *Upgrade) upgradeCanary(ctx context.Context, rel *release.Release, current, canary kube.ResourceList) (kube.ResourceList, error) {
+	u.cfg.emitPhase(rel, "canary")
+	u.cfg.Log("upgrading %d canary resources of %s", len(canary), rel.Name)
+	results, err := updateResources(u.cfg.KubeClient, current.Intersect(canary), canary, u.Force, u.ServerSideApply, u.ForceConflicts)
+	var created kube.ResourceList
+	if results != nil {
+		created = results.Created
+	}
+	if err != nil {
+		return created, err
+	}
+	u.cfg.emitResult(rel, results)
+
+	if err := u.cfg.waitForResources(rel, canary, u.Timeout, u.WaitForJobs); err != nil {
+		return created, err
+	}
+
+	if u.CanaryBakeTime > 0 {
+		u.cfg.emitPhase(rel, "bake")
+		u.cfg.Log("baking canary resources of %s for %s", rel.Name, u.CanaryBakeTime)
+		select {
+		case <-ctx.Done():
+			return created, context.Cause(ctx)
+		case <-time.After(u.CanaryBakeTime):
+		}
+		// The canary must still be ready once it has baked.
+		if err := u.cfg.waitForResources(rel, canary, u.Timeout, u.WaitForJobs); err != nil {
+			return created, err
+		}
+	}
+
+	if u.CanaryAnalysis != "" {
+		u.cfg.emitPhase(rel, "analysis")
+		if err := u.runCanaryAnalysis(ctx, rel, canary); err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// runCanaryAnalysis runs the canary analysis command. The release and the
+// canary resources are passed in the environment.
+func (u *Upgrade) runCanaryAnalysis(ctx context.Context, rel *release.Release, canary kube.ResourceList) error {
+	var resources []string
+	for _, r := range canary {
+		resources = append(resources, fmt.Sprintf("%s/%s", r.Object.GetObjectKind().GroupVersionKind().Kind, r.Name))
+	}
+
+	if u.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, u.Timeout)
+		defer cancel()
+	}
+	cmd := exec.CommandContext(ctx, u.CanaryAnalysis, u.CanaryAnalysisArgs...)
+	cmd.Env = append(os.Environ(),
+		"HELM_RELEASE_NAME="+rel.Name,
+		"HELM_RELEASE_NAMESPACE="+rel.Namespace,
+		"HELM_RELEASE_REVISION="+strconv.Itoa(rel.Version),
+		"HELM_CANARY_RESOURCES="+strings.Join(resources, ","),
+	)
+	u.cfg.Log("running canary analysis %s", u.CanaryAnalysis)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "canary analysis failed: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func canaryManifest(name string) string {
+	return fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n  annotations:\n    helm.sh/canary: \"true\"\n", name)
+}
+
+// sortedCalls sorts the names of the resources of each call, which are in
+// render order.
+func sortedCalls(calls []string) []string {
+	sorted := make([]string, len(calls))
+	for i, call := range calls {
+		op, names, _ := strings.Cut(call, " ")
+		list := strings.Split(names, ",")
+		sort.Strings(list)
+		sorted[i] = op + " " + strings.Join(list, ",")
+	}
+	return sorted
+}
+
+func canaryUpgrade(t *testing.T) (*Upgrade, *waveKubeClient, *release.Release, *chart.Chart) {
+	config, client := waveConfig(t)
+	upAction := NewUpgrade(config)
+	upAction.Namespace = "spaced"
+	upAction.DisableHooks = true
+	upAction.Strategy = UpgradeCanary
+
+	rel := releaseStub()
+	rel.Name = "canary"
+	rel.Namespace = "spaced"
+	rel.Manifest = "---\n" + waveManifest("app", "") + "---\n" + waveManifest("canary", "")
+	require.NoError(t, config.Releases.Create(rel))
+
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", ""))},
+			{Name: "templates/canary.yaml", Data: []byte(canaryManifest("canary"))},
+		}
+	})
+	return upAction, client, rel, chrt
+}
+
+func TestUpgradeRelease_Canary(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the canary analysis is a shell command")
+	}
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction, client, rel, chrt := canaryUpgrade(t)
+	upAction.CanaryAnalysis = "sh"
+	upAction.CanaryAnalysisArgs = []string{"-c", `test "$HELM_RELEASE_NAME/$HELM_RELEASE_REVISION $HELM_CANARY_RESOURCES" = "canary/2 ConfigMap/canary"`}
+
+	res, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, res.Info.Status)
+	is.Equal([]string{
+		"update canary",
+		"wait canary",
+		"update app,canary",
+	}, sortedCalls(client.calls))
+}
+
+func TestUpgradeRelease_CanaryAnalysisFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the canary analysis is a shell command")
+	}
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction, client, rel, chrt := canaryUpgrade(t)
+	upAction.CanaryAnalysis = "sh"
+	upAction.CanaryAnalysisArgs = []string{"-c", "echo error rate too high; exit 1"}
+
+	_, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "canary failed: canary analysis failed: error rate too high")
+	is.Contains(err.Error(), "has been rolled back due to the canary strategy")
+	// The rest of the resources are not upgraded, and the canary is rolled
+	// back.
+	is.Equal([]string{
+		"update canary",
+		"wait canary",
+		"update app,canary",
+		"wait app,canary",
+	}, sortedCalls(client.calls))
+
+	history, err := upAction.cfg.Releases.History(rel.Name)
+	req.NoError(err)
+	req.Len(history, 3)
+	failed, err := upAction.cfg.Releases.Get(rel.Name, 2)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, failed.Info.Status)
+	last, err := upAction.cfg.Releases.Last(rel.Name)
+	req.NoError(err)
+	is.Equal(3, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+}
+
+func TestUpgradeCanary_BakeCanceled(t *testing.T) {
+	upAction, client, rel, _ := canaryUpgrade(t)
+	upAction.CanaryBakeTime = time.Hour
+
+	current, err := client.Build(strings.NewReader(rel.Manifest), false)
+	require.NoError(t, err)
+	canary, err := client.Build(strings.NewReader(canaryManifest("canary")), false)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = upAction.upgradeCanary(ctx, rel, current, canary)
+	assert.ErrorIs(t, err, context.Canceled)
+	// The canary is not waited for again once baking is canceled.
+	assert.Equal(t, []string{"update canary", "wait canary"}, client.calls)
+}
+
+// applyingKubeClient updates resources like kube.Client.Update: resources
+// that do not exist are created, and the ones that exist must be part of the
+// original resources.
+type applyingKubeClient struct {
+	*waveKubeClient
+}
+
+func (c *applyingKubeClient) Update(original, target kube.ResourceList, _ bool) (*kube.Result, error) {
+	c.record("update", target)
+	res := &kube.Result{}
+	for _, info := range target {
+		key := info.Namespace + "/" + info.Name
+		if _, ok := c.live[key]; !ok {
+			c.live[key] = ""
+			res.Created = append(res.Created, info)
+			continue
+		}
+		if original.Get(info) == nil {
+			return res, errors.Errorf("no %s with the name %q found", info.Mapping.GroupVersionKind.Kind, info.Name)
+		}
+		res.Updated = append(res.Updated, info)
+	}
+	return res, nil
+}
+
+func TestUpgradeRelease_CanaryCreated(t *testing.T) {
+	upAction, client, rel, _ := canaryUpgrade(t)
+	client.live["spaced/app"] = ""
+	client.live["spaced/canary"] = ""
+	upAction.cfg.KubeClient = &applyingKubeClient{waveKubeClient: client}
+
+	deployment := "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: new-canary\n  annotations:\n    helm.sh/canary: \"true\"\n"
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", ""))},
+			{Name: "templates/canary.yaml", Data: []byte(canaryManifest("canary"))},
+			{Name: "templates/new-canary.yaml", Data: []byte(deployment)},
+		}
+	})
+	res, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusDeployed, res.Info.Status)
+	assert.Equal(t, []string{
+		"update canary,new-canary",
+		"wait canary,new-canary",
+		"update app,canary,new-canary",
+	}, sortedCalls(client.calls))
+}
+
+// failingUpgradeKubeClient reports the resources missing from the original
+// release as created, and fails the upgrade after the canary.
+type failingUpgradeKubeClient struct {
+	*waveKubeClient
+	updates int
+}
+
+func (c *failingUpgradeKubeClient) Update(original, target kube.ResourceList, _ bool) (*kube.Result, error) {
+	c.updates++
+	c.record("update", target)
+	if c.updates > 1 {
+		return &kube.Result{}, errors.New("update failed")
+	}
+	return &kube.Result{Created: target.Difference(original), Updated: target.Intersect(original)}, nil
+}
+
+func (c *failingUpgradeKubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.record("delete", resources)
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func TestUpgradeRelease_CanaryCleanupOnFail(t *testing.T) {
+	upAction, client, rel, _ := canaryUpgrade(t)
+	upAction.CleanupOnFail = true
+	upAction.cfg.KubeClient = &failingUpgradeKubeClient{waveKubeClient: client}
+
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", ""))},
+			{Name: "templates/new-canary.yaml", Data: []byte(canaryManifest("new-canary"))},
+		}
+	})
+	_, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "update failed")
+	// The canary created by the failed upgrade is cleaned up.
+	assert.Contains(t, client.calls, "delete new-canary")
+}
+
+func TestUpgradeRelease_CanaryErrors(t *testing.T) {
+	upAction, _, rel, _ := canaryUpgrade(t)
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", ""))},
+		}
+	})
+	_, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	assert.EqualError(t, err, "the canary strategy requires resources annotated with helm.sh/canary")
+
+	upAction.Strategy = "blue-green"
+	_, err = upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	assert.EqualError(t, err, `invalid upgrade strategy "blue-green"`)
+}
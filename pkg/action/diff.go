@@ -0,0 +1,585 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// ChangeType describes how a resource changes between two states.
+type ChangeType string
+
+const (
+	// ChangeAdded indicates the resource does not exist yet and will be created.
+	ChangeAdded ChangeType = "added"
+	// ChangeChanged indicates the resource exists and will be modified.
+	ChangeChanged ChangeType = "changed"
+	// ChangeRemoved indicates the resource exists and will be deleted.
+	ChangeRemoved ChangeType = "removed"
+	// ChangeUnchanged indicates the resource exists and will not be modified.
+	ChangeUnchanged ChangeType = "unchanged"
+)
+
+// ResourceChange is the change of a single resource against one baseline.
+type ResourceChange struct {
+	Change ChangeType `json:"change"`
+	// Diff is a unified diff of the YAML representation of the resource.
+	Diff string `json:"diff,omitempty"`
+}
+
+// ResourceDiff describes the proposed changes to a single resource.
+type ResourceDiff struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	// Manifest is the change relative to the manifest stored with the
+	// currently deployed release.
+	Manifest ResourceChange `json:"manifest"`
+	// Live is the change relative to the object currently in the cluster.
+	Live ResourceChange `json:"live"`
+}
+
+// DiffResult holds the changes an upgrade would make to a release.
+type DiffResult struct {
+	Release   string         `json:"release"`
+	Namespace string         `json:"namespace"`
+	Revision  int            `json:"revision"`
+	Resources []ResourceDiff `json:"resources"`
+}
+
+// Changed returns true if any resource differs from either baseline.
+func (r *DiffResult) Changed() bool {
+	for _, d := range r.Resources {
+		if d.Manifest.Change != ChangeUnchanged || d.Live.Change != ChangeUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff is the action for previewing the changes of an upgrade.
+//
+// It provides the implementation of 'helm diff upgrade'.
+type Diff struct {
+	cfg *Configuration
+
+	ChartPathOptions
+
+	// Devel indicates that the operation is done in devel mode.
+	Devel bool
+	// Namespace is the namespace in which this operation should be performed.
+	Namespace string
+	// ResetValues will reset the values to the chart's built-ins rather than merging with existing.
+	ResetValues bool
+	// ReuseValues will re-use the user's last supplied values.
+	ReuseValues bool
+	// ResetThenReuseValues will reset the values to the chart's built-ins then merge with user's last supplied values.
+	ResetThenReuseValues bool
+	// SkipSchemaValidation determines if JSON schema validation is disabled.
+	SkipSchemaValidation bool
+	// DisableOpenAPIValidation controls whether OpenAPI validation is enforced.
+	DisableOpenAPIValidation bool
+	// EnableDNS enables DNS lookups when rendering templates.
+	EnableDNS bool
+	// PostRenderer is an optional post-renderer applied to the proposed manifest.
+	PostRenderer postrender.PostRenderer
+	// Context is the number of context lines shown around each change.
+	Context int
+}
+
+// NewDiff creates a new Diff object with the given configuration.
+func NewDiff(cfg *Configuration) *Diff {
+	d := &Diff{
+		cfg:     cfg,
+		Context: 3,
+	}
+	d.ChartPathOptions.registryClient = cfg.RegistryClient
+
+	return d
+}
+
+// SetRegistryClient sets the registry client to use when fetching charts.
+func (d *Diff) SetRegistryClient(client *registry.Client) {
+	d.ChartPathOptions.registryClient = client
+}
+
+// Run renders the upgrade of the named release and compares it to the
+// deployed release and to the live cluster state. Nothing is modified.
+func (d *Diff) Run(name string, chart *chart.Chart, vals map[string]interface{}) (*DiffResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	// Render exactly as an upgrade would, but in server dry-run mode so that
+	// lookups work and nothing is recorded.
+	u := NewUpgrade(d.cfg)
+	u.Namespace = d.Namespace
+	u.DryRunOption = "server"
+	u.ResetValues = d.ResetValues
+	u.ReuseValues = d.ReuseValues
+	u.ResetThenReuseValues = d.ResetThenReuseValues
+	u.SkipSchemaValidation = d.SkipSchemaValidation
+	u.DisableOpenAPIValidation = d.DisableOpenAPIValidation
+	u.EnableDNS = d.EnableDNS
+	u.PostRenderer = d.PostRenderer
+
+	d.cfg.Log("preparing diff for %s", name)
+	current, upgraded, err := u.prepareUpgrade(name, chart, vals)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.compare(current, upgraded)
+}
+
+// compare computes the changes from the current release to the upgraded
+// release, relative to both the manifest of the current release and the live
+// cluster state.
+func (d *Diff) compare(current, upgraded *release.Release) (*DiffResult, error) {
+	result := &DiffResult{
+		Release:   upgraded.Name,
+		Namespace: upgraded.Namespace,
+		Revision:  upgraded.Version,
+	}
+
+	namespaced := d.cfg.namespacedKinds()
+	oldDocs, err := manifestsByKey(current.Manifest, current.Namespace, namespaced)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse deployed release manifest")
+	}
+	newDocs, err := manifestsByKey(upgraded.Manifest, upgraded.Namespace, namespaced)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse proposed release manifest")
+	}
+
+	live, err := d.liveChanges(current.Manifest, upgraded.Manifest, upgraded.Name, upgraded.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]manifestDoc)
+	for k, doc := range oldDocs {
+		keys[k] = doc
+	}
+	for k, doc := range newDocs {
+		keys[k] = doc
+	}
+
+	for k, doc := range keys {
+		rd := ResourceDiff{
+			APIVersion: doc.APIVersion,
+			Kind:       doc.Kind,
+			Namespace:  doc.Namespace,
+			Name:       doc.Name,
+		}
+		rd.Manifest = d.change(k, oldDocs[k].Content, newDocs[k].Content)
+		if c, ok := live[k]; ok {
+			rd.Live = c
+		} else {
+			// Without a live view of the object the best baseline is the
+			// stored manifest.
+			rd.Live = rd.Manifest
+		}
+		result.Resources = append(result.Resources, rd)
+	}
+
+	sort.Slice(result.Resources, func(i, j int) bool {
+		a, b := result.Resources[i], result.Resources[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	return result, nil
+}
+
+// liveChanges compares the objects currently in the cluster with the objects
+// an upgrade would leave behind. Resources that cannot be built (for example
+// because the fake client returns none) are simply absent from the result.
+func (d *Diff) liveChanges(currentManifest, targetManifest, name, namespace string) (map[string]ResourceChange, error) {
+	changes := make(map[string]ResourceChange)
+
+	kubeClient, ok := d.cfg.KubeClient.(kube.InterfaceResources)
+	if !ok {
+		return changes, nil
+	}
+
+	original, err := d.cfg.KubeClient.Build(bytes.NewBufferString(currentManifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from current release manifest")
+	}
+	target, err := d.cfg.KubeClient.Build(bytes.NewBufferString(targetManifest), !d.DisableOpenAPIValidation)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
+	}
+	if err := target.Visit(setMetadataVisitor(name, namespace, true)); err != nil {
+		return nil, err
+	}
+
+	all := append(kube.ResourceList{}, target...)
+	all = append(all, original.Difference(target)...)
+	if len(all) == 0 {
+		return changes, nil
+	}
+
+	objs, err := kubeClient.Get(all, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get live objects")
+	}
+	liveObjs := make(map[string]runtime.Object)
+	for _, list := range objs {
+		for _, obj := range list {
+			liveObjs[runtimeObjectKey(obj)] = obj
+		}
+	}
+
+	for _, info := range target {
+		k := infoKey(info)
+		liveObj, exists := liveObjs[k]
+		if !exists {
+			changes[k] = d.change(k, "", objectYAML(info.Object))
+			continue
+		}
+
+		var originalObj runtime.Object = info.Object
+		if o := original.Get(info); o != nil {
+			originalObj = o.Object
+		}
+		merged, err := kube.MergeLive(info, originalObj, liveObj)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to compute changes for %s", resourceString(info))
+		}
+		changes[k] = d.change(k, objectYAML(liveObj), objectYAML(merged))
+	}
+
+	for _, info := range original.Difference(target) {
+		k := infoKey(info)
+		if liveObj, exists := liveObjs[k]; exists {
+			changes[k] = d.change(k, objectYAML(liveObj), "")
+		} else {
+			changes[k] = ResourceChange{Change: ChangeUnchanged}
+		}
+	}
+
+	return changes, nil
+}
+
+func (d *Diff) change(key, from, to string) ResourceChange {
+	var c ChangeType
+	switch {
+	case from == to:
+		return ResourceChange{Change: ChangeUnchanged}
+	case from == "":
+		c = ChangeAdded
+	case to == "":
+		c = ChangeRemoved
+	default:
+		c = ChangeChanged
+	}
+	return ResourceChange{Change: c, Diff: unifiedDiff(d.cfg, key, from, to, d.Context)}
+}
+
+// unifiedDiff returns a unified diff between from and to, labelled with key.
+// The values of Secrets are redacted.
+func unifiedDiff(cfg *Configuration, key, from, to string, context int) string {
+	if strings.HasPrefix(key, "v1/Secret/") {
+		from, to = redactSecret(from, to)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(from),
+		B:        splitLines(to),
+		FromFile: key,
+		ToFile:   key,
+		Context:  context,
+	})
+	if err != nil {
+		// Writing to an in-memory buffer cannot fail, but show the changed
+		// object rather than nothing if it ever does.
+		cfg.Log("unable to compute diff for %s: %s", key, err)
+		return to
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return difflib.SplitLines(strings.TrimSuffix(s, "\n"))
+}
+
+// manifestDoc is a single document of a release manifest.
+type manifestDoc struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+	Content    string
+}
+
+// manifestsByKey splits a release manifest into its documents and indexes
+// them by apiVersion, kind, namespace and name. Like objects built from the
+// manifest, resources of the kinds namespaced reports are in namespace unless
+// they set theirs, and the others have no namespace.
+func manifestsByKey(manifest, namespace string, namespaced func(apiVersion, kind string) bool) (map[string]manifestDoc, error) {
+	list, err := manifestDocs(manifest, namespace)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]manifestDoc)
+	for _, doc := range list {
+		if !namespaced(doc.APIVersion, doc.Kind) {
+			doc.Namespace = ""
+		}
+		docs[doc.key()] = doc
+	}
+	return docs, nil
+}
+
+// manifestDocs splits a release manifest into its documents, in order.
+func manifestDocs(manifest, namespace string) ([]manifestDoc, error) {
+	split := releaseutil.SplitManifests(manifest)
+	keys := make([]string, 0, len(split))
+	for k := range split {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	var docs []manifestDoc
+	for _, k := range keys {
+		content := split[k]
+		var head struct {
+			APIVersion string `json:"apiVersion"`
+			Kind       string `json:"kind"`
+			Metadata   struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"metadata"`
+		}
+		if err := yaml.Unmarshal([]byte(content), &head); err != nil {
+			return nil, err
+		}
+		if head.Kind == "" {
+			// Comment-only documents, e.g. empty templates.
+			continue
+		}
+		doc := manifestDoc{
+			APIVersion: head.APIVersion,
+			Kind:       head.Kind,
+			Namespace:  head.Metadata.Namespace,
+			Name:       head.Metadata.Name,
+			Content:    content + "\n",
+		}
+		if doc.Namespace == "" {
+			doc.Namespace = namespace
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// namespacedKinds returns a function reporting whether the resources of a
+// kind are namespaced, according to the REST mapper of the configuration.
+func (cfg *Configuration) namespacedKinds() func(apiVersion, kind string) bool {
+	var mapper meta.RESTMapper
+	if cfg.RESTClientGetter != nil {
+		mapper, _ = cfg.RESTClientGetter.ToRESTMapper()
+	}
+	return mapperNamespacedKinds(mapper)
+}
+
+// mapperNamespacedKinds returns a function reporting whether the resources of
+// a kind are namespaced according to mapper. Kinds that cannot be mapped, for
+// example without a cluster, are taken as namespaced, which most kinds are.
+func mapperNamespacedKinds(mapper meta.RESTMapper) func(apiVersion, kind string) bool {
+	return func(apiVersion, kind string) bool {
+		if mapper == nil {
+			return true
+		}
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return true
+		}
+		mapping, err := mapper.RESTMapping(gv.WithKind(kind).GroupKind(), gv.Version)
+		if err != nil {
+			return true
+		}
+		return mapping.Scope.Name() == meta.RESTScopeNameNamespace
+	}
+}
+
+func (doc manifestDoc) key() string {
+	return resourceKey(doc.APIVersion, doc.Kind, doc.Namespace, doc.Name)
+}
+
+func resourceKey(apiVersion, kind, namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s/%s", apiVersion, kind, namespace, name)
+}
+
+func infoKey(info *resource.Info) string {
+	gvk := info.Object.GetObjectKind().GroupVersionKind()
+	return resourceKey(gvk.GroupVersion().String(), gvk.Kind, info.Namespace, info.Name)
+}
+
+func runtimeObjectKey(obj runtime.Object) string {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	var namespace, name string
+	if m, err := meta.Accessor(obj); err == nil {
+		namespace, name = m.GetNamespace(), m.GetName()
+	}
+	return resourceKey(gvk.GroupVersion().String(), gvk.Kind, namespace, name)
+}
+
+// objectYAML renders an object as YAML without the fields the API server
+// maintains, so that only meaningful differences remain.
+func objectYAML(obj runtime.Object) string {
+	u := comparableObject(obj)
+	if u == nil {
+		return ""
+	}
+	b, err := yaml.Marshal(u.Object)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b)) + "\n"
+}
+
+// comparableObject returns obj as unstructured without the fields the API
+// server maintains, or nil if obj cannot be converted.
+func comparableObject(obj runtime.Object) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return nil
+	}
+	u := &unstructured.Unstructured{Object: content}
+	for _, f := range []string{"managedFields", "resourceVersion", "uid", "generation", "creationTimestamp", "selfLink"} {
+		unstructured.RemoveNestedField(u.Object, "metadata", f)
+	}
+	unstructured.RemoveNestedField(u.Object, "metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration")
+	if len(u.GetAnnotations()) == 0 {
+		unstructured.RemoveNestedField(u.Object, "metadata", "annotations")
+	}
+	unstructured.RemoveNestedField(u.Object, "status")
+	return u
+}
+
+const (
+	redactedValue    = "(redacted)"
+	redactedOldValue = "(redacted, old value)"
+	redactedNewValue = "(redacted, new value)"
+)
+
+// redactSecret replaces the values of the data and stringData of the Secret
+// manifests from and to, so that their diff shows which keys change but not
+// their values. Manifests that cannot be parsed are hidden, like with
+// --hide-secret.
+func redactSecret(from, to string) (string, string) {
+	fromObj, fromOK := parseSecret(from)
+	toObj, toOK := parseSecret(to)
+	if !fromOK || !toOK {
+		return hiddenSecret(from), hiddenSecret(to)
+	}
+
+	for _, field := range []string{"data", "stringData"} {
+		fromData, _ := fromObj[field].(map[string]interface{})
+		toData, _ := toObj[field].(map[string]interface{})
+		changed := make(map[string]bool)
+		for k, v := range fromData {
+			if tv, ok := toData[k]; ok && !reflect.DeepEqual(v, tv) {
+				changed[k] = true
+			}
+		}
+		redact(fromData, changed, redactedOldValue)
+		redact(toData, changed, redactedNewValue)
+	}
+	return formatSecret(from, fromObj), formatSecret(to, toObj)
+}
+
+// redact replaces the values of data, with changedValue for the changed keys.
+func redact(data map[string]interface{}, changed map[string]bool, changedValue string) {
+	for k := range data {
+		if changed[k] {
+			data[k] = changedValue
+		} else {
+			data[k] = redactedValue
+		}
+	}
+}
+
+// parseSecret parses a Secret manifest, which is empty if s is.
+func parseSecret(s string) (map[string]interface{}, bool) {
+	if s == "" {
+		return nil, true
+	}
+	var obj map[string]interface{}
+	if err := yaml.Unmarshal([]byte(s), &obj); err != nil {
+		return nil, false
+	}
+	return obj, true
+}
+
+// formatSecret formats obj, the redacted Secret parsed from s, keeping the
+// comments heading s like its source.
+func formatSecret(s string, obj map[string]interface{}) string {
+	if s == "" {
+		return ""
+	}
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return hiddenSecret(s)
+	}
+	var head strings.Builder
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		head.WriteString(line)
+	}
+	return head.String() + string(b)
+}
+
+func hiddenSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "# HIDDEN: The Secret output has been suppressed\n"
+}
@@ -0,0 +1,168 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const diffStoredManifest = `---
+# Source: hello/templates/a.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  key: old
+---
+# Source: hello/templates/b.yaml
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+data:
+  key: value
+`
+
+func TestDiffUpgrade(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	rel := releaseStub()
+	rel.Name = "diffed"
+	rel.Namespace = "spaced"
+	rel.Manifest = diffStoredManifest
+	req.NoError(config.Releases.Create(rel))
+
+	ch := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/a.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  key: new\n")},
+			{Name: "templates/c.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n  namespace: other\n")},
+		}
+	})
+
+	client := NewDiff(config)
+	res, err := client.Run(rel.Name, ch, map[string]interface{}{})
+	req.NoError(err)
+	is.True(res.Changed())
+	is.Equal(2, res.Revision)
+	req.Len(res.Resources, 3)
+
+	byName := map[string]ResourceDiff{}
+	for _, r := range res.Resources {
+		byName[r.Name] = r
+	}
+
+	is.Equal(ChangeChanged, byName["a"].Manifest.Change)
+	is.Contains(byName["a"].Manifest.Diff, "-  key: old")
+	is.Contains(byName["a"].Manifest.Diff, "+  key: new")
+	is.Equal("spaced", byName["a"].Namespace)
+
+	is.Equal(ChangeRemoved, byName["b"].Manifest.Change)
+	is.Equal(ChangeAdded, byName["c"].Manifest.Change)
+	is.Equal("other", byName["c"].Namespace)
+
+	// Nothing must be recorded for a diff.
+	last, err := config.Releases.Last(rel.Name)
+	req.NoError(err)
+	is.Equal(1, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+}
+
+func TestDiffUpgrade_NoChanges(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	rel := releaseStub()
+	rel.Name = "diffed"
+	rel.Namespace = "spaced"
+	rel.Manifest = diffStoredManifest
+	req.NoError(config.Releases.Create(rel))
+
+	ch := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/a.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  key: old\n")},
+			{Name: "templates/b.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\ndata:\n  key: value\n")},
+		}
+	})
+
+	res, err := NewDiff(config).Run(rel.Name, ch, map[string]interface{}{})
+	req.NoError(err)
+	is.False(res.Changed())
+}
+
+func TestDiffUpgrade_NoDeployedRelease(t *testing.T) {
+	config := actionConfigFixture(t)
+	_, err := NewDiff(config).Run("missing", buildChart(), map[string]interface{}{})
+	assert.Error(t, err)
+}
+
+func TestDiffUpgrade_RedactsSecrets(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	rel := releaseStub()
+	rel.Name = "diffed"
+	rel.Namespace = "spaced"
+	rel.Manifest = "---\n# Source: hello/templates/secret.yaml\napiVersion: v1\nkind: Secret\nmetadata:\n  name: s\nstringData:\n  kept: hunter2\n  password: old-password\n"
+	req.NoError(config.Releases.Create(rel))
+
+	ch := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/secret.yaml", Data: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\nstringData:\n  kept: hunter2\n  password: new-password\n  token: new-token\n")},
+		}
+	})
+
+	res, err := NewDiff(config).Run(rel.Name, ch, map[string]interface{}{})
+	req.NoError(err)
+	req.Len(res.Resources, 1)
+	diff := res.Resources[0].Manifest
+	is.Equal(ChangeChanged, diff.Change)
+	for _, secret := range []string{"hunter2", "old-password", "new-password", "new-token"} {
+		is.NotContains(diff.Diff, secret)
+	}
+	is.Contains(diff.Diff, "   kept: (redacted)\n")
+	is.Contains(diff.Diff, "-  password: (redacted, old value)\n+  password: (redacted, new value)\n")
+	is.Contains(diff.Diff, "+  token: (redacted)\n")
+}
+
+func TestManifestsByKey_ClusterScoped(t *testing.T) {
+	is := assert.New(t)
+
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "ClusterRole"}, meta.RESTScopeRoot)
+
+	manifest := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n---\napiVersion: rbac.authorization.k8s.io/v1\nkind: ClusterRole\nmetadata:\n  name: b\n"
+	docs, err := manifestsByKey(manifest, "spaced", mapperNamespacedKinds(mapper))
+	is.NoError(err)
+	is.Contains(docs, "v1/ConfigMap/spaced/a")
+	// Like objects built from the manifest, cluster-scoped ones have no
+	// namespace.
+	is.Contains(docs, "rbac.authorization.k8s.io/v1/ClusterRole//b")
+}
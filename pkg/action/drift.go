@@ -0,0 +1,304 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// DriftType describes how a resource drifted from the release manifest.
+type DriftType string
+
+const (
+	// DriftModified indicates fields of the resource were changed out-of-band.
+	DriftModified DriftType = "modified"
+	// DriftDeleted indicates the resource was deleted from the cluster.
+	DriftDeleted DriftType = "deleted"
+	// DriftOrphaned indicates the resource carries the release's ownership
+	// metadata but is no longer part of the release manifest.
+	DriftOrphaned DriftType = "orphaned"
+)
+
+// ResourceDrift describes the drift of a single resource.
+type ResourceDrift struct {
+	APIVersion string    `json:"apiVersion"`
+	Kind       string    `json:"kind"`
+	Namespace  string    `json:"namespace,omitempty"`
+	Name       string    `json:"name"`
+	Drift      DriftType `json:"drift"`
+	// Fields lists the paths of the fields changed out-of-band, e.g.
+	// ".spec.replicas".
+	Fields []string `json:"fields,omitempty"`
+	// Diff is a unified diff from the manifest to the live object.
+	Diff string `json:"diff,omitempty"`
+}
+
+// DriftResult holds the drift of a release.
+type DriftResult struct {
+	Release   string          `json:"release"`
+	Namespace string          `json:"namespace"`
+	Revision  int             `json:"revision"`
+	Resources []ResourceDrift `json:"resources"`
+}
+
+// Drifted returns true if any resource drifted from the release manifest.
+func (r *DriftResult) Drifted() bool {
+	return len(r.Resources) > 0
+}
+
+// Drift is the action for detecting resources of a release that were changed
+// outside of Helm.
+//
+// It provides the implementation of 'helm status --drift'.
+type Drift struct {
+	cfg *Configuration
+
+	// Version is the revision of the release to check. The last revision is
+	// used if it is not set.
+	Version int
+	// Context is the number of context lines shown around each change.
+	Context int
+}
+
+// NewDrift creates a new Drift object with the given configuration.
+func NewDrift(cfg *Configuration) *Drift {
+	return &Drift{
+		cfg:     cfg,
+		Context: 3,
+	}
+}
+
+// Run compares the manifest of the named release with the live cluster.
+func (d *Drift) Run(name string) (*DriftResult, error) {
+	if err := d.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	rel, err := d.cfg.releaseContent(name, d.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := d.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
+	}
+
+	result := &DriftResult{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Resources: []ResourceDrift{},
+	}
+
+	err = resources.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		merged, live, fields, err := compareLive(info)
+		if err != nil {
+			return err
+		}
+		if live == nil {
+			result.Resources = append(result.Resources, newResourceDrift(info, DriftDeleted))
+			return nil
+		}
+		if len(fields) == 0 {
+			return nil
+		}
+		drift := newResourceDrift(info, DriftModified)
+		drift.Fields = fields
+		drift.Diff = unifiedDiff(d.cfg, infoKey(info), objectYAML(merged), objectYAML(live), d.Context)
+		result.Resources = append(result.Resources, drift)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, err := d.orphans(rel, resources)
+	if err != nil {
+		return nil, err
+	}
+	result.Resources = append(result.Resources, orphans...)
+
+	sort.SliceStable(result.Resources, func(i, j int) bool {
+		a, b := result.Resources[i], result.Resources[j]
+		return resourceKey(a.APIVersion, a.Kind, a.Namespace, a.Name) < resourceKey(b.APIVersion, b.Kind, b.Namespace, b.Name)
+	})
+	return result, nil
+}
+
+// orphans returns the resources that carry the ownership metadata of the
+// release but are not part of its manifest anymore. They are listed by the
+// managed-by label, for each kind and namespace the release deployed
+// resources of in any of its revisions.
+func (d *Drift) orphans(rel *release.Release, current kube.ResourceList) ([]ResourceDrift, error) {
+	history, err := d.cfg.Releases.History(rel.Name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get history of release %q", rel.Name)
+	}
+
+	searched := append(kube.ResourceList{}, current...)
+	for _, h := range history {
+		if h.Version == rel.Version {
+			continue
+		}
+		resources, err := d.cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), false)
+		if err != nil {
+			// Old revisions may use APIs the cluster does not serve anymore.
+			d.cfg.Log("skipping revision %d of release %q for drift detection: %s", h.Version, h.Name, err)
+			continue
+		}
+		searched = append(searched, resources...)
+	}
+
+	var orphans []ResourceDrift
+	listed := make(map[string]bool)
+	for _, info := range searched {
+		key := fmt.Sprintf("%s/%s", info.Mapping.Resource.GroupResource(), info.Namespace)
+		if listed[key] {
+			continue
+		}
+		listed[key] = true
+
+		owned, err := listOwned(info, rel)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range owned {
+			if current.Get(o) == nil {
+				orphans = append(orphans, newResourceDrift(o, DriftOrphaned))
+			}
+		}
+	}
+	return orphans, nil
+}
+
+// listOwned lists the resources of the kind and namespace of info that are
+// owned by the release.
+func listOwned(info *resource.Info, rel *release.Release) ([]*resource.Info, error) {
+	helper := resource.NewHelper(info.Client, info.Mapping)
+	list, err := helper.List(info.Namespace, info.Mapping.GroupVersionKind.GroupVersion().String(), &metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", appManagedByLabel, appManagedByHelm),
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "unable to list %s", info.Mapping.Resource.GroupResource())
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list %s", info.Mapping.Resource.GroupResource())
+	}
+
+	var owned []*resource.Info
+	for _, item := range items {
+		if checkOwnership(item, rel.Name, rel.Namespace) != nil {
+			continue
+		}
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, &resource.Info{
+			Client:    info.Client,
+			Mapping:   info.Mapping,
+			Namespace: accessor.GetNamespace(),
+			Name:      accessor.GetName(),
+			Object:    item,
+		})
+	}
+	return owned, nil
+}
+
+// compareLive compares the manifest object of info with the live object. It
+// returns a nil live object if the resource does not exist. Otherwise, it
+// returns the manifest object merged into the live one as Helm would apply
+// it, and the paths of the fields changed out-of-band.
+func compareLive(info *resource.Info) (merged, live runtime.Object, fields []string, err error) {
+	live, err = resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil, nil, nil
+		}
+		return nil, nil, nil, errors.Wrapf(err, "could not get information about the resource %s", resourceString(info))
+	}
+
+	merged, err = kube.MergeLive(info, info.Object, live)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "unable to compare %s with the live object", resourceString(info))
+	}
+	want, got := comparableObject(merged), comparableObject(live)
+	if want == nil || got == nil {
+		return nil, nil, nil, errors.Errorf("unable to compare %s with the live object", resourceString(info))
+	}
+	return merged, live, changedFields(want.Object, got.Object, ""), nil
+}
+
+func newResourceDrift(info *resource.Info, drift DriftType) ResourceDrift {
+	apiVersion, kind := info.Mapping.GroupVersionKind.ToAPIVersionAndKind()
+	return ResourceDrift{
+		APIVersion: apiVersion,
+		Kind:       kind,
+		Namespace:  info.Namespace,
+		Name:       info.Name,
+		Drift:      drift,
+	}
+}
+
+// changedFields returns the paths of the fields that differ between want and
+// got. Lists are compared as a whole.
+func changedFields(want, got map[string]interface{}, prefix string) []string {
+	keys := make(map[string]struct{}, len(want)+len(got))
+	for k := range want {
+		keys[k] = struct{}{}
+	}
+	for k := range got {
+		keys[k] = struct{}{}
+	}
+
+	var fields []string
+	for k := range keys {
+		path := fmt.Sprintf("%s.%s", prefix, k)
+		w, g := want[k], got[k]
+		wm, wok := w.(map[string]interface{})
+		gm, gok := g.(map[string]interface{})
+		switch {
+		case wok && gok:
+			fields = append(fields, changedFields(wm, gm, path)...)
+		case !reflect.DeepEqual(w, g):
+			fields = append(fields, path)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
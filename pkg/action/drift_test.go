@@ -0,0 +1,196 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/cli-runtime/pkg/resource"
+	restfake "k8s.io/client-go/rest/fake"
+	"sigs.k8s.io/yaml"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+// clusterKubeClient builds ConfigMaps backed by a fake cluster holding the
+// given live objects, keyed by namespace/name.
+type clusterKubeClient struct {
+	kubefake.PrintingKubeClient
+	t    *testing.T
+	live map[string]string
+}
+
+func (c *clusterKubeClient) Build(r io.Reader, _ bool) (kube.ResourceList, error) {
+	manifest, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var list kube.ResourceList
+	for _, doc := range releaseutil.SplitManifests(string(manifest)) {
+		obj := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(doc), &obj.Object); err != nil {
+			return nil, err
+		}
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace("spaced")
+		}
+		list.Append(&resource.Info{
+			Client:    c.restClient(),
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			Object:    obj,
+			Mapping: &meta.RESTMapping{
+				Resource:         obj.GroupVersionKind().GroupVersion().WithResource("configmaps"),
+				GroupVersionKind: obj.GroupVersionKind(),
+				Scope:            meta.RESTScopeNamespace,
+			},
+		})
+	}
+	return list, nil
+}
+
+func (c *clusterKubeClient) restClient() resource.RESTClient {
+	return &restfake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			header := http.Header{}
+			header.Set("Content-Type", runtime.ContentTypeJSON)
+			key := strings.TrimPrefix(strings.Replace(req.URL.Path, "/configmaps/", "/", 1), "/namespaces/")
+			if namespace, ok := strings.CutSuffix(key, "/configmaps"); ok {
+				return c.listResponse(namespace, header), nil
+			}
+			live, ok := c.live[key]
+			if !ok {
+				body := `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`
+				return &http.Response{StatusCode: http.StatusNotFound, Header: header, Body: io.NopCloser(strings.NewReader(body))}, nil
+			}
+			body, err := yaml.YAMLToJSON([]byte(live))
+			if err != nil {
+				c.t.Fatal(err)
+			}
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+		}),
+	}
+}
+
+// listResponse lists the live objects of the namespace.
+func (c *clusterKubeClient) listResponse(namespace string, header http.Header) *http.Response {
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{"apiVersion": "v1", "kind": "ConfigMapList"}}
+	for key, live := range c.live {
+		if !strings.HasPrefix(key, namespace+"/") {
+			continue
+		}
+		obj := unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(live), &obj.Object); err != nil {
+			c.t.Fatal(err)
+		}
+		list.Items = append(list.Items, obj)
+	}
+	body, err := list.MarshalJSON()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}
+}
+
+const driftLiveOwned = `
+  labels:
+    app.kubernetes.io/managed-by: Helm
+  annotations:
+    meta.helm.sh/release-name: drifty
+    meta.helm.sh/release-namespace: spaced
+`
+
+func TestDrift(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	config.KubeClient = &clusterKubeClient{
+		t: t,
+		live: map[string]string{
+			"spaced/a": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: spaced\n  resourceVersion: \"7\"" + driftLiveOwned + "data:\n  key: changed\n  extra: value\n",
+			"spaced/c": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n  namespace: spaced" + driftLiveOwned,
+			"spaced/d": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n  namespace: spaced\n",
+			"spaced/e": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: e\n  namespace: spaced" + driftLiveOwned + "data:\n  key: value\n",
+			// f is owned by the release, but not part of any revision left in
+			// its history.
+			"spaced/f": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: f\n  namespace: spaced" + driftLiveOwned,
+			"spaced/g": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: g\n  namespace: spaced" + strings.Replace(driftLiveOwned, "drifty", "other", 1),
+		},
+	}
+
+	old := releaseStub()
+	old.Name = "drifty"
+	old.Namespace = "spaced"
+	old.Info.Status = release.StatusSuperseded
+	old.Manifest = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n"
+	req.NoError(config.Releases.Create(old))
+
+	rel := releaseStub()
+	rel.Name = "drifty"
+	rel.Namespace = "spaced"
+	rel.Version = 2
+	rel.Manifest = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  key: value\n" +
+		"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n" +
+		"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: e\ndata:\n  key: value\n"
+	req.NoError(config.Releases.Create(rel))
+
+	res, err := NewDrift(config).Run(rel.Name)
+	req.NoError(err)
+	is.True(res.Drifted())
+	is.Equal(2, res.Revision)
+	req.Len(res.Resources, 4)
+
+	is.Equal("a", res.Resources[0].Name)
+	is.Equal(DriftModified, res.Resources[0].Drift)
+	is.Equal([]string{".data.key"}, res.Resources[0].Fields)
+	is.Contains(res.Resources[0].Diff, "-  key: value")
+	is.Contains(res.Resources[0].Diff, "+  key: changed")
+
+	is.Equal("b", res.Resources[1].Name)
+	is.Equal(DriftDeleted, res.Resources[1].Drift)
+
+	is.Equal("c", res.Resources[2].Name)
+	is.Equal(DriftOrphaned, res.Resources[2].Drift)
+
+	is.Equal("f", res.Resources[3].Name)
+	is.Equal(DriftOrphaned, res.Resources[3].Drift)
+}
+
+func TestDrift_NoDrift(t *testing.T) {
+	config := actionConfigFixture(t)
+	rel := releaseStub()
+	rel.Name = "steady"
+	require.NoError(t, config.Releases.Create(rel))
+
+	res, err := NewDrift(config).Run(rel.Name)
+	require.NoError(t, err)
+	assert.False(t, res.Drifted())
+}
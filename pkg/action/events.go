@@ -0,0 +1,148 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
+)
+
+// EventType describes what an Event reports.
+type EventType string
+
+const (
+	// EventPhaseStarted reports that an action entered a new phase, e.g.
+	// "install", "pre-upgrade" or "wait".
+	EventPhaseStarted EventType = "PhaseStarted"
+	// EventHookCreated reports that the resources of a hook were created.
+	EventHookCreated EventType = "HookCreated"
+	// EventHookFinished reports that a hook ran to completion or failed.
+	EventHookFinished EventType = "HookFinished"
+	// EventResourceCreated reports that a resource was created.
+	EventResourceCreated EventType = "ResourceCreated"
+	// EventResourceUpdated reports that a resource was updated.
+	EventResourceUpdated EventType = "ResourceUpdated"
+	// EventResourceDeleted reports that a resource was deleted.
+	EventResourceDeleted EventType = "ResourceDeleted"
+	// EventResourceReady reports that a resource became ready while waiting.
+	EventResourceReady EventType = "ResourceReady"
+	// EventWaiting reports a resource that is not ready yet while waiting.
+	EventWaiting EventType = "Waiting"
+)
+
+// Event describes the progress of an action. Events are passed to
+// Configuration.EventHandler as they happen.
+type Event struct {
+	Type      EventType     `json:"type"`
+	Time      helmtime.Time `json:"time"`
+	Release   string        `json:"release"`
+	Namespace string        `json:"namespace,omitempty"`
+	// Phase is set for EventPhaseStarted and hook events.
+	Phase string `json:"phase,omitempty"`
+	// Resource is set for resource and hook events.
+	Resource *EventResource `json:"resource,omitempty"`
+	// Message is a human readable description of the event.
+	Message string `json:"message,omitempty"`
+}
+
+// EventResource identifies the Kubernetes resource an Event is about.
+type EventResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (cfg *Configuration) emit(rel *release.Release, e Event) {
+	if cfg.EventHandler == nil {
+		return
+	}
+	e.Time = cfg.Now()
+	e.Release = rel.Name
+	e.Namespace = rel.Namespace
+	cfg.EventHandler(e)
+}
+
+func (cfg *Configuration) emitPhase(rel *release.Release, phase string) {
+	cfg.emit(rel, Event{Type: EventPhaseStarted, Phase: phase})
+}
+
+func (cfg *Configuration) emitResources(rel *release.Release, t EventType, resources kube.ResourceList) {
+	for _, info := range resources {
+		r := &EventResource{Namespace: info.Namespace, Name: info.Name}
+		if info.Mapping != nil {
+			r.Kind = info.Mapping.GroupVersionKind.Kind
+		}
+		cfg.emit(rel, Event{Type: t, Resource: r})
+	}
+}
+
+func (cfg *Configuration) emitResult(rel *release.Release, res *kube.Result) {
+	if res == nil {
+		return
+	}
+	cfg.emitResources(rel, EventResourceCreated, res.Created)
+	cfg.emitResources(rel, EventResourceUpdated, res.Updated)
+	cfg.emitResources(rel, EventResourceDeleted, res.Deleted)
+}
+
+// waitForResources waits for resources to be ready and reports their
+// progress as events while the client polls.
+func (cfg *Configuration) waitForResources(rel *release.Release, resources kube.ResourceList, timeout time.Duration, withJobs bool) error {
+	cfg.emit(rel, Event{Type: EventPhaseStarted, Phase: "wait", Message: fmt.Sprintf("waiting for %d resources", len(resources))})
+
+	kubeClient := cfg.KubeClient
+	if c, ok := cfg.KubeClient.(*kube.Client); ok && cfg.EventHandler != nil {
+		// Copy the client so concurrent actions sharing it don't see
+		// each other's progress.
+		progressClient := *c
+		progressClient.WaitProgress = cfg.waitProgress(rel)
+		kubeClient = &progressClient
+	}
+	if withJobs {
+		return kubeClient.WaitWithJobs(resources, timeout)
+	}
+	return kubeClient.Wait(resources, timeout)
+}
+
+// waitProgress returns a kube.Client progress function which emits an event
+// whenever the status of a resource changes.
+func (cfg *Configuration) waitProgress(rel *release.Release) func([]kube.ResourceStatus) {
+	seen := map[kube.ResourceStatus]bool{}
+	return func(statuses []kube.ResourceStatus) {
+		for _, s := range statuses {
+			key := s
+			key.Message = ""
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			e := Event{
+				Type:     EventWaiting,
+				Resource: &EventResource{Kind: s.Kind, Namespace: s.Namespace, Name: s.Name},
+				Message:  s.Message,
+			}
+			if s.Ready {
+				e.Type = EventResourceReady
+			}
+			cfg.emit(rel, e)
+		}
+	}
+}
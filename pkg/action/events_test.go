@@ -0,0 +1,75 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallRelease_Events(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	instAction := installAction(t)
+	instAction.Wait = true
+	var events []Event
+	instAction.cfg.EventHandler = func(e Event) { events = append(events, e) }
+
+	_, err := instAction.Run(buildChart(withSampleTemplates()), map[string]interface{}{})
+	req.NoError(err)
+
+	var phases []string
+	var hookEvents []EventType
+	for _, e := range events {
+		is.Equal(instAction.ReleaseName, e.Release)
+		is.Equal("spaced", e.Namespace)
+		switch e.Type {
+		case EventPhaseStarted:
+			phases = append(phases, e.Phase)
+		case EventHookCreated, EventHookFinished:
+			is.Equal("post-install", e.Phase)
+			req.NotNil(e.Resource)
+			is.Equal("test-cm", e.Resource.Name)
+			hookEvents = append(hookEvents, e.Type)
+		}
+	}
+	is.Equal([]string{"install", "wait", "post-install"}, phases)
+	is.Equal([]EventType{EventHookCreated, EventHookFinished}, hookEvents)
+}
+
+func TestUninstallRelease_Events(t *testing.T) {
+	is := assert.New(t)
+
+	unAction := uninstallAction(t)
+	unAction.DisableHooks = true
+	rel := releaseStub()
+	rel.Name = "come-fail-away"
+	unAction.cfg.Releases.Create(rel)
+
+	var events []Event
+	unAction.cfg.EventHandler = func(e Event) { events = append(events, e) }
+
+	_, err := unAction.Run(rel.Name)
+	is.NoError(err)
+	if is.NotEmpty(events) {
+		is.Equal(EventPhaseStarted, events[0].Type)
+		is.Equal("uninstall", events[0].Phase)
+	}
+}
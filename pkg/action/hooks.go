@@ -18,6 +18,7 @@ package action
 import (
 	"bytes"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -29,6 +30,12 @@ import (
 
 // execHook executes all of the hooks for the given hook event.
 func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent, timeout time.Duration) error {
+	return cfg.execHookParallel(rl, hook, timeout, cfg.HookParallelism)
+}
+
+// execHookParallel executes all of the hooks for the given hook event, running
+// up to parallelism hooks of the same weight at the same time.
+func (cfg *Configuration) execHookParallel(rl *release.Release, hook release.HookEvent, timeout time.Duration, parallelism int) error {
 	executingHooks := []*release.Hook{}
 
 	for _, h := range rl.Hooks {
@@ -42,6 +49,10 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 	// hooke are pre-ordered by kind, so keep order stable
 	sort.Stable(hookByWeight(executingHooks))
 
+	if len(executingHooks) > 0 {
+		cfg.emitPhase(rl, string(hook))
+	}
+
 	for _, h := range executingHooks {
 		// Set default delete policy to before-hook-creation
 		if h.DeletePolicies == nil || len(h.DeletePolicies) == 0 {
@@ -51,63 +62,188 @@ func (cfg *Configuration) execHook(rl *release.Release, hook release.HookEvent,
 			//                 current release.
 			h.DeletePolicies = []release.HookDeletePolicy{release.HookBeforeHookCreation}
 		}
+	}
 
-		if err := cfg.deleteHookByPolicy(h, release.HookBeforeHookCreation, timeout); err != nil {
+	for _, group := range hookWeightGroups(executingHooks) {
+		if err := cfg.execHookGroup(rl, hook, group, timeout, parallelism); err != nil {
 			return err
 		}
+	}
 
-		resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
-		if err != nil {
-			return errors.Wrapf(err, "unable to build kubernetes object for %s hook %s", hook, h.Path)
+	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
+	// under succeeded condition. If so, then clear the corresponding resource object in each hook
+	for _, h := range executingHooks {
+		if err := cfg.deleteHookByPolicy(h, release.HookSucceeded, timeout); err != nil {
+			return err
 		}
+	}
 
-		// Record the time at which the hook was applied to the cluster
-		h.LastRun = release.HookExecution{
-			StartedAt: helmtime.Now(),
-			Phase:     release.HookPhaseRunning,
-		}
-		cfg.recordRelease(rl)
-
-		// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
-		// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
-		// the most appropriate value to surface.
-		h.LastRun.Phase = release.HookPhaseUnknown
-
-		// Create hook resources
-		if _, err := cfg.KubeClient.Create(resources); err != nil {
-			h.LastRun.CompletedAt = helmtime.Now()
-			h.LastRun.Phase = release.HookPhaseFailed
-			return errors.Wrapf(err, "warning: Hook %s %s failed", hook, h.Path)
+	return nil
+}
+
+// execFailureHooks executes the on-failure hooks of a release which failed to
+// install or upgrade. A failing on-failure hook is only logged, so that the
+// original error is reported and the release is still cleaned up.
+func (cfg *Configuration) execFailureHooks(rl *release.Release, timeout time.Duration) {
+	if err := cfg.execHook(rl, release.HookOnFailure, timeout); err != nil {
+		cfg.Log("warning: %s hooks failed: %s", release.HookOnFailure, err)
+	}
+}
+
+// execHookGroup executes hooks of the same weight. Up to parallelism hooks are
+// run at the same time. If a hook fails, the hooks which have not been started
+// yet are skipped and the error of the first failed hook is returned once the
+// running ones are done.
+func (cfg *Configuration) execHookGroup(rl *release.Release, hook release.HookEvent, hooks []*release.Hook, timeout time.Duration, parallelism int) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		failed bool
+	)
+	errs := make([]error, len(hooks))
+	sem := make(chan struct{}, parallelism)
+	for i, h := range hooks {
+		sem <- struct{}{}
+		mu.Lock()
+		stop := failed
+		mu.Unlock()
+		if stop {
+			<-sem
+			break
 		}
 
-		// Watch hook resources until they have completed
-		err = cfg.KubeClient.WatchUntilReady(resources, timeout)
-		// Note the time of success/failure
-		h.LastRun.CompletedAt = helmtime.Now()
-		// Mark hook as succeeded or failed
-		if err != nil {
-			h.LastRun.Phase = release.HookPhaseFailed
-			// If a hook is failed, check the annotation of the hook to determine whether the hook should be deleted
-			// under failed condition. If so, then clear the corresponding resource object in the hook
-			if err := cfg.deleteHookByPolicy(h, release.HookFailed, timeout); err != nil {
-				return err
+		wg.Add(1)
+		go func(i int, h *release.Hook) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := cfg.execSingleHook(rl, hook, h, timeout, &mu); err != nil {
+				mu.Lock()
+				errs[i] = err
+				failed = true
+				mu.Unlock()
 			}
+		}(i, h)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
 			return err
 		}
-		h.LastRun.Phase = release.HookPhaseSucceeded
+	}
+	return nil
+}
+
+// execSingleHook creates a hook and waits for it to complete. The release and
+// its hooks are shared by the hooks run at the same time, so they are only
+// modified and recorded while holding mu.
+func (cfg *Configuration) execSingleHook(rl *release.Release, hook release.HookEvent, h *release.Hook, timeout time.Duration, mu *sync.Mutex) error {
+	if err := cfg.deleteHookByPolicy(h, release.HookBeforeHookCreation, timeout); err != nil {
+		return err
 	}
 
-	// If all hooks are successful, check the annotation of each hook to determine whether the hook should be deleted
-	// under succeeded condition. If so, then clear the corresponding resource object in each hook
-	for _, h := range executingHooks {
-		if err := cfg.deleteHookByPolicy(h, release.HookSucceeded, timeout); err != nil {
+	resources, err := cfg.KubeClient.Build(bytes.NewBufferString(h.Manifest), true)
+	if err != nil {
+		return errors.Wrapf(err, "unable to build kubernetes object for %s hook %s", hook, h.Path)
+	}
+
+	// Record the time at which the hook was applied to the cluster
+	mu.Lock()
+	h.LastRun = release.HookExecution{
+		StartedAt: helmtime.Now(),
+		Phase:     release.HookPhaseRunning,
+	}
+	cfg.recordRelease(rl)
+
+	// As long as the implementation of WatchUntilReady does not panic, HookPhaseFailed or HookPhaseSucceeded
+	// should always be set by this function. If we fail to do that for any reason, then HookPhaseUnknown is
+	// the most appropriate value to surface.
+	h.LastRun.Phase = release.HookPhaseUnknown
+	mu.Unlock()
+
+	// Create hook resources
+	if _, err := cfg.KubeClient.Create(resources); err != nil {
+		mu.Lock()
+		h.LastRun.CompletedAt = helmtime.Now()
+		h.LastRun.Phase = release.HookPhaseFailed
+		h.LastRun.Message = err.Error()
+		mu.Unlock()
+		return errors.Wrapf(err, "warning: Hook %s %s failed", hook, h.Path)
+	}
+	mu.Lock()
+	cfg.emit(rl, Event{Type: EventHookCreated, Phase: string(hook), Resource: hookResource(h)})
+	mu.Unlock()
+
+	// Watch hook resources until they have completed
+	err = cfg.KubeClient.WatchUntilReady(resources, timeout)
+	logs := cfg.hookLogs(h, resources)
+	mu.Lock()
+	// Note the time of success/failure
+	h.LastRun.CompletedAt = helmtime.Now()
+	h.LastRun.Logs = logs
+	// Mark hook as succeeded or failed
+	if err != nil {
+		h.LastRun.Phase = release.HookPhaseFailed
+		h.LastRun.Message = err.Error()
+		cfg.emit(rl, Event{Type: EventHookFinished, Phase: string(hook), Resource: hookResource(h), Message: err.Error()})
+		mu.Unlock()
+		// If a hook is failed, check the annotation of the hook to determine whether the hook should be deleted
+		// under failed condition. If so, then clear the corresponding resource object in the hook
+		if err := cfg.deleteHookByPolicy(h, release.HookFailed, timeout); err != nil {
 			return err
 		}
+		return err
 	}
-
+	h.LastRun.Phase = release.HookPhaseSucceeded
+	cfg.emit(rl, Event{Type: EventHookFinished, Phase: string(hook), Resource: hookResource(h), Message: string(h.LastRun.Phase)})
+	mu.Unlock()
 	return nil
 }
 
+// hookLogLimit is the maximum number of bytes of the output of each container
+// of a hook kept in the release.
+const hookLogLimit = 8 * 1024
+
+// hookLogs returns the output of the containers run by a Pod or Job hook, so
+// that it is kept once the hook is deleted. Failing to get the logs does not
+// fail the hook.
+func (cfg *Configuration) hookLogs(h *release.Hook, resources kube.ResourceList) []release.HookLog {
+	client, ok := cfg.KubeClient.(kube.InterfaceLogs)
+	if !ok || (h.Kind != "Pod" && h.Kind != "Job") {
+		return nil
+	}
+	logs, err := client.Logs(resources, hookLogLimit)
+	if err != nil {
+		cfg.Log("warning: unable to get the logs of hook %s: %s", h.Path, err)
+	}
+	var hookLogs []release.HookLog
+	for _, l := range logs {
+		hookLogs = append(hookLogs, release.HookLog{Pod: l.Pod, Container: l.Container, Log: l.Log, Truncated: l.Truncated})
+	}
+	return hookLogs
+}
+
+// hookWeightGroups splits hooks sorted by weight into groups of the same
+// weight.
+func hookWeightGroups(hooks []*release.Hook) [][]*release.Hook {
+	var groups [][]*release.Hook
+	for i, h := range hooks {
+		if i == 0 || h.Weight != hooks[i-1].Weight {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], h)
+	}
+	return groups
+}
+
+func hookResource(h *release.Hook) *EventResource {
+	return &EventResource{Kind: h.Kind, Name: h.Name}
+}
+
 // hookByWeight is a sorter for hooks
 type hookByWeight []*release.Hook
 
@@ -0,0 +1,200 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// hookKubeClient records when hooks start and finish, and how many of them
+// run at the same time.
+type hookKubeClient struct {
+	clusterKubeClient
+	fail string
+
+	mu     sync.Mutex
+	active int
+	max    int
+	calls  []string
+}
+
+func (c *hookKubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *hookKubeClient) WatchUntilReady(resources kube.ResourceList, _ time.Duration) error {
+	name := resources[0].Name
+	c.mu.Lock()
+	c.active++
+	if c.active > c.max {
+		c.max = c.active
+	}
+	c.calls = append(c.calls, "start "+name)
+	c.mu.Unlock()
+
+	if name != c.fail {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+	c.calls = append(c.calls, "end "+name)
+	if name == c.fail {
+		return errors.Errorf("hook %s failed", name)
+	}
+	return nil
+}
+
+func (c *hookKubeClient) Logs(resources kube.ResourceList, _ int64) ([]kube.ContainerLog, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var logs []kube.ContainerLog
+	for _, r := range resources {
+		c.calls = append(c.calls, "logs "+r.Name)
+		logs = append(logs, kube.ContainerLog{Pod: r.Name + "-abcde", Container: "main", Log: "output of " + r.Name})
+	}
+	return logs, nil
+}
+
+func (c *hookKubeClient) Delete(resources kube.ResourceList) (*kube.Result, []error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, r := range resources {
+		c.calls = append(c.calls, "delete "+r.Name)
+	}
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func hookRelease(weights map[string]int) *release.Release {
+	rel := releaseStub()
+	rel.Hooks = nil
+	for _, name := range []string{"a", "b", "c", "d"} {
+		weight, ok := weights[name]
+		if !ok {
+			continue
+		}
+		rel.Hooks = append(rel.Hooks, &release.Hook{
+			Name:     name,
+			Kind:     "ConfigMap",
+			Path:     "templates/" + name,
+			Manifest: fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", name),
+			Weight:   weight,
+			Events:   []release.HookEvent{release.HookPreInstall},
+		})
+	}
+	return rel
+}
+
+func hookConfig(t *testing.T, parallelism int) (*Configuration, *hookKubeClient) {
+	config := actionConfigFixture(t)
+	client := &hookKubeClient{clusterKubeClient: clusterKubeClient{t: t, live: map[string]string{}}}
+	client.Out = io.Discard
+	config.KubeClient = client
+	config.HookParallelism = parallelism
+	return config, client
+}
+
+func TestExecHook_Parallel(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 2)
+	rel := hookRelease(map[string]int{"a": 0, "b": 0, "c": 0, "d": 1})
+	req.NoError(config.Releases.Create(rel))
+
+	req.NoError(config.execHook(rel, release.HookPreInstall, time.Minute))
+	is.Equal(2, client.max)
+	is.Equal("start d", client.calls[len(client.calls)-2])
+	for _, h := range rel.Hooks {
+		is.Equal(release.HookPhaseSucceeded, h.LastRun.Phase, h.Name)
+	}
+}
+
+func TestExecHook_Sequential(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 0)
+	rel := hookRelease(map[string]int{"a": 0, "b": 0, "c": 0})
+	req.NoError(config.Releases.Create(rel))
+
+	req.NoError(config.execHook(rel, release.HookPreInstall, time.Minute))
+	is.Equal(1, client.max)
+	is.Equal([]string{"delete a", "start a", "end a", "delete b", "start b", "end b", "delete c", "start c", "end c"}, client.calls)
+}
+
+func TestExecHook_ParallelFailure(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 2)
+	client.fail = "a"
+	rel := hookRelease(map[string]int{"a": 0, "b": 0, "c": 0, "d": 1})
+	req.NoError(config.Releases.Create(rel))
+
+	err := config.execHook(rel, release.HookPreInstall, time.Minute)
+	is.EqualError(err, "hook a failed")
+	is.ElementsMatch([]string{"delete a", "delete b", "start a", "start b", "end a", "end b"}, client.calls)
+	is.Equal(release.HookPhaseFailed, rel.Hooks[0].LastRun.Phase)
+	is.Equal(release.HookPhaseSucceeded, rel.Hooks[1].LastRun.Phase)
+	is.Equal(release.HookPhase(""), rel.Hooks[2].LastRun.Phase)
+	is.Equal(release.HookPhase(""), rel.Hooks[3].LastRun.Phase)
+}
+
+func TestExecHook_Logs(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 1)
+	client.fail = "b"
+	rel := hookRelease(map[string]int{"a": 0, "b": 1})
+	rel.Hooks[0].Kind = "Job"
+	rel.Hooks[1].Kind = "Job"
+	rel.Hooks[1].DeletePolicies = []release.HookDeletePolicy{release.HookFailed}
+	rel.Hooks = append(rel.Hooks, &release.Hook{
+		Name:     "c",
+		Kind:     "ConfigMap",
+		Path:     "templates/c",
+		Manifest: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n",
+		Weight:   2,
+		Events:   []release.HookEvent{release.HookPostInstall},
+	})
+	req.NoError(config.Releases.Create(rel))
+
+	is.Error(config.execHook(rel, release.HookPreInstall, time.Minute))
+	is.Equal([]release.HookLog{{Pod: "a-abcde", Container: "main", Log: "output of a"}}, rel.Hooks[0].LastRun.Logs)
+	is.Equal([]release.HookLog{{Pod: "b-abcde", Container: "main", Log: "output of b"}}, rel.Hooks[1].LastRun.Logs)
+	// The logs of a failed hook are captured before it is deleted.
+	is.Equal([]string{"delete a", "start a", "end a", "logs a", "start b", "end b", "logs b", "delete b"}, client.calls)
+
+	client.calls = nil
+	req.NoError(config.execHook(rel, release.HookPostInstall, time.Minute))
+	is.Empty(rel.Hooks[2].LastRun.Logs)
+	is.Equal([]string{"delete c", "start c", "end c"}, client.calls)
+}
@@ -43,6 +43,7 @@ import (
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/cli"
 	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/engine"
 	"helm.sh/helm/v3/pkg/getter"
 	"helm.sh/helm/v3/pkg/kube"
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
@@ -84,6 +85,7 @@ type Install struct {
 	Devel                    bool
 	DependencyUpdate         bool
 	Timeout                  time.Duration
+	LockTimeout              time.Duration
 	Namespace                string
 	ReleaseName              string
 	GenerateName             bool
@@ -107,12 +109,20 @@ type Install struct {
 	IsUpgrade bool
 	// Enable DNS lookups when rendering templates
 	EnableDNS bool
+	// Trace, if set, records the templates included and the values read
+	// while rendering. Used by helm template --trace
+	Trace *engine.Trace
 	// Used by helm template to add the release as part of OutputDir path
 	// OutputDir/<ReleaseName>
 	UseReleaseName bool
 	// TakeOwnership will ignore the check for helm annotations and take ownership of the resources.
 	TakeOwnership bool
-	PostRenderer  postrender.PostRenderer
+	// ServerSideApply creates and adopts resources with server-side apply.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field managers
+	// when ServerSideApply is set.
+	ForceConflicts bool
+	PostRenderer   postrender.PostRenderer
 	// Lock to control raceconditions when the process receives a SIGTERM
 	Lock sync.Mutex
 }
@@ -242,6 +252,20 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 		return nil, errors.New("Hiding Kubernetes secrets requires a dry-run mode")
 	}
 
+	if err := validateServerSideApply(i.Force, i.ServerSideApply, i.ForceConflicts); err != nil {
+		return nil, err
+	}
+
+	if !i.ClientOnly && !i.isDryRun() {
+		var unlock func()
+		var err error
+		ctx, unlock, err = i.cfg.lockRelease(ctx, i.ReleaseName, i.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	if err := i.availableName(); err != nil {
 		return nil, err
 	}
@@ -313,7 +337,7 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	rel := i.createRelease(chrt, vals, i.Labels)
 
 	var manifestDoc *bytes.Buffer
-	rel.Hooks, manifestDoc, rel.Info.Notes, err = i.cfg.renderResources(chrt, valuesToRender, i.ReleaseName, i.OutputDir, i.SubNotes, i.UseReleaseName, i.IncludeCRDs, i.PostRenderer, interactWithRemote, i.EnableDNS, i.HideSecret)
+	rel.Hooks, manifestDoc, rel.Info.Notes, err = i.cfg.renderResources(chrt, valuesToRender, i.ReleaseName, i.OutputDir, i.SubNotes, i.UseReleaseName, i.IncludeCRDs, i.PostRenderer, interactWithRemote, i.EnableDNS, i.HideSecret, i.Trace)
 	// Even for errors, attach this if available
 	if manifestDoc != nil {
 		rel.Manifest = manifestDoc.String()
@@ -331,6 +355,11 @@ func (i *Install) RunWithContext(ctx context.Context, chrt *chart.Chart, vals ma
 	var toBeAdopted kube.ResourceList
 	resources, err := i.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), !i.DisableOpenAPIValidation)
 	if err != nil {
+		// A post-renderer may have changed the resources, which can no
+		// longer be traced to the templates.
+		if i.PostRenderer == nil {
+			err = i.cfg.locateBuildError(err, chrt, valuesToRender, interactWithRemote, i.EnableDNS, !i.DisableOpenAPIValidation)
+		}
 		return nil, errors.Wrap(err, "unable to build kubernetes objects from release manifest")
 	}
 
@@ -425,7 +454,7 @@ func (i *Install) performInstallCtx(ctx context.Context, rel *release.Release, t
 	}()
 	select {
 	case <-ctx.Done():
-		err := ctx.Err()
+		err := context.Cause(ctx)
 		return rel, err
 	case msg := <-resultChan:
 		return msg.r, msg.e
@@ -441,7 +470,8 @@ func (i *Install) isDryRun() bool {
 }
 
 func (i *Install) performInstall(rel *release.Release, toBeAdopted kube.ResourceList, resources kube.ResourceList) (*release.Release, error) {
-	var err error
+	i.cfg.emitPhase(rel, "install")
+
 	// pre-install hooks
 	if !i.DisableHooks {
 		if err := i.cfg.execHook(rel, release.HookPreInstall, i.Timeout); err != nil {
@@ -452,22 +482,23 @@ func (i *Install) performInstall(rel *release.Release, toBeAdopted kube.Resource
 	// At this point, we can do the install. Note that before we were detecting whether to
 	// do an update, but it's not clear whether we WANT to do an update if the re-use is set
 	// to true, since that is basically an upgrade operation.
-	if len(toBeAdopted) == 0 && len(resources) > 0 {
-		_, err = i.cfg.KubeClient.Create(resources)
-	} else if len(resources) > 0 {
-		_, err = i.cfg.KubeClient.Update(toBeAdopted, resources, i.Force)
+	//
+	// Resources are created wave by wave if they are spread over several
+	// deploy waves.
+	waves, err := kube.DeployWaves(resources)
+	if err != nil {
+		return rel, err
 	}
+	results, err := i.cfg.applyWaves(rel, waves, i.Timeout, i.WaitForJobs, func(wave kube.ResourceList) (*kube.Result, error) {
+		return i.createResources(toBeAdopted.Intersect(wave), wave)
+	})
 	if err != nil {
 		return rel, err
 	}
+	i.cfg.emitResult(rel, results)
 
 	if i.Wait {
-		if i.WaitForJobs {
-			err = i.cfg.KubeClient.WaitWithJobs(resources, i.Timeout)
-		} else {
-			err = i.cfg.KubeClient.Wait(resources, i.Timeout)
-		}
-		if err != nil {
+		if err := i.cfg.waitForResources(rel, resources, i.Timeout, i.WaitForJobs); err != nil {
 			return rel, err
 		}
 	}
@@ -498,11 +529,30 @@ func (i *Install) performInstall(rel *release.Release, toBeAdopted kube.Resource
 	return rel, nil
 }
 
+// createResources creates the resources of the release, adopting the existing
+// ones.
+func (i *Install) createResources(toBeAdopted, resources kube.ResourceList) (*kube.Result, error) {
+	switch {
+	case len(resources) == 0:
+		return nil, nil
+	case i.ServerSideApply:
+		return updateResources(i.cfg.KubeClient, toBeAdopted, resources, i.Force, i.ServerSideApply, i.ForceConflicts)
+	case len(toBeAdopted) == 0:
+		return i.cfg.KubeClient.Create(resources)
+	default:
+		return i.cfg.KubeClient.Update(toBeAdopted, resources, i.Force)
+	}
+}
+
 func (i *Install) failRelease(rel *release.Release, err error) (*release.Release, error) {
 	rel.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", i.ReleaseName, err.Error()))
+	if !i.DisableHooks {
+		i.cfg.execFailureHooks(rel, i.Timeout)
+	}
 	if i.Atomic {
 		i.cfg.Log("Install failed and atomic is set, uninstalling release")
 		uninstall := NewUninstall(i.cfg)
+		uninstall.locked = true
 		uninstall.DisableHooks = i.DisableHooks
 		uninstall.KeepHistory = false
 		uninstall.Timeout = i.Timeout
@@ -17,7 +17,9 @@ limitations under the License.
 package action
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -34,6 +36,8 @@ import (
 	"helm.sh/helm/v3/internal/test"
 	"helm.sh/helm/v3/pkg/chart"
 	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/kube"
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
 	"helm.sh/helm/v3/pkg/release"
 	"helm.sh/helm/v3/pkg/storage/driver"
@@ -192,6 +196,71 @@ func TestInstallRelease_WithNotesRendered(t *testing.T) {
 	is.Equal(rel.Info.Description, "Install complete")
 }
 
+func TestInstallRelease_InvalidYAMLSource(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte("{{- define \"data\" -}}\nname: web\nvalue\n{{- end }}")},
+			{Name: "templates/configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: web\ndata:\n  {{- include \"data\" . | nindent 2 }}\n")},
+		}
+	})
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+
+	// The error points at the line of the helper that produced the invalid
+	// YAML.
+	var rerr *engine.RenderError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	is.Equal(engine.StageManifest, rerr.Stage)
+	is.Equal("hello/templates/_helpers.tpl", rerr.File)
+	is.Equal(3, rerr.Line)
+	is.True(strings.HasPrefix(err.Error(), "hello/templates/_helpers.tpl:3: YAML parse error on hello/templates/configmap.yaml"), err.Error())
+}
+
+// unknownFieldKubeClient fails to build resources setting the field, like a
+// client validating them against the OpenAPI schema.
+type unknownFieldKubeClient struct {
+	kubefake.PrintingKubeClient
+	kind, field string
+}
+
+func (c *unknownFieldKubeClient) Build(r io.Reader, validate bool) (kube.ResourceList, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if validate && strings.Contains(string(b), "\n"+c.field+":") {
+		return nil, fmt.Errorf(`error validating "": error validating data: ValidationError(%s): unknown field %q in io.k8s.api.core.v1.%s`, c.kind, c.field, c.kind)
+	}
+	return c.PrintingKubeClient.Build(bytes.NewReader(b), validate)
+}
+
+func TestInstallRelease_InvalidResourceSource(t *testing.T) {
+	is := assert.New(t)
+	instAction := installAction(t)
+	instAction.cfg.KubeClient = &unknownFieldKubeClient{PrintingKubeClient: kubefake.PrintingKubeClient{Out: io.Discard}, kind: "ConfigMap", field: "spec"}
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/a-configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n")},
+			{Name: "templates/b-configmap.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n{{- if true }}\nspec:\n  replicas: 1\n{{- end }}\n")},
+		}
+	})
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+
+	// The error points at the line of the template setting the unknown
+	// field.
+	var rerr *engine.RenderError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	is.Equal(engine.StageManifest, rerr.Stage)
+	is.Equal("hello/templates/b-configmap.yaml", rerr.File)
+	is.Equal(11, rerr.Line)
+	is.True(strings.HasPrefix(err.Error(), "unable to build kubernetes objects from release manifest: hello/templates/b-configmap.yaml:11: error validating"), err.Error())
+}
+
 func TestInstallRelease_WithChartAndDependencyParentNotes(t *testing.T) {
 	// Regression: Make sure that the child's notes don't override the parent's
 	is := assert.New(t)
@@ -362,6 +431,48 @@ func TestInstallRelease_FailedHooks(t *testing.T) {
 	is.Equal(release.StatusFailed, res.Info.Status)
 }
 
+func TestInstallRelease_FailureHooks(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	t.Run("failure hooks run", func(t *testing.T) {
+		instAction := installAction(t)
+		failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+		failer.WaitError = fmt.Errorf("I timed out")
+		instAction.Wait = true
+
+		res, err := instAction.Run(buildChart(withFailureHook()), map[string]interface{}{})
+		req.Error(err)
+		is.Equal(release.StatusFailed, res.Info.Status)
+		req.NotNil(findHook(res, "notify"))
+		is.Equal(release.HookPhaseSucceeded, findHook(res, "notify").LastRun.Phase)
+	})
+
+	t.Run("failure hooks run before atomic uninstall", func(t *testing.T) {
+		instAction := installAction(t)
+		failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+		failer.WaitError = fmt.Errorf("I timed out")
+		instAction.Atomic = true
+		phases := recordPhases(instAction.cfg)
+
+		_, err := instAction.Run(buildChart(withFailureHook()), map[string]interface{}{})
+		req.Error(err)
+		is.Equal([]string{"install", "wait", "on-failure", "uninstall", "pre-delete"}, *phases)
+	})
+
+	t.Run("failure hooks are disabled", func(t *testing.T) {
+		instAction := installAction(t)
+		failer := instAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+		failer.WaitError = fmt.Errorf("I timed out")
+		instAction.Wait = true
+		instAction.DisableHooks = true
+
+		res, err := instAction.Run(buildChart(withFailureHook()), map[string]interface{}{})
+		req.Error(err)
+		is.True(findHook(res, "notify").LastRun.StartedAt.IsZero())
+	})
+}
+
 func TestInstallRelease_ReplaceRelease(t *testing.T) {
 	is := assert.New(t)
 	instAction := installAction(t)
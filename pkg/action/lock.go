@@ -0,0 +1,131 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+)
+
+// ReleaseLocker serializes the actions modifying a release, so concurrent
+// upgrades of the same release do not leave it pending.
+//
+// kube.LeaseLocker implements it with coordination.k8s.io Leases.
+type ReleaseLocker interface {
+	// Lock acquires the lock of the named release for holder, waiting up
+	// to timeout for another holder to release it. It returns a function
+	// releasing the lock. If the lock is lost while it is held, lost is
+	// called with the reason.
+	Lock(release, holder string, timeout time.Duration, lost func(error)) (func() error, error)
+	// Unlock removes the lock of the named release regardless of its holder
+	// and returns the holder.
+	Unlock(release string) (string, error)
+}
+
+// lockRelease locks the named release if a locker is configured. It returns
+// a context derived from ctx, which is cancelled if the lock is lost, and a
+// function unlocking the release.
+//
+// Actions run by an action holding the lock, e.g. the rollback of an atomic
+// upgrade, are told so and do not lock the release again.
+func (cfg *Configuration) lockRelease(ctx context.Context, name string, timeout time.Duration) (context.Context, func(), error) {
+	if cfg.Locker == nil || chartutil.ValidateReleaseName(name) != nil {
+		return ctx, func() {}, nil
+	}
+
+	holder := cfg.LockHolder
+	if holder == "" {
+		holder = defaultLockHolder()
+	}
+	cfg.Log("locking release %s as %q", name, holder)
+	ctx, cancel := context.WithCancelCause(ctx)
+	unlock, err := cfg.Locker.Lock(name, holder, timeout, func(err error) {
+		cfg.Log("lost the lock of release %s: %s", name, err)
+		cancel(err)
+	})
+	if err != nil {
+		cancel(err)
+		return nil, nil, err
+	}
+
+	return ctx, func() {
+		if err := unlock(); err != nil {
+			cfg.Log("warning: failed to unlock release %s: %s", name, err)
+		}
+		cancel(nil)
+	}, nil
+}
+
+// lockLost returns an error if the context returned by lockRelease was
+// cancelled because the lock was lost.
+func lockLost(ctx context.Context) error {
+	if err := context.Cause(ctx); err != nil {
+		return errors.Wrap(err, "aborting")
+	}
+	return nil
+}
+
+// defaultLockHolder identifies the current process as user@host (pid).
+func defaultLockHolder() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s@%s (pid %d)", name, host, os.Getpid())
+}
+
+// Unlock is the action for removing the lock of a release, e.g. one left by
+// a process which was killed.
+//
+// It provides the implementation of 'helm release unlock'.
+type Unlock struct {
+	cfg *Configuration
+}
+
+// NewUnlock creates a new Unlock object with the given configuration.
+func NewUnlock(cfg *Configuration) *Unlock {
+	return &Unlock{
+		cfg: cfg,
+	}
+}
+
+// Run removes the lock of the named release and returns its holder.
+func (u *Unlock) Run(name string) (string, error) {
+	if u.cfg.Locker == nil {
+		return "", errors.New("release locking is not configured")
+	}
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return "", errors.Errorf("release name is invalid: %s", name)
+	}
+	holder, err := u.cfg.Locker.Unlock(name)
+	if errors.Is(err, kube.ErrNotLocked) {
+		return "", errors.Errorf("release %q is not locked", name)
+	}
+	return holder, err
+}
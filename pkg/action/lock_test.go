@@ -0,0 +1,178 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/kube"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// memoryLocker is a ReleaseLocker holding its locks in memory.
+type memoryLocker struct {
+	mu      sync.Mutex
+	holders map[string]string
+	locked  []string
+	lost    map[string]func(error)
+}
+
+func (l *memoryLocker) Lock(name, holder string, _ time.Duration, lost func(error)) (func() error, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holders == nil {
+		l.holders = map[string]string{}
+	}
+	if other, ok := l.holders[name]; ok {
+		return nil, &kube.LockedError{Release: name, Holder: other}
+	}
+	l.holders[name] = holder
+	l.locked = append(l.locked, name)
+	if l.lost == nil {
+		l.lost = map[string]func(error){}
+	}
+	l.lost[name] = lost
+	return func() error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.holders, name)
+		return nil
+	}, nil
+}
+
+func (l *memoryLocker) Unlock(name string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	holder, ok := l.holders[name]
+	if !ok {
+		return "", kube.ErrNotLocked
+	}
+	delete(l.holders, name)
+	return holder, nil
+}
+
+func TestUpgradeRelease_Locked(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	locker := &memoryLocker{}
+	upAction.cfg.Locker = locker
+	upAction.cfg.LockHolder = "ci-1"
+
+	rel := releaseStub()
+	rel.Name = "nuketown"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	_, err := locker.Lock(rel.Name, "ci-2", 0, nil)
+	req.NoError(err)
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	is.EqualError(err, `release "nuketown" is locked by "ci-2"`)
+
+	// the lock is released once the upgrade finished
+	holder, err := NewUnlock(upAction.cfg).Run(rel.Name)
+	req.NoError(err)
+	is.Equal("ci-2", holder)
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+	is.Empty(locker.holders)
+
+	_, err = NewUnlock(upAction.cfg).Run(rel.Name)
+	is.EqualError(err, `release "nuketown" is not locked`)
+}
+
+func TestUpgradeRelease_AtomicReusesLock(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	locker := &memoryLocker{}
+	upAction.cfg.Locker = locker
+
+	rel := releaseStub()
+	rel.Name = "nuketown"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WatchUntilReadyError = fmt.Errorf("arming key removed")
+	upAction.Atomic = true
+
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "rolled back due to atomic being set")
+	is.Equal([]string{"nuketown"}, locker.locked, "the rollback must not lock the release again")
+	is.Empty(locker.holders)
+}
+
+func TestUpgradeRelease_LockedBySameConfiguration(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	locker := &memoryLocker{}
+	upAction.cfg.Locker = locker
+	upAction.cfg.LockHolder = "server"
+
+	rel := releaseStub()
+	rel.Name = "nuketown"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	// another action on the release through the same configuration
+	_, unlock, err := upAction.cfg.lockRelease(context.Background(), rel.Name, 0)
+	req.NoError(err)
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	is.EqualError(err, `release "nuketown" is locked by "server"`)
+
+	unlock()
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.NoError(err)
+}
+
+func TestLockRelease_Lost(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	cfg := actionConfigFixture(t)
+	locker := &memoryLocker{}
+	cfg.Locker = locker
+
+	ctx, unlock, err := cfg.lockRelease(context.Background(), "nuketown", 0)
+	req.NoError(err)
+	defer unlock()
+	req.NoError(lockLost(ctx))
+
+	locker.lost["nuketown"](errors.New("lock of release \"nuketown\" was removed or taken over"))
+	is.EqualError(lockLost(ctx), `aborting: lock of release "nuketown" was removed or taken over`)
+	is.Error(ctx.Err())
+}
+
+func TestUnlock_NotConfigured(t *testing.T) {
+	_, err := NewUnlock(actionConfigFixture(t)).Run("nuketown")
+	assert.EqualError(t, err, "release locking is not configured")
+}
@@ -0,0 +1,326 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"k8s.io/cli-runtime/pkg/resource"
+
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// RecoverStrategy selects how a release stuck in a pending state is recovered.
+type RecoverStrategy string
+
+const (
+	// RecoverAuto chooses a strategy from the state of the cluster.
+	RecoverAuto RecoverStrategy = "auto"
+	// RecoverMarkFailed marks the pending revision as failed without
+	// touching the cluster.
+	RecoverMarkFailed RecoverStrategy = "mark-failed"
+	// RecoverRollForward applies the pending revision and marks it deployed.
+	RecoverRollForward RecoverStrategy = "roll-forward"
+	// RecoverRestore marks the pending revision as failed and rolls back to
+	// the last deployed revision.
+	RecoverRestore RecoverStrategy = "restore"
+)
+
+// RecoverStrategies lists the valid recover strategies.
+var RecoverStrategies = []RecoverStrategy{RecoverAuto, RecoverMarkFailed, RecoverRollForward, RecoverRestore}
+
+// ResourceState describes a resource of the pending revision in the cluster.
+type ResourceState string
+
+const (
+	// ResourceApplied indicates the live object matches the pending revision.
+	ResourceApplied ResourceState = "applied"
+	// ResourceDiffers indicates the live object differs from the pending revision.
+	ResourceDiffers ResourceState = "differs"
+	// ResourceMissing indicates the resource does not exist in the cluster.
+	ResourceMissing ResourceState = "missing"
+)
+
+// RecoverResource describes the state of a single resource of the pending
+// revision.
+type RecoverResource struct {
+	APIVersion string        `json:"apiVersion"`
+	Kind       string        `json:"kind"`
+	Namespace  string        `json:"namespace,omitempty"`
+	Name       string        `json:"name"`
+	State      ResourceState `json:"state"`
+	// Fields lists the paths of the fields that differ from the pending
+	// revision.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// RecoverReport describes a pending release and how it is recovered.
+type RecoverReport struct {
+	Release   string         `json:"release"`
+	Namespace string         `json:"namespace"`
+	Revision  int            `json:"revision"`
+	Status    release.Status `json:"status"`
+	// LastDeployed is the last deployed revision, or zero if there is none.
+	LastDeployed int               `json:"lastDeployed,omitempty"`
+	Resources    []RecoverResource `json:"resources"`
+	Strategy     RecoverStrategy   `json:"strategy"`
+	Reason       string            `json:"reason"`
+	// Recovered is false for a dry run.
+	Recovered bool `json:"recovered"`
+}
+
+// Applied returns true if every resource of the pending revision matches the
+// live cluster.
+func (r *RecoverReport) Applied() bool {
+	for _, res := range r.Resources {
+		if res.State != ResourceApplied {
+			return false
+		}
+	}
+	return true
+}
+
+// Recover is the action for recovering releases stuck in a pending state,
+// e.g. after the client was interrupted during an upgrade.
+//
+// It provides the implementation of 'helm recover'.
+type Recover struct {
+	cfg *Configuration
+
+	// Strategy selects how the release is recovered. RecoverAuto is used if
+	// it is not set.
+	Strategy    RecoverStrategy
+	DryRun      bool
+	Wait        bool
+	WaitForJobs bool
+	Timeout     time.Duration
+	LockTimeout time.Duration
+}
+
+// NewRecover creates a new Recover object with the given configuration.
+func NewRecover(cfg *Configuration) *Recover {
+	return &Recover{
+		cfg:      cfg,
+		Strategy: RecoverAuto,
+	}
+}
+
+// Run inspects the pending revision of the named release, compares it with the
+// live cluster and recovers the release. With DryRun only the report is
+// returned.
+func (r *Recover) Run(name string) (*RecoverReport, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("recover: Release name is invalid: %s", name)
+	}
+
+	switch r.Strategy {
+	case "", RecoverAuto, RecoverMarkFailed, RecoverRollForward, RecoverRestore:
+	default:
+		return nil, errors.Errorf("invalid recover strategy %q", r.Strategy)
+	}
+
+	ctx := context.Background()
+	if !r.DryRun {
+		var unlock func()
+		var err error
+		ctx, unlock, err = r.cfg.lockRelease(ctx, name, r.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	rel, err := r.cfg.Releases.Last(name)
+	if err != nil {
+		return nil, err
+	}
+	if !rel.Info.Status.IsPending() {
+		return nil, errors.Errorf("release %q is not pending: revision %d is %s", name, rel.Version, rel.Info.Status)
+	}
+
+	deployed, err := r.cfg.Releases.Deployed(name)
+	if err != nil && !strings.Contains(err.Error(), "has no deployed releases") {
+		return nil, err
+	}
+
+	target, err := r.cfg.KubeClient.Build(bytes.NewBufferString(rel.Manifest), false)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to build kubernetes objects from pending release manifest")
+	}
+
+	report := &RecoverReport{
+		Release:   rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+		Status:    rel.Info.Status,
+		Resources: []RecoverResource{},
+	}
+	if deployed != nil {
+		report.LastDeployed = deployed.Version
+	}
+
+	err = target.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		_, live, fields, err := compareLive(info)
+		if err != nil {
+			return err
+		}
+		apiVersion, kind := info.Mapping.GroupVersionKind.ToAPIVersionAndKind()
+		res := RecoverResource{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  info.Namespace,
+			Name:       info.Name,
+			State:      ResourceApplied,
+		}
+		switch {
+		case live == nil:
+			res.State = ResourceMissing
+		case len(fields) > 0:
+			res.State = ResourceDiffers
+			res.Fields = fields
+		}
+		report.Resources = append(report.Resources, res)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.choose(report, deployed); err != nil {
+		return nil, err
+	}
+
+	if r.DryRun {
+		return report, nil
+	}
+	if err := lockLost(ctx); err != nil {
+		return nil, err
+	}
+
+	r.cfg.Log("recovering %s revision %d: %s", name, rel.Version, report.Strategy)
+	switch report.Strategy {
+	case RecoverMarkFailed:
+		rel.SetStatus(release.StatusFailed, fmt.Sprintf("Recovered from %s: marked as failed", report.Status))
+		if err := r.cfg.Releases.Update(rel); err != nil {
+			return nil, err
+		}
+	case RecoverRollForward:
+		if err := r.rollForward(rel, deployed, target); err != nil {
+			return nil, err
+		}
+	case RecoverRestore:
+		rel.SetStatus(release.StatusFailed, fmt.Sprintf("Recovered from %s: restoring revision %d", report.Status, deployed.Version))
+		if err := r.cfg.Releases.Update(rel); err != nil {
+			return nil, err
+		}
+		// The rollback reuses the release lock held by this action.
+		rollback := NewRollback(r.cfg)
+		rollback.locked = true
+		rollback.Version = deployed.Version
+		rollback.Wait = r.Wait
+		rollback.WaitForJobs = r.WaitForJobs
+		rollback.Timeout = r.Timeout
+		if err := rollback.Run(name); err != nil {
+			return nil, errors.Wrapf(err, "unable to restore revision %d", deployed.Version)
+		}
+	}
+	report.Recovered = true
+	return report, nil
+}
+
+// choose sets the strategy of the report and the reason for it.
+func (r *Recover) choose(report *RecoverReport, deployed *release.Release) error {
+	switch r.Strategy {
+	case "", RecoverAuto:
+		switch {
+		case report.Applied():
+			report.Strategy = RecoverRollForward
+			report.Reason = fmt.Sprintf("all resources of revision %d match the cluster", report.Revision)
+		case deployed != nil:
+			report.Strategy = RecoverRestore
+			report.Reason = fmt.Sprintf("resources of revision %d do not match the cluster, revision %d was the last deployed", report.Revision, deployed.Version)
+		default:
+			report.Strategy = RecoverMarkFailed
+			report.Reason = fmt.Sprintf("resources of revision %d do not match the cluster and there is no deployed revision to restore", report.Revision)
+		}
+	case RecoverMarkFailed, RecoverRollForward:
+		report.Strategy = r.Strategy
+		report.Reason = "requested"
+	case RecoverRestore:
+		if deployed == nil {
+			return errors.Errorf("release %q has no deployed revision to restore", report.Release)
+		}
+		report.Strategy = r.Strategy
+		report.Reason = "requested"
+	}
+	return nil
+}
+
+// rollForward applies the resources of the pending revision and marks it as
+// deployed, superseding the previously deployed revisions.
+func (r *Recover) rollForward(rel, deployed *release.Release, target kube.ResourceList) error {
+	var current kube.ResourceList
+	if deployed != nil {
+		var err error
+		current, err = r.cfg.KubeClient.Build(bytes.NewBufferString(deployed.Manifest), false)
+		if err != nil {
+			return errors.Wrap(err, "unable to build kubernetes objects from deployed release manifest")
+		}
+	}
+
+	if err := target.Visit(setMetadataVisitor(rel.Name, rel.Namespace, true)); err != nil {
+		return errors.Wrap(err, "unable to set metadata visitor from pending release")
+	}
+	results, err := updateResources(r.cfg.KubeClient, current, target, false, false, false)
+	if err != nil {
+		return errors.Wrapf(err, "unable to apply revision %d", rel.Version)
+	}
+	r.cfg.emitResult(rel, results)
+
+	if r.Wait {
+		if err := r.cfg.waitForResources(rel, target, r.Timeout, r.WaitForJobs); err != nil {
+			return errors.Wrapf(err, "release %s failed", rel.Name)
+		}
+	}
+
+	previous, err := r.cfg.Releases.DeployedAll(rel.Name)
+	if err != nil && !strings.Contains(err.Error(), "has no deployed releases") {
+		return err
+	}
+	for _, p := range previous {
+		r.cfg.Log("superseding previous deployment %d", p.Version)
+		p.Info.Status = release.StatusSuperseded
+		r.cfg.recordRelease(p)
+	}
+
+	rel.SetStatus(release.StatusDeployed, fmt.Sprintf("Recovered from %s: rolled forward", rel.Info.Status))
+	return r.cfg.Releases.Update(rel)
+}
@@ -0,0 +1,147 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+const recoverManifest = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  key: value\n"
+
+func recoverFixture(t *testing.T, live string, deployed bool) *Configuration {
+	t.Helper()
+	config := actionConfigFixture(t)
+	client := &clusterKubeClient{t: t, live: map[string]string{}}
+	client.Out = io.Discard
+	config.KubeClient = client
+	if live != "" {
+		client.live["spaced/a"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n  namespace: spaced" + driftLiveOwned + live
+	}
+
+	version := 1
+	if deployed {
+		rel := releaseStub()
+		rel.Name = "stuck"
+		rel.Namespace = "spaced"
+		rel.Manifest = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\ndata:\n  key: old\n"
+		require.NoError(t, config.Releases.Create(rel))
+		version++
+	}
+
+	rel := releaseStub()
+	rel.Name = "stuck"
+	rel.Namespace = "spaced"
+	rel.Version = version
+	rel.Info.Status = release.StatusPendingUpgrade
+	if !deployed {
+		rel.Info.Status = release.StatusPendingInstall
+	}
+	rel.Manifest = recoverManifest
+	require.NoError(t, config.Releases.Create(rel))
+	return config
+}
+
+func TestRecover_RollForward(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := recoverFixture(t, "data:\n  key: value\n", true)
+	report, err := NewRecover(config).Run("stuck")
+	req.NoError(err)
+	is.True(report.Recovered)
+	is.Equal(RecoverRollForward, report.Strategy)
+	is.Equal(1, report.LastDeployed)
+	req.Len(report.Resources, 1)
+	is.Equal(ResourceApplied, report.Resources[0].State)
+
+	rel, err := config.Releases.Get("stuck", 2)
+	req.NoError(err)
+	is.Equal(release.StatusDeployed, rel.Info.Status)
+	prev, err := config.Releases.Get("stuck", 1)
+	req.NoError(err)
+	is.Equal(release.StatusSuperseded, prev.Info.Status)
+}
+
+func TestRecover_Restore(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := recoverFixture(t, "data:\n  key: old\n", true)
+	report, err := NewRecover(config).Run("stuck")
+	req.NoError(err)
+	is.Equal(RecoverRestore, report.Strategy)
+	req.Len(report.Resources, 1)
+	is.Equal(ResourceDiffers, report.Resources[0].State)
+	is.Equal([]string{".data.key"}, report.Resources[0].Fields)
+
+	rel, err := config.Releases.Get("stuck", 2)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, rel.Info.Status)
+	last, err := config.Releases.Last("stuck")
+	req.NoError(err)
+	is.Equal(3, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+	is.Equal("Rollback to 1", last.Info.Description)
+}
+
+func TestRecover_DryRun(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := recoverFixture(t, "", false)
+	client := NewRecover(config)
+	client.DryRun = true
+	report, err := client.Run("stuck")
+	req.NoError(err)
+	is.False(report.Recovered)
+	is.Equal(RecoverMarkFailed, report.Strategy)
+	is.Equal(release.StatusPendingInstall, report.Status)
+	req.Len(report.Resources, 1)
+	is.Equal(ResourceMissing, report.Resources[0].State)
+
+	rel, err := config.Releases.Last("stuck")
+	req.NoError(err)
+	is.Equal(release.StatusPendingInstall, rel.Info.Status)
+
+	client.DryRun = false
+	client.Strategy = RecoverRestore
+	_, err = client.Run("stuck")
+	is.EqualError(err, `release "stuck" has no deployed revision to restore`)
+
+	client.Strategy = RecoverMarkFailed
+	_, err = client.Run("stuck")
+	req.NoError(err)
+	rel, err = config.Releases.Last("stuck")
+	req.NoError(err)
+	is.Equal(release.StatusFailed, rel.Info.Status)
+}
+
+func TestRecover_NotPending(t *testing.T) {
+	config := actionConfigFixture(t)
+	rel := releaseStub()
+	rel.Name = "steady"
+	require.NoError(t, config.Releases.Create(rel))
+
+	_, err := NewRecover(config).Run("steady")
+	assert.EqualError(t, err, `release "steady" is not pending: revision 1 is deployed`)
+}
@@ -20,14 +20,18 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"path"
 	"sort"
 	"time"
 
 	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
 
 	"helm.sh/helm/v3/pkg/chartutil"
 	"helm.sh/helm/v3/pkg/release"
+	helmtime "helm.sh/helm/v3/pkg/time"
 )
 
 const (
@@ -43,8 +47,15 @@ type ReleaseTesting struct {
 	Timeout time.Duration
 	// Used for fetching logs from test pods
 	Namespace string
-	Filters   map[string][]string
+	// Filters select the tests to run by name. The names may be shell
+	// patterns, e.g. "db-*".
+	Filters map[string][]string
+	// Selector selects the tests to run by the labels of their manifest.
+	Selector  string
 	HideNotes bool
+	// Parallelism is the maximum number of tests with the same weight run at
+	// the same time. Configuration.HookParallelism is used if it is 0.
+	Parallelism int
 }
 
 // NewReleaseTesting creates a new ReleaseTesting object with the given configuration.
@@ -71,40 +82,154 @@ func (r *ReleaseTesting) Run(name string) (*release.Release, error) {
 		return rel, err
 	}
 
+	selector, err := labels.Parse(r.Selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid test selector %q", r.Selector)
+	}
+
+	// The filters only select test hooks, pre-test and post-test hooks are
+	// always run.
 	skippedHooks := []*release.Hook{}
 	executingHooks := []*release.Hook{}
-	if len(r.Filters[ExcludeNameFilter]) != 0 {
-		for _, h := range rel.Hooks {
-			if contains(r.Filters[ExcludeNameFilter], h.Name) {
-				skippedHooks = append(skippedHooks, h)
-			} else {
-				executingHooks = append(executingHooks, h)
-			}
+	for _, h := range rel.Hooks {
+		if !isTestHook(h) {
+			executingHooks = append(executingHooks, h)
+		} else if r.selects(h, selector) {
+			// Forget the previous run, so that the tests which are not run
+			// this time are reported as skipped.
+			h.LastRun = release.HookExecution{}
+			executingHooks = append(executingHooks, h)
+		} else {
+			skippedHooks = append(skippedHooks, h)
 		}
-		rel.Hooks = executingHooks
-	}
-	if len(r.Filters[IncludeNameFilter]) != 0 {
-		executingHooks = nil
-		for _, h := range rel.Hooks {
-			if contains(r.Filters[IncludeNameFilter], h.Name) {
-				executingHooks = append(executingHooks, h)
-			} else {
-				skippedHooks = append(skippedHooks, h)
-			}
-		}
-		rel.Hooks = executingHooks
 	}
+	rel.Hooks = executingHooks
 
-	if err := r.cfg.execHook(rel, release.HookTest, r.Timeout); err != nil {
-		rel.Hooks = append(skippedHooks, rel.Hooks...)
-		r.cfg.Releases.Update(rel)
-		return rel, err
+	parallelism := r.Parallelism
+	if parallelism == 0 {
+		parallelism = r.cfg.HookParallelism
+	}
+	for _, hook := range []release.HookEvent{release.HookPreTest, release.HookTest, release.HookPostTest} {
+		if err := r.cfg.execHookParallel(rel, hook, r.Timeout, parallelism); err != nil {
+			rel.Hooks = append(skippedHooks, rel.Hooks...)
+			r.cfg.Releases.Update(rel)
+			return rel, err
+		}
 	}
 
 	rel.Hooks = append(skippedHooks, rel.Hooks...)
 	return rel, r.cfg.Releases.Update(rel)
 }
 
+// selects returns whether the test hook h is selected by the filters and the
+// label selector.
+func (r *ReleaseTesting) selects(h *release.Hook, selector labels.Selector) bool {
+	if matchesAny(r.Filters[ExcludeNameFilter], h.Name) {
+		return false
+	}
+	if len(r.Filters[IncludeNameFilter]) > 0 && !matchesAny(r.Filters[IncludeNameFilter], h.Name) {
+		return false
+	}
+	return selector.Empty() || selector.Matches(labels.Set(hookLabels(h)))
+}
+
+// TestStatus is the outcome of a test.
+type TestStatus string
+
+const (
+	// TestPassed indicates that the test succeeded.
+	TestPassed TestStatus = "passed"
+	// TestFailed indicates that the test failed.
+	TestFailed TestStatus = "failed"
+	// TestSkipped indicates that the test was selected but not run, because
+	// an earlier test or a pre-test hook failed.
+	TestSkipped TestStatus = "skipped"
+	// TestUnknown indicates that the outcome of the test is not known.
+	TestUnknown TestStatus = "unknown"
+)
+
+// TestResult is the result of a test of a release.
+type TestResult struct {
+	Name        string        `json:"name"`
+	Status      TestStatus    `json:"status"`
+	StartedAt   helmtime.Time `json:"started_at,omitempty"`
+	CompletedAt helmtime.Time `json:"completed_at,omitempty"`
+	// Duration is the time the test took to run, in seconds.
+	Duration float64 `json:"duration"`
+	// Reason explains why the test failed.
+	Reason string `json:"reason,omitempty"`
+	// Logs are the end of the output of the containers of the test.
+	Logs []release.HookLog `json:"logs,omitempty"`
+}
+
+// TestSuiteResult is the result of the tests of a release.
+type TestSuiteResult struct {
+	Release   string        `json:"release"`
+	Namespace string        `json:"namespace"`
+	Revision  int           `json:"revision"`
+	Tests     []*TestResult `json:"tests"`
+}
+
+// Failed returns the number of failed tests.
+func (s *TestSuiteResult) Failed() int {
+	return s.count(TestFailed)
+}
+
+// Skipped returns the number of skipped tests.
+func (s *TestSuiteResult) Skipped() int {
+	return s.count(TestSkipped)
+}
+
+func (s *TestSuiteResult) count(status TestStatus) int {
+	n := 0
+	for _, t := range s.Tests {
+		if t.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Result returns the results of the tests of rel selected by the filters, in
+// the order they were run. rel is the release returned by Run.
+func (r *ReleaseTesting) Result(rel *release.Release) (*TestSuiteResult, error) {
+	selector, err := labels.Parse(r.Selector)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid test selector %q", r.Selector)
+	}
+
+	result := &TestSuiteResult{Release: rel.Name, Namespace: rel.Namespace, Revision: rel.Version, Tests: []*TestResult{}}
+	hooks := append([]*release.Hook{}, rel.Hooks...)
+	sort.Stable(hookByWeight(hooks))
+	for _, h := range hooks {
+		if !isTestHook(h) || !r.selects(h, selector) {
+			continue
+		}
+		t := &TestResult{
+			Name:        h.Name,
+			StartedAt:   h.LastRun.StartedAt,
+			CompletedAt: h.LastRun.CompletedAt,
+			Reason:      h.LastRun.Message,
+			Logs:        h.LastRun.Logs,
+		}
+		switch h.LastRun.Phase {
+		case release.HookPhaseSucceeded:
+			t.Status = TestPassed
+		case release.HookPhaseFailed:
+			t.Status = TestFailed
+		case "":
+			t.Status = TestSkipped
+		default:
+			t.Status = TestUnknown
+		}
+		if !t.StartedAt.IsZero() && !t.CompletedAt.IsZero() {
+			t.Duration = t.CompletedAt.Sub(t.StartedAt).Seconds()
+		}
+		result.Tests = append(result.Tests, t)
+	}
+	return result, nil
+}
+
 // GetPodLogs will write the logs for all test pods in the given release into
 // the given writer. These can be immediately output to the user or captured for
 // other uses
@@ -113,16 +238,17 @@ func (r *ReleaseTesting) GetPodLogs(out io.Writer, rel *release.Release) error {
 	if err != nil {
 		return errors.Wrap(err, "unable to get kubernetes client to fetch pod logs")
 	}
+	selector, err := labels.Parse(r.Selector)
+	if err != nil {
+		return errors.Wrapf(err, "invalid test selector %q", r.Selector)
+	}
 
 	hooksByWight := append([]*release.Hook{}, rel.Hooks...)
 	sort.Stable(hookByWeight(hooksByWight))
 	for _, h := range hooksByWight {
 		for _, e := range h.Events {
 			if e == release.HookTest {
-				if contains(r.Filters[ExcludeNameFilter], h.Name) {
-					continue
-				}
-				if len(r.Filters[IncludeNameFilter]) > 0 && !contains(r.Filters[IncludeNameFilter], h.Name) {
+				if !r.selects(h, selector) {
 					continue
 				}
 				req := client.CoreV1().Pods(r.Namespace).GetLogs(h.Name, &v1.PodLogOptions{})
@@ -143,9 +269,33 @@ func (r *ReleaseTesting) GetPodLogs(out io.Writer, rel *release.Release) error {
 	return nil
 }
 
-func contains(arr []string, value string) bool {
-	for _, item := range arr {
-		if item == value {
+// isTestHook returns whether h is run by the test event.
+func isTestHook(h *release.Hook) bool {
+	for _, e := range h.Events {
+		if e == release.HookTest {
+			return true
+		}
+	}
+	return false
+}
+
+// hookLabels returns the labels of the manifest of a hook.
+func hookLabels(h *release.Hook) map[string]string {
+	var head struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := yaml.Unmarshal([]byte(h.Manifest), &head); err != nil {
+		return nil
+	}
+	return head.Metadata.Labels
+}
+
+// matchesAny returns whether name matches one of the shell patterns.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, name); ok || (err != nil && pattern == name) {
 			return true
 		}
 	}
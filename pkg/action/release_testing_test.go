@@ -0,0 +1,121 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func TestReleaseTesting_PreAndPostTestHooks(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 1)
+	rel := hookRelease(map[string]int{"a": 0, "b": 0, "c": 0, "d": 0})
+	rel.Hooks[0].Events = []release.HookEvent{release.HookPreTest}
+	rel.Hooks[1].Events = []release.HookEvent{release.HookTest}
+	rel.Hooks[2].Events = []release.HookEvent{release.HookTest}
+	rel.Hooks[3].Events = []release.HookEvent{release.HookPostTest}
+	for _, h := range rel.Hooks {
+		h.DeletePolicies = []release.HookDeletePolicy{release.HookFailed}
+	}
+	req.NoError(config.Releases.Create(rel))
+
+	test := NewReleaseTesting(config)
+	test.Filters = map[string][]string{IncludeNameFilter: {"b"}}
+	res, err := test.Run(rel.Name)
+	req.NoError(err)
+	// The filters only apply to test hooks.
+	is.Equal([]string{"start a", "end a", "start b", "end b", "start d", "end d"}, client.calls)
+	is.Equal(release.HookPhase(""), findHook(res, "c").LastRun.Phase)
+
+	client.calls = nil
+	client.fail = "b"
+	_, err = test.Run(rel.Name)
+	is.EqualError(err, "hook b failed")
+	// Post-test hooks are not run if a test fails.
+	is.Equal([]string{"start a", "end a", "start b", "end b", "delete b"}, client.calls)
+}
+
+func TestReleaseTesting_Result(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 1)
+	client.fail = "b"
+	rel := hookRelease(map[string]int{"a": 0, "b": 1, "c": 2, "d": 0})
+	for _, h := range rel.Hooks {
+		h.Events = []release.HookEvent{release.HookTest}
+		h.DeletePolicies = []release.HookDeletePolicy{release.HookFailed}
+	}
+	rel.Hooks[0].LastRun.Phase = release.HookPhaseFailed
+	req.NoError(config.Releases.Create(rel))
+
+	test := NewReleaseTesting(config)
+	test.Filters = map[string][]string{ExcludeNameFilter: {"d"}}
+	res, err := test.Run(rel.Name)
+	is.EqualError(err, "hook b failed")
+
+	result, err := test.Result(res)
+	req.NoError(err)
+	is.Equal(rel.Name, result.Release)
+	req.Len(result.Tests, 3)
+	is.Equal("a", result.Tests[0].Name)
+	is.Equal(TestPassed, result.Tests[0].Status)
+	is.Equal("b", result.Tests[1].Name)
+	is.Equal(TestFailed, result.Tests[1].Status)
+	is.Equal("hook b failed", result.Tests[1].Reason)
+	is.Greater(result.Tests[1].Duration, 0.0)
+	is.Equal("c", result.Tests[2].Name)
+	is.Equal(TestSkipped, result.Tests[2].Status)
+	is.Equal(1, result.Failed())
+	is.Equal(1, result.Skipped())
+}
+
+func TestReleaseTesting_Selection(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config, client := hookConfig(t, 1)
+	rel := hookRelease(map[string]int{"a": 0, "b": 0, "c": 0, "d": 0})
+	for i, h := range rel.Hooks {
+		h.Name = []string{"db-read", "db-write", "api", "db-slow"}[i]
+		h.Events = []release.HookEvent{release.HookTest}
+		h.DeletePolicies = []release.HookDeletePolicy{release.HookFailed}
+		h.Manifest = "apiVersion: v1\nkind: Pod\nmetadata:\n  name: " + h.Name + "\n  labels:\n    suite: smoke\n"
+	}
+	rel.Hooks[3].Manifest += "    speed: slow\n"
+	req.NoError(config.Releases.Create(rel))
+
+	test := NewReleaseTesting(config)
+	test.Filters = map[string][]string{IncludeNameFilter: {"db-*"}}
+	test.Selector = "suite=smoke,speed!=slow"
+	test.Parallelism = 4
+	_, err := test.Run(rel.Name)
+	req.NoError(err)
+	is.ElementsMatch([]string{"start db-read", "start db-write", "end db-read", "end db-write"}, client.calls)
+	is.Equal(2, client.max)
+
+	test.Selector = "a=b=c"
+	_, err = test.Run(rel.Name)
+	is.ErrorContains(err, `invalid test selector "a=b=c"`)
+}
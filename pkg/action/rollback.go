@@ -18,6 +18,7 @@ package action
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -37,6 +38,7 @@ type Rollback struct {
 
 	Version       int
 	Timeout       time.Duration
+	LockTimeout   time.Duration // how long to wait for the release lock held by another client
 	Wait          bool
 	WaitForJobs   bool
 	DisableHooks  bool
@@ -45,21 +47,75 @@ type Rollback struct {
 	Force         bool // will (if true) force resource upgrade through uninstall/recreate if needed
 	CleanupOnFail bool
 	MaxHistory    int // MaxHistory limits the maximum number of revisions saved per release
+	// ServerSideApply updates resources with server-side apply instead of
+	// client-side three-way merge patches.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field managers
+	// when ServerSideApply is set.
+	ForceConflicts bool
+	// Values are merged over the values of the target revision, whose stored
+	// chart is then rendered again.
+	Values map[string]interface{}
+	// Resources limits the rollback to the resources given as kind/name. The
+	// other resources keep their current state.
+	Resources []string
+	// DiffContext is the number of context lines shown around each change by
+	// Diff.
+	DiffContext int
+
+	// locked is set when the action running the rollback holds the release
+	// lock.
+	locked bool
 }
 
 // NewRollback creates a new Rollback object with the given configuration.
 func NewRollback(cfg *Configuration) *Rollback {
 	return &Rollback{
-		cfg: cfg,
+		cfg:         cfg,
+		DiffContext: 3,
 	}
 }
 
+// Diff previews the changes of a rollback of the named release, relative to
+// both the current release and the live cluster state. Nothing is modified.
+func (r *Rollback) Diff(name string) (*DiffResult, error) {
+	if err := r.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+
+	r.cfg.Log("preparing rollback diff for %s", name)
+	currentRelease, targetRelease, err := r.prepareRollback(name)
+	if err != nil {
+		return nil, err
+	}
+
+	d := NewDiff(r.cfg)
+	d.Namespace = currentRelease.Namespace
+	d.Context = r.DiffContext
+	return d.compare(currentRelease, targetRelease)
+}
+
 // Run executes 'helm rollback' against the given release.
 func (r *Rollback) Run(name string) error {
 	if err := r.cfg.KubeClient.IsReachable(); err != nil {
 		return err
 	}
 
+	if err := validateServerSideApply(r.Force, r.ServerSideApply, r.ForceConflicts); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if !r.DryRun && !r.locked {
+		var unlock func()
+		var err error
+		ctx, unlock, err = r.cfg.lockRelease(ctx, name, r.LockTimeout)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+	}
+
 	r.cfg.Releases.MaxHistory = r.MaxHistory
 
 	r.cfg.Log("preparing rollback of %s", name)
@@ -68,6 +124,9 @@ func (r *Rollback) Run(name string) error {
 		return err
 	}
 
+	if err := lockLost(ctx); err != nil {
+		return err
+	}
 	if !r.DryRun {
 		r.cfg.Log("creating rolled back release for %s", name)
 		if err := r.cfg.Releases.Create(targetRelease); err != nil {
@@ -75,6 +134,9 @@ func (r *Rollback) Run(name string) error {
 		}
 	}
 
+	if err := lockLost(ctx); err != nil {
+		return err
+	}
 	r.cfg.Log("performing rollback of %s", name)
 	if _, err := r.performRollback(currentRelease, targetRelease); err != nil {
 		return err
@@ -135,6 +197,11 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 		return nil, nil, err
 	}
 
+	description := fmt.Sprintf("Rollback to %d", previousVersion)
+	if len(r.Values) > 0 {
+		description = fmt.Sprintf("Rollback to %d with overridden values", previousVersion)
+	}
+
 	// Store a new release object with previous release's configuration
 	targetRelease := &release.Release{
 		Name:      name,
@@ -148,7 +215,7 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 			Notes:         previousRelease.Info.Notes,
 			// Because we lose the reference to previous version elsewhere, we set the
 			// message here, and only override it later if we experience failure.
-			Description: fmt.Sprintf("Rollback to %d", previousVersion),
+			Description: description,
 		},
 		Version:  currentRelease.Version + 1,
 		Labels:   previousRelease.Labels,
@@ -156,9 +223,161 @@ func (r *Rollback) prepareRollback(name string) (*release.Release, *release.Rele
 		Hooks:    previousRelease.Hooks,
 	}
 
+	if len(r.Values) > 0 {
+		if err := r.renderWithValues(targetRelease); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(r.Resources) > 0 {
+		manifest, err := selectResources(currentRelease.Manifest, targetRelease.Manifest, currentRelease.Namespace, r.Resources)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Only the selected resources move back; the chart, the values and
+		// the hooks of the release stay as they are.
+		targetRelease.Chart = currentRelease.Chart
+		targetRelease.Config = currentRelease.Config
+		targetRelease.Labels = currentRelease.Labels
+		targetRelease.Hooks = currentRelease.Hooks
+		targetRelease.Info.Notes = currentRelease.Info.Notes
+		targetRelease.Manifest = manifest
+		targetRelease.Info.Description = fmt.Sprintf("Rollback of %s to %d", strings.Join(r.Resources, ", "), previousVersion)
+	}
+
 	return currentRelease, targetRelease, nil
 }
 
+// renderWithValues merges the rollback values over the values of the target
+// release and renders its chart again.
+func (r *Rollback) renderWithValues(targetRelease *release.Release) error {
+	ch := targetRelease.Chart
+	if ch == nil {
+		return errors.New("the target revision has no stored chart to render")
+	}
+	// Subcharts are not stored with a release, so rendering the chart again
+	// would silently drop their resources. Subcharts vendored without being
+	// declared are only known from the sources of the rendered resources.
+	if ch.Metadata != nil && len(ch.Metadata.Dependencies) > 0 || hasSubchartResources(targetRelease) {
+		return errors.New("cannot override the values of a chart with dependencies: subcharts are not stored with a release")
+	}
+
+	config := chartutil.CoalesceTables(r.Values, targetRelease.Config)
+
+	caps, err := r.cfg.getCapabilities()
+	if err != nil {
+		return err
+	}
+	options := chartutil.ReleaseOptions{
+		Name:      targetRelease.Name,
+		Namespace: targetRelease.Namespace,
+		Revision:  targetRelease.Version,
+		IsUpgrade: true,
+	}
+	valuesToRender, err := chartutil.ToRenderValues(ch, config, options, caps)
+	if err != nil {
+		return err
+	}
+
+	hooks, manifestDoc, notesTxt, err := r.cfg.renderResources(ch, valuesToRender, "", "", false, false, false, nil, !r.DryRun, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	targetRelease.Config = config
+	targetRelease.Hooks = hooks
+	targetRelease.Manifest = manifestDoc.String()
+	targetRelease.Info.Notes = notesTxt
+	return nil
+}
+
+// hasSubchartResources returns whether some resources or hooks of the release
+// were rendered from the templates of a subchart.
+func hasSubchartResources(rel *release.Release) bool {
+	for _, line := range strings.Split(rel.Manifest, "\n") {
+		if source, ok := strings.CutPrefix(line, "# Source: "); ok && isSubchartSource(source) {
+			return true
+		}
+	}
+	for _, h := range rel.Hooks {
+		if isSubchartSource(h.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubchartSource returns whether a template path, like
+// "mychart/charts/sub/templates/service.yaml", is in a subchart.
+func isSubchartSource(source string) bool {
+	_, rest, _ := strings.Cut(strings.TrimSpace(source), "/")
+	return strings.HasPrefix(rest, "charts/")
+}
+
+// selectResources returns the current manifest with the resources matching
+// the kind/name selectors taken from the target manifest. Selected resources
+// missing from the target are removed, and those missing from the current
+// manifest are added.
+func selectResources(current, target, namespace string, selectors []string) (string, error) {
+	for _, sel := range selectors {
+		if kind, name, ok := strings.Cut(sel, "/"); !ok || kind == "" || name == "" {
+			return "", errors.Errorf("invalid resource %q: expected kind/name", sel)
+		}
+	}
+
+	currentDocs, err := manifestDocs(current, namespace)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse current release manifest")
+	}
+	targetDocs, err := manifestDocs(target, namespace)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to parse target release manifest")
+	}
+
+	matched := make(map[string]bool)
+	selected := func(doc manifestDoc) bool {
+		found := false
+		for _, sel := range selectors {
+			kind, name, _ := strings.Cut(sel, "/")
+			if strings.EqualFold(kind, doc.Kind) && name == doc.Name {
+				matched[sel] = true
+				found = true
+			}
+		}
+		return found
+	}
+
+	targetByKey := make(map[string]manifestDoc)
+	for _, doc := range targetDocs {
+		targetByKey[doc.key()] = doc
+	}
+
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, doc := range currentDocs {
+		seen[doc.key()] = true
+		if !selected(doc) {
+			fmt.Fprintf(&b, "---\n%s", doc.Content)
+			continue
+		}
+		if t, ok := targetByKey[doc.key()]; ok {
+			fmt.Fprintf(&b, "---\n%s", t.Content)
+		}
+	}
+	for _, doc := range targetDocs {
+		if !seen[doc.key()] && selected(doc) {
+			fmt.Fprintf(&b, "---\n%s", doc.Content)
+		}
+	}
+
+	for _, sel := range selectors {
+		if !matched[sel] {
+			return "", errors.Errorf("no resource matches %q", sel)
+		}
+	}
+	return b.String(), nil
+}
+
 func (r *Rollback) performRollback(currentRelease, targetRelease *release.Release) (*release.Release, error) {
 	if r.DryRun {
 		r.cfg.Log("dry run for %s", targetRelease.Name)
@@ -174,6 +393,8 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		return targetRelease, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
 	}
 
+	r.cfg.emitPhase(targetRelease, "rollback")
+
 	// pre-rollback hooks
 	if !r.DisableHooks {
 		if err := r.cfg.execHook(targetRelease, release.HookPreRollback, r.Timeout); err != nil {
@@ -188,7 +409,7 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 	if err != nil {
 		return targetRelease, errors.Wrap(err, "unable to set metadata visitor from target release")
 	}
-	results, err := r.cfg.KubeClient.Update(current, target, r.Force)
+	results, err := r.cfg.updateWaves(targetRelease, current, target, r.Force, r.ServerSideApply, r.ForceConflicts, r.Timeout, r.WaitForJobs)
 
 	if err != nil {
 		msg := fmt.Sprintf("Rollback %q failed: %s", targetRelease.Name, err)
@@ -212,6 +433,7 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 		}
 		return targetRelease, err
 	}
+	r.cfg.emitResult(targetRelease, results)
 
 	if r.Recreate {
 		// NOTE: Because this is not critical for a release to succeed, we just
@@ -224,20 +446,11 @@ func (r *Rollback) performRollback(currentRelease, targetRelease *release.Releas
 	}
 
 	if r.Wait {
-		if r.WaitForJobs {
-			if err := r.cfg.KubeClient.WaitWithJobs(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
-		} else {
-			if err := r.cfg.KubeClient.Wait(target, r.Timeout); err != nil {
-				targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
-				r.cfg.recordRelease(currentRelease)
-				r.cfg.recordRelease(targetRelease)
-				return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
-			}
+		if err := r.cfg.waitForResources(targetRelease, target, r.Timeout, r.WaitForJobs); err != nil {
+			targetRelease.SetStatus(release.StatusFailed, fmt.Sprintf("Release %q failed: %s", targetRelease.Name, err.Error()))
+			r.cfg.recordRelease(currentRelease)
+			r.cfg.recordRelease(targetRelease)
+			return targetRelease, errors.Wrapf(err, "release %s failed", targetRelease.Name)
 		}
 	}
 
@@ -0,0 +1,152 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func rollbackConfigMap(name, value string) string {
+	return fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\ndata:\n  value: %q\n", name, value)
+}
+
+// rollbackHistory stores two revisions of a release: the first with the
+// config maps a, b and c at value 1, the second with a and b at value 2.
+func rollbackHistory(t *testing.T, config *Configuration) *release.Release {
+	t.Helper()
+	first := namedReleaseStub("rollme", release.StatusSuperseded)
+	first.Manifest = "---\n" + rollbackConfigMap("a", "1") + "---\n" + rollbackConfigMap("b", "1") + "---\n" + rollbackConfigMap("c", "1")
+	require.NoError(t, config.Releases.Create(first))
+
+	second := namedReleaseStub("rollme", release.StatusDeployed)
+	second.Version = 2
+	second.Config = map[string]interface{}{"name": "second"}
+	second.Manifest = "---\n" + rollbackConfigMap("a", "2") + "---\n" + rollbackConfigMap("b", "2")
+	require.NoError(t, config.Releases.Create(second))
+	return second
+}
+
+func TestRollback_Resources(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	second := rollbackHistory(t, config)
+
+	client := NewRollback(config)
+	client.Version = 1
+	client.Resources = []string{"configmap/a", "ConfigMap/c"}
+	req.NoError(client.Run("rollme"))
+
+	last, err := config.Releases.Last("rollme")
+	req.NoError(err)
+	is.Equal(3, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+	is.Equal("---\n"+rollbackConfigMap("a", "1")+"---\n"+rollbackConfigMap("b", "2")+"---\n"+rollbackConfigMap("c", "1"), last.Manifest)
+	is.Equal(second.Config, last.Config)
+	is.Equal("Rollback of configmap/a, ConfigMap/c to 1", last.Info.Description)
+}
+
+func TestRollback_ResourcesErrors(t *testing.T) {
+	config := actionConfigFixture(t)
+	rollbackHistory(t, config)
+
+	client := NewRollback(config)
+	client.Resources = []string{"ConfigMap"}
+	assert.EqualError(t, client.Run("rollme"), `invalid resource "ConfigMap": expected kind/name`)
+
+	client.Resources = []string{"ConfigMap/a", "Secret/a"}
+	assert.EqualError(t, client.Run("rollme"), `no resource matches "Secret/a"`)
+}
+
+func TestRollback_Values(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	first := namedReleaseStub("rollme", release.StatusSuperseded)
+	first.Chart = buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/greeting.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: greeting\ndata:\n  greeting: {{ .Values.greeting }}\n  name: {{ .Values.name }}\n")},
+		}
+	})
+	first.Config = map[string]interface{}{"greeting": "hi", "name": "first"}
+	require.NoError(t, config.Releases.Create(first))
+	second := namedReleaseStub("rollme", release.StatusDeployed)
+	second.Version = 2
+	require.NoError(t, config.Releases.Create(second))
+
+	client := NewRollback(config)
+	client.Values = map[string]interface{}{"greeting": "hello"}
+	req.NoError(client.Run("rollme"))
+
+	last, err := config.Releases.Last("rollme")
+	req.NoError(err)
+	is.Equal(3, last.Version)
+	is.Equal(map[string]interface{}{"greeting": "hello", "name": "first"}, last.Config)
+	is.Contains(last.Manifest, "greeting: hello\n  name: first\n")
+	is.Equal("Rollback to 1 with overridden values", last.Info.Description)
+
+	// The subcharts of a stored chart are lost, so it cannot be rendered
+	// again.
+	first.Chart.Metadata.Dependencies = []*chart.Dependency{{Name: "sub"}}
+	req.NoError(config.Releases.Update(first))
+	client.Version = 1
+	err = client.Run("rollme")
+	is.EqualError(err, "cannot override the values of a chart with dependencies: subcharts are not stored with a release")
+
+	// Neither can a chart with subcharts vendored in charts/ without being
+	// declared.
+	first.Chart.Metadata.Dependencies = nil
+	first.Manifest += "---\n# Source: hello/charts/sub/templates/configmap.yaml\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: sub\n"
+	req.NoError(config.Releases.Update(first))
+	err = client.Run("rollme")
+	is.EqualError(err, "cannot override the values of a chart with dependencies: subcharts are not stored with a release")
+}
+
+func TestRollback_Diff(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	config := actionConfigFixture(t)
+	rollbackHistory(t, config)
+
+	client := NewRollback(config)
+	client.Resources = []string{"ConfigMap/a", "ConfigMap/c"}
+	result, err := client.Diff("rollme")
+	req.NoError(err)
+	is.Equal(3, result.Revision)
+	is.True(result.Changed())
+
+	changes := make(map[string]ChangeType)
+	for _, r := range result.Resources {
+		changes[r.Name] = r.Manifest.Change
+	}
+	is.Equal(map[string]ChangeType{"a": ChangeChanged, "b": ChangeUnchanged, "c": ChangeAdded}, changes)
+
+	// Nothing was rolled back.
+	last, err := config.Releases.Last("rollme")
+	req.NoError(err)
+	is.Equal(2, last.Version)
+}
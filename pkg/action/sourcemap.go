@@ -0,0 +1,227 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+	goYaml "sigs.k8s.io/yaml/goyaml.v3"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/releaseutil"
+)
+
+var (
+	yamlErrorRegex      = regexp.MustCompile(`^YAML parse error on (\S+): `)
+	yamlLineRegex       = regexp.MustCompile(`yaml: line (\d+):`)
+	validationPathRegex = regexp.MustCompile(`ValidationError\(([^)]*)\): (?:unknown field "([^"]*)")?`)
+)
+
+// locateManifestError points err, a YAML error in the files rendered from ch
+// by e, at the template line that produced it. As source maps are only
+// needed then, the chart is rendered again with them. Errors that cannot be
+// located are returned unchanged.
+func locateManifestError(err error, e engine.Engine, ch *chart.Chart, values chartutil.Values) error {
+	m := yamlErrorRegex.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	name := m[1]
+
+	e.Trace = nil
+	files, sourceMaps, rerr := e.RenderWithSourceMaps(ch, values)
+	if rerr != nil {
+		return err
+	}
+	for _, doc := range documents(files[name]) {
+		var head releaseutil.SimpleHead
+		docErr := yaml.Unmarshal([]byte(doc.content), &head)
+		if docErr == nil {
+			continue
+		}
+		lm := yamlLineRegex.FindStringSubmatch(docErr.Error())
+		if lm == nil {
+			return err
+		}
+		line, _ := strconv.Atoi(lm[1])
+		loc, ok := sourceMaps[name].Lookup(doc.line + line - 1)
+		if !ok {
+			return err
+		}
+		// The position already names the file when the error is in it.
+		msg := err.Error()
+		if loc.File == name {
+			msg = "YAML parse error: " + strings.TrimPrefix(msg, m[0])
+		}
+		return &engine.RenderError{
+			Stage:   engine.StageManifest,
+			File:    loc.File,
+			Line:    loc.Line,
+			Message: msg,
+			Err:     err,
+		}
+	}
+	return err
+}
+
+// locateBuildError points err, an error building the resources rendered from
+// ch like a failed validation, at the template line that produced the
+// invalid resource. The chart is rendered again with source maps, and its
+// resources are built one at a time to find the one failing with err.
+// Errors that cannot be located are returned unchanged.
+func (cfg *Configuration) locateBuildError(err error, ch *chart.Chart, values chartutil.Values, interactWithRemote, enableDNS, validate bool) error {
+	e, eerr := cfg.newEngine(interactWithRemote, enableDNS)
+	if eerr != nil {
+		return err
+	}
+	files, sourceMaps, rerr := e.RenderWithSourceMaps(ch, values)
+	if rerr != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !strings.HasSuffix(name, notesFileSuffix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, doc := range documents(files[name]) {
+			if strings.TrimSpace(doc.content) == "" {
+				continue
+			}
+			// Hooks are not part of the manifest.
+			var head releaseutil.SimpleHead
+			if yaml.Unmarshal([]byte(doc.content), &head) == nil && head.Metadata != nil && head.Metadata.Annotations[release.HookAnnotation] != "" {
+				continue
+			}
+			if _, docErr := cfg.KubeClient.Build(strings.NewReader(doc.content), validate); docErr == nil || docErr.Error() != err.Error() {
+				continue
+			}
+			loc, ok := sourceMaps[name].Lookup(doc.line + fieldLine(doc.content, err) - 1)
+			if !ok {
+				return err
+			}
+			return &engine.RenderError{
+				Stage:   engine.StageManifest,
+				File:    loc.File,
+				Line:    loc.Line,
+				Message: err.Error(),
+				Err:     err,
+			}
+		}
+	}
+	return err
+}
+
+// document is a YAML document of a rendered file.
+type document struct {
+	content string
+	// line is the line of the file the document starts on.
+	line int
+}
+
+// documents splits a rendered file into its YAML documents, in order.
+func documents(content string) []document {
+	docs := releaseutil.SplitManifests(content)
+	keys := make([]string, 0, len(docs))
+	for k := range docs {
+		keys = append(keys, k)
+	}
+	sort.Sort(releaseutil.BySplitManifestsOrder(keys))
+
+	out := make([]document, 0, len(keys))
+	offset := 0
+	for _, k := range keys {
+		doc := docs[k]
+		i := strings.Index(content[offset:], doc)
+		if i < 0 {
+			break
+		}
+		start := offset + i
+		offset = start + len(doc)
+		out = append(out, document{content: doc, line: strings.Count(content[:start], "\n") + 1})
+	}
+	return out
+}
+
+// fieldLine returns the line of doc holding the field a validation error is
+// about, or 1, the start of the document, if it cannot be found.
+func fieldLine(doc string, err error) int {
+	m := validationPathRegex.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 1
+	}
+	// The path starts with the kind, like "Pod.spec.containers[0]".
+	fields := strings.Split(m[1], ".")[1:]
+	if m[2] != "" {
+		fields = append(fields, m[2])
+	}
+
+	var root goYaml.Node
+	if goYaml.Unmarshal([]byte(doc), &root) != nil || len(root.Content) == 0 {
+		return 1
+	}
+	node, line := root.Content[0], 1
+	for _, field := range fields {
+		name, index := field, -1
+		if i := strings.IndexByte(field, '['); i > 0 && strings.HasSuffix(field, "]") {
+			name = field[:i]
+			n, err := strconv.Atoi(field[i+1 : len(field)-1])
+			if err != nil {
+				break
+			}
+			index = n
+		}
+		key, value := mappingEntry(node, name)
+		if key == nil {
+			break
+		}
+		node, line = value, key.Line
+		if index >= 0 {
+			if node.Kind != goYaml.SequenceNode || index >= len(node.Content) {
+				break
+			}
+			node = node.Content[index]
+			line = node.Line
+		}
+	}
+	return line
+}
+
+// mappingEntry returns the key and value nodes of name in node, or nil if
+// node is not a mapping holding name.
+func mappingEntry(node *goYaml.Node, name string) (*goYaml.Node, *goYaml.Node) {
+	if node.Kind != goYaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,153 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// StorageMigrate is the action for copying release records from one storage
+// driver to another.
+//
+// It provides the implementation of 'helm storage migrate'.
+type StorageMigrate struct {
+	cfg *Configuration
+
+	// DeleteSource deletes the records from the source driver once all of
+	// them were copied and verified.
+	DeleteSource bool
+	// DryRun lists the records which would be migrated without copying them.
+	DryRun bool
+}
+
+// NewStorageMigrate creates a new StorageMigrate object with the given
+// configuration.
+func NewStorageMigrate(cfg *Configuration) *StorageMigrate {
+	return &StorageMigrate{
+		cfg: cfg,
+	}
+}
+
+// Run copies every revision of every release stored by from to to and
+// returns the migrated revisions.
+//
+// Each copy is read back and compared with the original, including its custom
+// labels. Revisions already present and identical in to are skipped, so an
+// interrupted migration can be run again.
+func (m *StorageMigrate) Run(from, to driver.Driver) ([]*release.Release, error) {
+	src, dst := storage.Init(from), storage.Init(to)
+
+	list, err := src.ListReleases()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list releases in %s storage", from.Name())
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Version < list[j].Version
+	})
+
+	migrated := make([]*release.Release, 0, len(list))
+	for _, l := range list {
+		// Get returns the custom labels only, which is what Create expects.
+		rel, err := src.Get(l.Name, l.Version)
+		if err != nil {
+			return migrated, errors.Wrapf(err, "unable to get revision %d of release %q", l.Version, l.Name)
+		}
+		if m.DryRun {
+			migrated = append(migrated, rel)
+			continue
+		}
+
+		if existing, err := dst.Get(rel.Name, rel.Version); err == nil {
+			if err := sameRecord(rel, existing); err != nil {
+				return migrated, errors.Wrapf(err, "revision %d of release %q already exists in %s storage", rel.Version, rel.Name, to.Name())
+			}
+			m.cfg.Log("revision %d of release %q already migrated", rel.Version, rel.Name)
+			migrated = append(migrated, rel)
+			continue
+		}
+
+		if err := dst.Create(rel); err != nil {
+			return migrated, errors.Wrapf(err, "unable to store revision %d of release %q in %s storage", rel.Version, rel.Name, to.Name())
+		}
+		copied, err := dst.Get(rel.Name, rel.Version)
+		if err != nil {
+			return migrated, errors.Wrapf(err, "unable to read back revision %d of release %q", rel.Version, rel.Name)
+		}
+		if err := sameRecord(rel, copied); err != nil {
+			return migrated, errors.Wrapf(err, "verification of revision %d of release %q failed", rel.Version, rel.Name)
+		}
+		migrated = append(migrated, rel)
+	}
+
+	if !m.DeleteSource || m.DryRun {
+		return migrated, nil
+	}
+	// Only delete once everything was copied, so a failure leaves the
+	// source complete.
+	for _, rel := range migrated {
+		if _, err := src.Delete(rel.Name, rel.Version); err != nil {
+			return migrated, errors.Wrapf(err, "unable to delete revision %d of release %q from %s storage", rel.Version, rel.Name, from.Name())
+		}
+	}
+	return migrated, nil
+}
+
+// sameRecord returns an error if the two releases differ, ignoring the labels
+// managed by the storage drivers.
+func sameRecord(want, got *release.Release) error {
+	// The labels are not part of the JSON encoding of a release.
+	a, err := json.Marshal(want)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(got)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(a, b) {
+		return errors.New("stored record differs from the original")
+	}
+	if wantLabels, gotLabels := customLabels(want), customLabels(got); !reflect.DeepEqual(wantLabels, gotLabels) {
+		return errors.Errorf("labels of the stored record differ from the original: expected %v, got %v", wantLabels, gotLabels)
+	}
+	return nil
+}
+
+// customLabels returns the labels of the release which are not managed by the
+// storage drivers.
+func customLabels(rel *release.Release) map[string]string {
+	labels := map[string]string{}
+	for k, v := range rel.Labels {
+		labels[k] = v
+	}
+	for _, k := range driver.GetSystemLabels() {
+		delete(labels, k)
+	}
+	return labels
+}
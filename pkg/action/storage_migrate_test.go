@@ -0,0 +1,125 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func TestStorageMigrate(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	from, to := driver.NewMemory(), driver.NewMemory()
+	for _, rel := range []*release.Release{
+		namedReleaseStub("thomas-guide", release.StatusSuperseded),
+		namedReleaseStub("atlas-guide", release.StatusDeployed),
+	} {
+		rel.Labels = map[string]string{"team": "maps"}
+		req.NoError(storage.Init(from).Create(rel))
+	}
+
+	migrate := NewStorageMigrate(actionConfigFixture(t))
+	migrate.DryRun = true
+	migrated, err := migrate.Run(from, to)
+	req.NoError(err)
+	is.Len(migrated, 2)
+	all, err := to.List(func(_ *release.Release) bool { return true })
+	req.NoError(err)
+	is.Empty(all, "dry-run must not copy releases")
+
+	migrate.DryRun = false
+	migrated, err = migrate.Run(from, to)
+	req.NoError(err)
+	req.Len(migrated, 2)
+	is.Equal("atlas-guide", migrated[0].Name)
+	is.Equal("thomas-guide", migrated[1].Name)
+	rel, err := storage.Init(to).Get("thomas-guide", 1)
+	req.NoError(err)
+	is.Equal(map[string]string{"team": "maps"}, rel.Labels)
+	is.Equal(release.StatusSuperseded, rel.Info.Status)
+
+	// migrating again skips the identical copies and deletes the source
+	migrate.DeleteSource = true
+	migrated, err = migrate.Run(from, to)
+	req.NoError(err)
+	is.Len(migrated, 2)
+	all, err = from.List(func(_ *release.Release) bool { return true })
+	req.NoError(err)
+	is.Empty(all)
+	all, err = to.List(func(_ *release.Release) bool { return true })
+	req.NoError(err)
+	is.Len(all, 2)
+}
+
+func TestStorageMigrate_Conflict(t *testing.T) {
+	from, to := driver.NewMemory(), driver.NewMemory()
+	rel := namedReleaseStub("thomas-guide", release.StatusDeployed)
+	require.NoError(t, storage.Init(from).Create(rel))
+	other := namedReleaseStub("thomas-guide", release.StatusFailed)
+	require.NoError(t, storage.Init(to).Create(other))
+
+	migrate := NewStorageMigrate(actionConfigFixture(t))
+	migrate.DeleteSource = true
+	_, err := migrate.Run(from, to)
+	assert.ErrorContains(t, err, `revision 1 of release "thomas-guide" already exists`)
+
+	// the source is kept when the migration fails
+	_, err = storage.Init(from).Get(rel.Name, rel.Version)
+	assert.NoError(t, err)
+}
+
+// labelDroppingDriver is a storage driver which loses the given label.
+type labelDroppingDriver struct {
+	*driver.Memory
+	label string
+}
+
+func (d labelDroppingDriver) Create(key string, rls *release.Release) error {
+	r := *rls
+	r.Labels = map[string]string{}
+	for k, v := range rls.Labels {
+		if k != d.label {
+			r.Labels[k] = v
+		}
+	}
+	return d.Memory.Create(key, &r)
+}
+
+func TestStorageMigrate_LabelsVerified(t *testing.T) {
+	from := driver.NewMemory()
+	rel := namedReleaseStub("thomas-guide", release.StatusDeployed)
+	rel.Labels = map[string]string{"team": "maps", "tier": "gold"}
+	require.NoError(t, storage.Init(from).Create(rel))
+
+	to := labelDroppingDriver{Memory: driver.NewMemory(), label: "tier"}
+	migrate := NewStorageMigrate(actionConfigFixture(t))
+	migrate.DeleteSource = true
+	_, err := migrate.Run(from, to)
+	assert.ErrorContains(t, err, `verification of revision 1 of release "thomas-guide" failed: labels of the stored record differ from the original: expected map[team:maps tier:gold], got map[team:maps]`)
+
+	// the source is kept when the migration fails
+	_, err = storage.Init(from).Get(rel.Name, rel.Version)
+	assert.NoError(t, err)
+}
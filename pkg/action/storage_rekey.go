@@ -0,0 +1,99 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// StorageRekey is the action for re-encrypting the stored release records
+// with the current encryption key.
+//
+// It provides the implementation of 'helm storage rekey'.
+type StorageRekey struct {
+	cfg *Configuration
+}
+
+// NewStorageRekey creates a new StorageRekey object with the given
+// configuration.
+func NewStorageRekey(cfg *Configuration) *StorageRekey {
+	return &StorageRekey{
+		cfg: cfg,
+	}
+}
+
+// Run rewrites every revision of every release, so the storage driver
+// encrypts it with its current key. It returns the rewritten revisions.
+// Records which cannot be read, e.g. as they were encrypted with a key
+// which is no longer configured, are reported in the error once the other
+// revisions were rewritten.
+func (r *StorageRekey) Run() ([]*release.Release, error) {
+	list, readErrs, err := r.releases()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list releases")
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Name != list[j].Name {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].Version < list[j].Version
+	})
+
+	for i, rel := range list {
+		if err := r.cfg.Releases.Update(rel); err != nil {
+			return list[:i], errors.Wrapf(err, "unable to rewrite revision %d of release %q", rel.Version, rel.Name)
+		}
+	}
+	if len(readErrs) > 0 {
+		return list, errors.Errorf("unable to read %d release records: %s", len(readErrs), strings.Join(readErrs, "; "))
+	}
+	return list, nil
+}
+
+// releases returns the stored revisions, and the errors of the records which
+// cannot be read. Drivers which don't encrypt releases don't list their
+// records, but then all of them can be read.
+func (r *StorageRekey) releases() ([]*release.Release, []string, error) {
+	lister, ok := r.cfg.Releases.Driver.(driver.KeyLister)
+	if !ok {
+		list, err := r.cfg.Releases.ListReleases()
+		return list, nil, err
+	}
+
+	keys, err := lister.Keys()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(keys)
+	var list []*release.Release
+	var readErrs []string
+	for _, key := range keys {
+		rel, err := r.cfg.Releases.Driver.Get(key)
+		if err != nil {
+			readErrs = append(readErrs, err.Error())
+			continue
+		}
+		list = append(list, rel)
+	}
+	return list, readErrs, nil
+}
@@ -0,0 +1,68 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// keyListingDriver is a storage driver listing the keys of its records,
+// some of which cannot be read.
+type keyListingDriver struct {
+	*driver.Memory
+	keys       []string
+	unreadable map[string]bool
+}
+
+func (d keyListingDriver) Keys() ([]string, error) {
+	return d.keys, nil
+}
+
+func (d keyListingDriver) Get(key string) (*release.Release, error) {
+	if d.unreadable[key] {
+		return nil, fmt.Errorf("get: failed to decode data %q: unknown encryption key", key)
+	}
+	return d.Memory.Get(key)
+}
+
+func TestStorageRekey_UnreadableRecords(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	d := keyListingDriver{
+		Memory:     driver.NewMemory(),
+		keys:       []string{"sh.helm.release.v1.thomas-guide.v1", "sh.helm.release.v1.lost.v1"},
+		unreadable: map[string]bool{"sh.helm.release.v1.lost.v1": true},
+	}
+	cfg := actionConfigFixture(t)
+	cfg.Releases = storage.Init(d)
+	req.NoError(cfg.Releases.Create(namedReleaseStub("thomas-guide", release.StatusDeployed)))
+
+	rekeyed, err := NewStorageRekey(cfg).Run()
+	// The readable records are rewritten, and the others reported.
+	req.Len(rekeyed, 1)
+	is.Equal("thomas-guide", rekeyed[0].Name)
+	is.EqualError(err, `unable to read 1 release records: get: failed to decode data "sh.helm.release.v1.lost.v1": unknown encryption key`)
+}
@@ -17,6 +17,7 @@ limitations under the License.
 package action
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -44,7 +45,12 @@ type Uninstall struct {
 	Wait                bool
 	DeletionPropagation string
 	Timeout             time.Duration
+	LockTimeout         time.Duration
 	Description         string
+
+	// locked is set when the action running the uninstall holds the release
+	// lock.
+	locked bool
 }
 
 // NewUninstall creates a new Uninstall object with the given configuration.
@@ -73,6 +79,17 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 		return nil, errors.Errorf("uninstall: Release name is invalid: %s", name)
 	}
 
+	ctx := context.Background()
+	if !u.locked {
+		var unlock func()
+		var err error
+		ctx, unlock, err = u.cfg.lockRelease(ctx, name, u.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	rels, err := u.cfg.Releases.History(name)
 	if err != nil {
 		if u.IgnoreNotFound {
@@ -104,7 +121,11 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 	rel.Info.Deleted = helmtime.Now()
 	rel.Info.Description = "Deletion in progress (or silently failed)"
 	res := &release.UninstallReleaseResponse{Release: rel}
+	u.cfg.emitPhase(rel, "uninstall")
 
+	if err := lockLost(ctx); err != nil {
+		return nil, err
+	}
 	if !u.DisableHooks {
 		if err := u.cfg.execHook(rel, release.HookPreDelete, u.Timeout); err != nil {
 			return res, err
@@ -119,11 +140,15 @@ func (u *Uninstall) Run(name string) (*release.UninstallReleaseResponse, error)
 		u.cfg.Log("uninstall: Failed to store updated release: %s", err)
 	}
 
+	if err := lockLost(ctx); err != nil {
+		return res, err
+	}
 	deletedResources, kept, errs := u.deleteRelease(rel)
 	if errs != nil {
 		u.cfg.Log("uninstall: Failed to delete release: %s", errs)
 		return nil, errors.Errorf("failed to delete release: %s", name)
 	}
+	u.cfg.emitResources(rel, EventResourceDeleted, deletedResources)
 
 	if kept != "" {
 		kept = "These resources were kept due to the resource policy:\n" + kept
@@ -222,14 +247,39 @@ func (u *Uninstall) deleteRelease(rel *release.Release) (kube.ResourceList, stri
 	if err != nil {
 		return nil, "", []error{errors.Wrap(err, "unable to build kubernetes objects for delete")}
 	}
-	if len(resources) > 0 {
-		if kubeClient, ok := u.cfg.KubeClient.(kube.InterfaceDeletionPropagation); ok {
-			_, errs = kubeClient.DeleteWithPropagationPolicy(resources, parseCascadingFlag(u.cfg, u.DeletionPropagation))
+
+	// Deploy waves are deleted in reverse order, each one after the previous
+	// one is gone.
+	waves, err := kube.DeployWaves(resources)
+	if err != nil {
+		return nil, "", []error{err}
+	}
+	for i := len(waves) - 1; i >= 0; i-- {
+		if errs = u.deleteResources(waves[i]); errs != nil {
 			return resources, kept, errs
 		}
-		_, errs = u.cfg.KubeClient.Delete(resources)
+		if i == 0 {
+			break
+		}
+		if kubeClient, ok := u.cfg.KubeClient.(kube.InterfaceExt); ok {
+			if err := kubeClient.WaitForDelete(waves[i], u.Timeout); err != nil {
+				return resources, kept, []error{errors.Wrapf(err, "deploy wave %d of %d was not deleted", i+1, len(waves))}
+			}
+		}
+	}
+	return resources, kept, nil
+}
+
+func (u *Uninstall) deleteResources(resources kube.ResourceList) []error {
+	if len(resources) == 0 {
+		return nil
+	}
+	if kubeClient, ok := u.cfg.KubeClient.(kube.InterfaceDeletionPropagation); ok {
+		_, errs := kubeClient.DeleteWithPropagationPolicy(resources, parseCascadingFlag(u.cfg, u.DeletionPropagation))
+		return errs
 	}
-	return resources, kept, errs
+	_, errs := u.cfg.KubeClient.Delete(resources)
+	return errs
 }
 
 func parseCascadingFlag(cfg *Configuration, cascadingFlag string) v1.DeletionPropagation {
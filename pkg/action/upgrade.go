@@ -64,6 +64,8 @@ type Upgrade struct {
 	SkipCRDs bool
 	// Timeout is the timeout for this operation
 	Timeout time.Duration
+	// LockTimeout is how long to wait for the release lock held by another client.
+	LockTimeout time.Duration
 	// Wait determines whether the wait operation should be performed after the upgrade is requested.
 	Wait bool
 	// WaitForJobs determines whether the wait operation for the Jobs should be performed after the upgrade is requested.
@@ -119,6 +121,23 @@ type Upgrade struct {
 	EnableDNS bool
 	// TakeOwnership will skip the check for helm annotations and adopt all existing resources.
 	TakeOwnership bool
+	// ServerSideApply updates resources with server-side apply instead of
+	// client-side three-way merge patches.
+	ServerSideApply bool
+	// ForceConflicts takes ownership of fields managed by other field managers
+	// when ServerSideApply is set.
+	ForceConflicts bool
+	// Strategy is how the changes are applied. UpgradeAll is used if it is
+	// empty.
+	Strategy UpgradeStrategy
+	// CanaryBakeTime is how long the canary resources must stay ready before
+	// the rest of the resources are upgraded.
+	CanaryBakeTime time.Duration
+	// CanaryAnalysis is an optional command run once the canary resources
+	// have baked. The upgrade is rolled back if it fails.
+	CanaryAnalysis string
+	// CanaryAnalysisArgs are the arguments of the CanaryAnalysis command.
+	CanaryAnalysisArgs []string
 }
 
 type resultMessage struct {
@@ -161,6 +180,24 @@ func (u *Upgrade) RunWithContext(ctx context.Context, name string, chart *chart.
 		return nil, errors.Errorf("release name is invalid: %s", name)
 	}
 
+	if err := validateServerSideApply(u.Force, u.ServerSideApply, u.ForceConflicts); err != nil {
+		return nil, err
+	}
+
+	if err := validateUpgradeStrategy(u.Strategy); err != nil {
+		return nil, err
+	}
+
+	if !u.isDryRun() {
+		var unlock func()
+		var err error
+		ctx, unlock, err = u.cfg.lockRelease(ctx, name, u.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	u.cfg.Log("preparing upgrade for %s", name)
 	currentRelease, upgradedRelease, err := u.prepareUpgrade(name, chart, vals)
 	if err != nil {
@@ -273,7 +310,7 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 		interactWithRemote = true
 	}
 
-	hooks, manifestDoc, notesTxt, err := u.cfg.renderResources(chart, valuesToRender, "", "", u.SubNotes, false, false, u.PostRenderer, interactWithRemote, u.EnableDNS, u.HideSecret)
+	hooks, manifestDoc, notesTxt, err := u.cfg.renderResources(chart, valuesToRender, "", "", u.SubNotes, false, false, u.PostRenderer, interactWithRemote, u.EnableDNS, u.HideSecret, nil)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -304,32 +341,88 @@ func (u *Upgrade) prepareUpgrade(name string, chart *chart.Chart, vals map[strin
 		upgradedRelease.Info.Notes = notesTxt
 	}
 	err = validateManifest(u.cfg.KubeClient, manifestDoc.Bytes(), !u.DisableOpenAPIValidation)
+	if err != nil && u.PostRenderer == nil {
+		err = u.cfg.locateBuildError(err, chart, valuesToRender, interactWithRemote, u.EnableDNS, !u.DisableOpenAPIValidation)
+	}
 	return currentRelease, upgradedRelease, err
 }
 
 func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedRelease *release.Release) (*release.Release, error) {
-	current, err := u.cfg.KubeClient.Build(bytes.NewBufferString(originalRelease.Manifest), false)
+	current, target, err := u.upgradeResources(originalRelease, upgradedRelease)
+	if err != nil {
+		return upgradedRelease, err
+	}
+	adopted, err := u.adoptResources(current, target, upgradedRelease)
+	if err != nil {
+		return nil, err
+	}
+	current = append(current, adopted...)
+
+	// Run if it is a dry run
+	if u.isDryRun() {
+		u.cfg.Log("dry run for %s", upgradedRelease.Name)
+		if len(u.Description) > 0 {
+			upgradedRelease.Info.Description = u.Description
+		} else {
+			upgradedRelease.Info.Description = "Dry run complete"
+		}
+		return upgradedRelease, nil
+	}
+
+	u.cfg.Log("creating upgraded release for %s", upgradedRelease.Name)
+	if err := u.cfg.Releases.Create(upgradedRelease); err != nil {
+		return nil, err
+	}
+	rChan := make(chan resultMessage)
+	ctxChan := make(chan resultMessage)
+	doneChan := make(chan interface{})
+	defer close(doneChan)
+	go u.releasingUpgrade(ctx, rChan, upgradedRelease, current, target, originalRelease)
+	go u.handleContext(ctx, doneChan, ctxChan, upgradedRelease)
+	select {
+	case result := <-rChan:
+		return result.r, result.e
+	case result := <-ctxChan:
+		return result.r, result.e
+	}
+}
+
+// upgradeResources builds the resources of the current and the upgraded
+// release.
+func (u *Upgrade) upgradeResources(originalRelease, upgradedRelease *release.Release) (current, target kube.ResourceList, err error) {
+	current, err = u.cfg.KubeClient.Build(bytes.NewBufferString(originalRelease.Manifest), false)
 	if err != nil {
 		// Checking for removed Kubernetes API error so can provide a more informative error message to the user
 		// Ref: https://github.com/helm/helm/issues/7219
 		if strings.Contains(err.Error(), "unable to recognize \"\": no matches for kind") {
-			return upgradedRelease, errors.Wrap(err, "current release manifest contains removed kubernetes api(s) for this "+
+			return nil, nil, errors.Wrap(err, "current release manifest contains removed kubernetes api(s) for this "+
 				"kubernetes version and it is therefore unable to build the kubernetes "+
 				"objects for performing the diff. error from kubernetes")
 		}
-		return upgradedRelease, errors.Wrap(err, "unable to build kubernetes objects from current release manifest")
+		return nil, nil, errors.Wrap(err, "unable to build kubernetes objects from current release manifest")
 	}
-	target, err := u.cfg.KubeClient.Build(bytes.NewBufferString(upgradedRelease.Manifest), !u.DisableOpenAPIValidation)
+	target, err = u.cfg.KubeClient.Build(bytes.NewBufferString(upgradedRelease.Manifest), !u.DisableOpenAPIValidation)
 	if err != nil {
-		return upgradedRelease, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
+		return nil, nil, errors.Wrap(err, "unable to build kubernetes objects from new release manifest")
 	}
 
 	// It is safe to use force only on target because these are resources currently rendered by the chart.
 	err = target.Visit(setMetadataVisitor(upgradedRelease.Name, upgradedRelease.Namespace, true))
 	if err != nil {
-		return upgradedRelease, err
+		return nil, nil, err
+	}
+
+	if u.Strategy == UpgradeCanary && len(canaryResources(target)) == 0 {
+		return nil, nil, errors.Errorf("the canary strategy requires resources annotated with %s", CanaryAnnotation)
 	}
 
+	return current, target, nil
+}
+
+// adoptResources returns the existing resources that the upgrade from current
+// to target takes over. It fails if they are not owned by the release and
+// TakeOwnership is not set.
+func (u *Upgrade) adoptResources(current, target kube.ResourceList, rel *release.Release) (kube.ResourceList, error) {
 	// Do a basic diff using gvk + name to figure out what new resources are being created so we can validate they don't already exist
 	existingResources := make(map[string]bool)
 	for _, r := range current {
@@ -343,51 +436,17 @@ func (u *Upgrade) performUpgrade(ctx context.Context, originalRelease, upgradedR
 		}
 	}
 
-	var toBeUpdated kube.ResourceList
+	var adopted kube.ResourceList
+	var err error
 	if u.TakeOwnership {
-		toBeUpdated, err = requireAdoption(toBeCreated)
+		adopted, err = requireAdoption(toBeCreated)
 	} else {
-		toBeUpdated, err = existingResourceConflict(toBeCreated, upgradedRelease.Name, upgradedRelease.Namespace)
+		adopted, err = existingResourceConflict(toBeCreated, rel.Name, rel.Namespace)
 	}
 	if err != nil {
 		return nil, errors.Wrap(err, "Unable to continue with update")
 	}
-
-	toBeUpdated.Visit(func(r *resource.Info, err error) error {
-		if err != nil {
-			return err
-		}
-		current.Append(r)
-		return nil
-	})
-
-	// Run if it is a dry run
-	if u.isDryRun() {
-		u.cfg.Log("dry run for %s", upgradedRelease.Name)
-		if len(u.Description) > 0 {
-			upgradedRelease.Info.Description = u.Description
-		} else {
-			upgradedRelease.Info.Description = "Dry run complete"
-		}
-		return upgradedRelease, nil
-	}
-
-	u.cfg.Log("creating upgraded release for %s", upgradedRelease.Name)
-	if err := u.cfg.Releases.Create(upgradedRelease); err != nil {
-		return nil, err
-	}
-	rChan := make(chan resultMessage)
-	ctxChan := make(chan resultMessage)
-	doneChan := make(chan interface{})
-	defer close(doneChan)
-	go u.releasingUpgrade(rChan, upgradedRelease, current, target, originalRelease)
-	go u.handleContext(ctx, doneChan, ctxChan, upgradedRelease)
-	select {
-	case result := <-rChan:
-		return result.r, result.e
-	case result := <-ctxChan:
-		return result.r, result.e
-	}
+	return adopted, nil
 }
 
 // Function used to lock the Mutex, this is important for the case when the atomic flag is set.
@@ -406,7 +465,7 @@ func (u *Upgrade) reportToPerformUpgrade(c chan<- resultMessage, rel *release.Re
 func (u *Upgrade) handleContext(ctx context.Context, done chan interface{}, c chan<- resultMessage, upgradedRelease *release.Release) {
 	select {
 	case <-ctx.Done():
-		err := ctx.Err()
+		err := context.Cause(ctx)
 
 		// when the atomic flag is set the ongoing release finish first and doesn't give time for the rollback happens.
 		u.reportToPerformUpgrade(c, upgradedRelease, kube.ResourceList{}, err)
@@ -414,7 +473,9 @@ func (u *Upgrade) handleContext(ctx context.Context, done chan interface{}, c ch
 		return
 	}
 }
-func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *release.Release, current kube.ResourceList, target kube.ResourceList, originalRelease *release.Release) {
+func (u *Upgrade) releasingUpgrade(ctx context.Context, c chan<- resultMessage, upgradedRelease *release.Release, current kube.ResourceList, target kube.ResourceList, originalRelease *release.Release) {
+	u.cfg.emitPhase(upgradedRelease, "upgrade")
+
 	// pre-upgrade hooks
 
 	if !u.DisableHooks {
@@ -426,12 +487,31 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 		u.cfg.Log("upgrade hooks disabled for %s", upgradedRelease.Name)
 	}
 
-	results, err := u.cfg.KubeClient.Update(current, target, u.Force)
+	var created kube.ResourceList
+	if u.Strategy == UpgradeCanary {
+		canaryCreated, err := u.upgradeCanary(ctx, upgradedRelease, current, canaryResources(target))
+		if err != nil {
+			u.cfg.recordRelease(originalRelease)
+			u.reportToPerformUpgrade(c, upgradedRelease, canaryCreated, errors.Wrap(err, "canary failed"))
+			return
+		}
+		// The resources created by the canary exist now, so the rest of the
+		// upgrade updates them from their canary state. They are cleaned up
+		// on failure all the same.
+		current = append(current, canaryCreated...)
+		created = canaryCreated
+	}
+
+	results, err := u.cfg.updateWaves(upgradedRelease, current, target, u.Force, u.ServerSideApply, u.ForceConflicts, u.Timeout, u.WaitForJobs)
+	if results != nil {
+		created = append(created, results.Created...)
+	}
 	if err != nil {
 		u.cfg.recordRelease(originalRelease)
-		u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
+		u.reportToPerformUpgrade(c, upgradedRelease, created, err)
 		return
 	}
+	u.cfg.emitResult(upgradedRelease, results)
 
 	if u.Recreate {
 		// NOTE: Because this is not critical for a release to succeed, we just
@@ -447,25 +527,17 @@ func (u *Upgrade) releasingUpgrade(c chan<- resultMessage, upgradedRelease *rele
 		u.cfg.Log(
 			"waiting for release %s resources (created: %d updated: %d  deleted: %d)",
 			upgradedRelease.Name, len(results.Created), len(results.Updated), len(results.Deleted))
-		if u.WaitForJobs {
-			if err := u.cfg.KubeClient.WaitWithJobs(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
-				return
-			}
-		} else {
-			if err := u.cfg.KubeClient.Wait(target, u.Timeout); err != nil {
-				u.cfg.recordRelease(originalRelease)
-				u.reportToPerformUpgrade(c, upgradedRelease, results.Created, err)
-				return
-			}
+		if err := u.cfg.waitForResources(upgradedRelease, target, u.Timeout, u.WaitForJobs); err != nil {
+			u.cfg.recordRelease(originalRelease)
+			u.reportToPerformUpgrade(c, upgradedRelease, created, err)
+			return
 		}
 	}
 
 	// post-upgrade hooks
 	if !u.DisableHooks {
 		if err := u.cfg.execHook(upgradedRelease, release.HookPostUpgrade, u.Timeout); err != nil {
-			u.reportToPerformUpgrade(c, upgradedRelease, results.Created, fmt.Errorf("post-upgrade hooks failed: %s", err))
+			u.reportToPerformUpgrade(c, upgradedRelease, created, fmt.Errorf("post-upgrade hooks failed: %s", err))
 			return
 		}
 	}
@@ -488,6 +560,9 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 
 	rel.Info.Status = release.StatusFailed
 	rel.Info.Description = msg
+	if !u.DisableHooks {
+		u.cfg.execFailureHooks(rel, u.Timeout)
+	}
 	u.cfg.recordRelease(rel)
 	if u.CleanupOnFail && len(created) > 0 {
 		u.cfg.Log("Cleanup on fail set, cleaning up %d resources", len(created))
@@ -501,8 +576,8 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		}
 		u.cfg.Log("Resource cleanup complete")
 	}
-	if u.Atomic {
-		u.cfg.Log("Upgrade failed and atomic is set, rolling back to last successful release")
+	if u.Atomic || u.Strategy == UpgradeCanary {
+		u.cfg.Log("Upgrade failed and atomic is set or the strategy is %s, rolling back to last successful release", UpgradeCanary)
 
 		// As a protection, get the last successful release before rollback.
 		// If there are no successful releases, bail out
@@ -525,16 +600,22 @@ func (u *Upgrade) failRelease(rel *release.Release, created kube.ResourceList, e
 		releaseutil.Reverse(filteredHistory, releaseutil.SortByRevision)
 
 		rollin := NewRollback(u.cfg)
+		rollin.locked = true
 		rollin.Version = filteredHistory[0].Version
 		rollin.Wait = true
 		rollin.WaitForJobs = u.WaitForJobs
 		rollin.DisableHooks = u.DisableHooks
 		rollin.Recreate = u.Recreate
 		rollin.Force = u.Force
+		rollin.ServerSideApply = u.ServerSideApply
+		rollin.ForceConflicts = u.ForceConflicts
 		rollin.Timeout = u.Timeout
 		if rollErr := rollin.Run(rel.Name); rollErr != nil {
 			return rel, errors.Wrapf(rollErr, "an error occurred while rolling back the release. original upgrade error: %s", err)
 		}
+		if !u.Atomic {
+			return rel, errors.Wrapf(err, "release %s failed, and has been rolled back due to the %s strategy", rel.Name, UpgradeCanary)
+		}
 		return rel, errors.Wrapf(err, "release %s failed, and has been rolled back due to atomic being set", rel.Name)
 	}
 
@@ -594,6 +675,32 @@ func validateManifest(c kube.Interface, manifest []byte, openAPIValidation bool)
 	return err
 }
 
+// validateServerSideApply checks that the server-side apply options of an
+// action are consistent.
+func validateServerSideApply(force, serverSide, forceConflicts bool) error {
+	if serverSide && force {
+		return errors.New("force replacement cannot be used with server-side apply, use force conflicts instead")
+	}
+	if forceConflicts && !serverSide {
+		return errors.New("force conflicts requires server-side apply")
+	}
+	return nil
+}
+
+// updateResources updates the resources from current to target, either with
+// client-side three-way merge patches or, if requested, with server-side
+// apply.
+func updateResources(c kube.Interface, current, target kube.ResourceList, force, serverSide, forceConflicts bool) (*kube.Result, error) {
+	if !serverSide {
+		return c.Update(current, target, force)
+	}
+	ssa, ok := c.(kube.InterfaceServerSideApply)
+	if !ok {
+		return &kube.Result{}, errors.New("unable to get kubeClient with interface InterfaceServerSideApply")
+	}
+	return ssa.UpdateServerSide(current, target, forceConflicts)
+}
+
 // recreate captures all the logic for recreating pods for both upgrade and
 // rollback. If we end up refactoring rollback to use upgrade, this can just be
 // made an unexported method on the upgrade action.
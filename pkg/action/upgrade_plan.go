@@ -0,0 +1,399 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// UpgradePlan holds the changes an upgrade makes to a release and to the
+// cluster. It is made by Upgrade.Plan and executed by Upgrade.ApplyPlan.
+//
+// A plan is applied as it was made, so its patches and the upgraded release
+// hold the data of Secrets as is.
+type UpgradePlan struct {
+	Release   string `json:"release"`
+	Namespace string `json:"namespace"`
+	// FromRevision is the revision of the release that is upgraded.
+	FromRevision int `json:"fromRevision"`
+	// Revision is the revision created by the upgrade.
+	Revision int `json:"revision"`
+	// Force, ServerSideApply and DisableHooks are the options the plan was
+	// made with. They are used when the plan is applied.
+	Force           bool `json:"force,omitempty"`
+	ServerSideApply bool `json:"serverSideApply,omitempty"`
+	DisableHooks    bool `json:"disableHooks,omitempty"`
+	// Resources are the changes to the resources of the release.
+	Resources []PlannedResource `json:"resources"`
+	// Hooks are the hooks run by the upgrade, in order.
+	Hooks []PlannedHook `json:"hooks,omitempty"`
+	// CRDs are the CustomResourceDefinitions that differ from the ones of
+	// the upgraded revision.
+	CRDs []PlannedCRD `json:"crds,omitempty"`
+	// Upgrade is the release recorded when the plan is applied.
+	Upgrade *release.Release `json:"upgrade"`
+}
+
+// PlannedResource is the change of an upgrade to a single resource.
+type PlannedResource struct {
+	kube.PlannedChange
+	// Adopted is true for existing resources that the release takes over.
+	Adopted bool `json:"adopted,omitempty"`
+}
+
+// PlannedHook is a hook run by an upgrade.
+type PlannedHook struct {
+	Name   string              `json:"name"`
+	Kind   string              `json:"kind"`
+	Events []release.HookEvent `json:"events"`
+	Weight int                 `json:"weight"`
+}
+
+// PlannedCRD is a CustomResourceDefinition changed by the chart.
+type PlannedCRD struct {
+	Name   string     `json:"name"`
+	Change ChangeType `json:"change"`
+	// Applied is true for the CRDs rendered by templates. The CRDs of the
+	// crds/ directory of a chart are not changed by upgrades.
+	Applied bool `json:"applied"`
+}
+
+// Changed returns true if applying the plan changes any resource.
+func (p *UpgradePlan) Changed() bool {
+	for _, r := range p.Resources {
+		if r.Action != kube.PlanNone {
+			return true
+		}
+	}
+	return false
+}
+
+// Plan computes the changes the upgrade of the named release would make,
+// without modifying the release or the cluster.
+func (u *Upgrade) Plan(name string, chart *chart.Chart, vals map[string]interface{}) (*UpgradePlan, error) {
+	if err := u.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	if err := chartutil.ValidateReleaseName(name); err != nil {
+		return nil, errors.Errorf("release name is invalid: %s", name)
+	}
+	if err := validateServerSideApply(u.Force, u.ServerSideApply, u.ForceConflicts); err != nil {
+		return nil, err
+	}
+	if err := validateUpgradeStrategy(u.Strategy); err != nil {
+		return nil, err
+	}
+
+	u.cfg.Log("preparing upgrade plan for %s", name)
+	currentRelease, upgradedRelease, err := u.prepareUpgrade(name, chart, vals)
+	if err != nil {
+		return nil, err
+	}
+	resources, err := u.planResources(currentRelease, upgradedRelease)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &UpgradePlan{
+		Release:         upgradedRelease.Name,
+		Namespace:       upgradedRelease.Namespace,
+		FromRevision:    currentRelease.Version,
+		Revision:        upgradedRelease.Version,
+		Force:           u.Force,
+		ServerSideApply: u.ServerSideApply,
+		DisableHooks:    u.DisableHooks,
+		Resources:       resources,
+		CRDs:            plannedCRDs(currentRelease.Chart, upgradedRelease.Chart, resources),
+		Upgrade:         upgradedRelease,
+	}
+	if !u.DisableHooks {
+		plan.Hooks = plannedHooks(upgradedRelease, release.HookPreUpgrade, release.HookPostUpgrade)
+	}
+	return plan, nil
+}
+
+// ApplyPlan executes a plan made by Plan. It fails without changing anything
+// if the release or its resources in the cluster changed since the plan was
+// made.
+func (u *Upgrade) ApplyPlan(ctx context.Context, plan *UpgradePlan) (*release.Release, error) {
+	if err := u.cfg.KubeClient.IsReachable(); err != nil {
+		return nil, err
+	}
+	if plan.Upgrade == nil || plan.Upgrade.Name != plan.Release || plan.Upgrade.Version != plan.Revision {
+		return nil, errors.New("invalid upgrade plan: it does not hold the upgraded release")
+	}
+
+	u.Wait = u.Wait || u.Atomic
+	u.Force = plan.Force
+	u.ServerSideApply = plan.ServerSideApply
+	u.DisableHooks = plan.DisableHooks
+	if err := validateServerSideApply(u.Force, u.ServerSideApply, u.ForceConflicts); err != nil {
+		return nil, err
+	}
+	if err := validateUpgradeStrategy(u.Strategy); err != nil {
+		return nil, err
+	}
+
+	if !u.isDryRun() {
+		var unlock func()
+		var err error
+		ctx, unlock, err = u.cfg.lockRelease(ctx, plan.Release, u.LockTimeout)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	lastRelease, err := u.cfg.Releases.Last(plan.Release)
+	if err != nil {
+		return nil, err
+	}
+	if lastRelease.Version != plan.Revision-1 {
+		return nil, errors.Errorf("release %s changed since the plan was made: the plan was made at revision %d, the last revision is now %d", plan.Release, plan.Revision-1, lastRelease.Version)
+	}
+	if lastRelease.Info.Status.IsPending() {
+		return nil, errPending
+	}
+	currentRelease, err := u.cfg.Releases.Get(plan.Release, plan.FromRevision)
+	if err != nil {
+		return nil, err
+	}
+
+	u.cfg.Log("checking upgrade plan for %s", plan.Release)
+	resources, err := u.planResources(currentRelease, plan.Upgrade)
+	if err != nil {
+		return nil, err
+	}
+	if changes := planDifferences(plan.Resources, resources); len(changes) > 0 {
+		return nil, errors.Errorf("the cluster changed since the plan was made:\n%s", strings.Join(changes, "\n"))
+	}
+
+	upgradedRelease := plan.Upgrade
+	upgradedRelease.Info.Status = release.StatusPendingUpgrade
+	upgradedRelease.Info.LastDeployed = Timestamper()
+	u.cfg.Releases.MaxHistory = u.MaxHistory
+
+	u.cfg.Log("applying upgrade plan for %s", plan.Release)
+	res, err := u.performUpgrade(ctx, currentRelease, upgradedRelease)
+	if err != nil {
+		return res, err
+	}
+	if !u.isDryRun() {
+		if err := u.cfg.Releases.Update(upgradedRelease); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// planResources plans the changes of the upgrade from currentRelease to
+// upgradedRelease to the resources in the cluster.
+func (u *Upgrade) planResources(currentRelease, upgradedRelease *release.Release) ([]PlannedResource, error) {
+	kubeClient, ok := u.cfg.KubeClient.(kube.InterfacePlan)
+	if !ok {
+		return nil, errors.New("the Kubernetes client does not support upgrade plans")
+	}
+	current, target, err := u.upgradeResources(currentRelease, upgradedRelease)
+	if err != nil {
+		return nil, err
+	}
+	adopted, err := u.adoptResources(current, target, upgradedRelease)
+	if err != nil {
+		return nil, err
+	}
+	current = append(current, adopted...)
+	changes, err := kubeClient.Plan(current, target, u.Force, u.ServerSideApply)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to plan the changes to the resources")
+	}
+
+	isAdopted := make(map[string]bool)
+	for _, r := range adopted {
+		isAdopted[objectKey(r)] = true
+	}
+	resources := []PlannedResource{}
+	for _, c := range changes {
+		resources = append(resources, PlannedResource{
+			PlannedChange: c,
+			Adopted:       isAdopted[plannedKey(c)],
+		})
+	}
+	return resources, nil
+}
+
+// planDifferences describes how the resources of a plan differ from the
+// resources planned now.
+func planDifferences(planned, actual []PlannedResource) []string {
+	now := make(map[string]PlannedResource)
+	for _, r := range actual {
+		now[plannedKey(r.PlannedChange)] = r
+	}
+	var diffs []string
+	for _, r := range planned {
+		k := plannedKey(r.PlannedChange)
+		a, ok := now[k]
+		delete(now, k)
+		switch {
+		case !ok:
+			diffs = append(diffs, fmt.Sprintf("%s: planned %s, no longer planned", k, describePlannedResource(r)))
+		case !samePlannedResource(r, a):
+			diffs = append(diffs, fmt.Sprintf("%s: planned %s, now %s", k, describePlannedResource(r), describePlannedResource(a)))
+		}
+	}
+	for _, r := range actual {
+		k := plannedKey(r.PlannedChange)
+		if _, ok := now[k]; ok {
+			diffs = append(diffs, fmt.Sprintf("%s: not planned, now %s", k, describePlannedResource(r)))
+		}
+	}
+	return diffs
+}
+
+func samePlannedResource(a, b PlannedResource) bool {
+	return a.Action == b.Action &&
+		a.PatchType == b.PatchType &&
+		a.ResourceVersion == b.ResourceVersion &&
+		a.Adopted == b.Adopted &&
+		samePatch(a.Patch, b.Patch)
+}
+
+// samePatch compares patches by content, since a plan read from YAML does not
+// hold the patches with the same formatting.
+func samePatch(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func describePlannedResource(r PlannedResource) string {
+	s := string(r.Action)
+	switch {
+	case r.Adopted && r.Action == kube.PlanNone:
+		s = "adopt"
+	case r.Adopted:
+		s = "adopt and " + s
+	}
+	if r.ResourceVersion != "" {
+		s += fmt.Sprintf(" at resourceVersion %s", r.ResourceVersion)
+	}
+	return s
+}
+
+func plannedKey(c kube.PlannedChange) string {
+	return resourceKey(c.APIVersion, c.Kind, c.Namespace, c.Name)
+}
+
+// plannedHooks returns the hooks of rel run for events, in the order they
+// are run.
+func plannedHooks(rel *release.Release, events ...release.HookEvent) []PlannedHook {
+	var planned []PlannedHook
+	for _, event := range events {
+		var hooks []*release.Hook
+		for _, h := range rel.Hooks {
+			for _, e := range h.Events {
+				if e == event {
+					hooks = append(hooks, h)
+					break
+				}
+			}
+		}
+		sort.Stable(hookByWeight(hooks))
+		for _, h := range hooks {
+			planned = append(planned, PlannedHook{
+				Name:   h.Name,
+				Kind:   h.Kind,
+				Events: []release.HookEvent{event},
+				Weight: h.Weight,
+			})
+		}
+	}
+	return planned
+}
+
+// plannedCRDs returns the CRDs of the crds/ directories of the charts that
+// differ between current and upgraded, and the CRDs rendered by templates
+// that the upgrade changes.
+func plannedCRDs(current, upgraded *chart.Chart, resources []PlannedResource) []PlannedCRD {
+	var crds []PlannedCRD
+	for _, r := range resources {
+		if r.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		switch r.Action {
+		case kube.PlanCreate:
+			crds = append(crds, PlannedCRD{Name: r.Name, Change: ChangeAdded, Applied: true})
+		case kube.PlanDelete:
+			crds = append(crds, PlannedCRD{Name: r.Name, Change: ChangeRemoved, Applied: true})
+		case kube.PlanNone:
+		default:
+			crds = append(crds, PlannedCRD{Name: r.Name, Change: ChangeChanged, Applied: true})
+		}
+	}
+
+	files := func(ch *chart.Chart) map[string][]byte {
+		m := make(map[string][]byte)
+		if ch == nil {
+			return m
+		}
+		for _, crd := range ch.CRDObjects() {
+			m[crd.Filename] = crd.File.Data
+		}
+		return m
+	}
+	before, after := files(current), files(upgraded)
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b, inBefore := before[name]
+		a, inAfter := after[name]
+		switch {
+		case !inBefore:
+			crds = append(crds, PlannedCRD{Name: name, Change: ChangeAdded})
+		case !inAfter:
+			crds = append(crds, PlannedCRD{Name: name, Change: ChangeRemoved})
+		case !bytes.Equal(a, b):
+			crds = append(crds, PlannedCRD{Name: name, Change: ChangeChanged})
+		}
+	}
+	return crds
+}
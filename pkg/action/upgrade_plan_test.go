@@ -0,0 +1,174 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/types"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+func (c *clusterKubeClient) Plan(original, target kube.ResourceList, force, serverSide bool) ([]kube.PlannedChange, error) {
+	return kube.PlanUpdate(original, target, force, serverSide)
+}
+
+func planUpgrade(t *testing.T) (*Upgrade, *waveKubeClient, *chart.Chart) {
+	config, client := waveConfig(t)
+	owned := strings.ReplaceAll(driftLiveOwned, "drifty", "planned")
+	client.live = map[string]string{
+		"spaced/app":     "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\n  namespace: spaced\n  resourceVersion: \"3\"" + owned + "data:\n  key: old\n",
+		"spaced/adopted": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: adopted\n  namespace: spaced\n  resourceVersion: \"5\"" + owned,
+		"spaced/stale":   "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: stale\n  namespace: spaced\n  resourceVersion: \"8\"" + owned,
+	}
+
+	rel := releaseStub()
+	rel.Name = "planned"
+	rel.Namespace = "spaced"
+	rel.Manifest = "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  key: old\n" +
+		"---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: stale\n"
+	require.NoError(t, config.Releases.Create(rel))
+
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: app\ndata:\n  key: new\n")},
+			{Name: "templates/adopted.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: adopted\n")},
+			{Name: "templates/fresh.yaml", Data: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: fresh\n")},
+			{Name: "templates/hooks", Data: []byte(manifestWithHook)},
+		}
+		opts.Files = []*chart.File{
+			{Name: "crds/widgets.yaml", Data: []byte("kind: CustomResourceDefinition\n")},
+		}
+	})
+
+	upAction := NewUpgrade(config)
+	upAction.Namespace = "spaced"
+	return upAction, client, chrt
+}
+
+func TestUpgradePlan(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction, client, chrt := planUpgrade(t)
+	plan, err := upAction.Plan("planned", chrt, map[string]interface{}{})
+	req.NoError(err)
+	is.Empty(client.calls)
+	is.True(plan.Changed())
+	is.Equal(1, plan.FromRevision)
+	is.Equal(2, plan.Revision)
+	is.Equal(release.StatusPendingUpgrade, plan.Upgrade.Info.Status)
+
+	req.Len(plan.Resources, 4)
+	resources := make(map[string]PlannedResource)
+	for _, r := range plan.Resources {
+		resources[r.Name] = r
+	}
+	is.True(resources["adopted"].Adopted)
+	is.Equal("5", resources["adopted"].ResourceVersion)
+	is.False(resources["app"].Adopted)
+	is.Equal(kube.PlanPatch, resources["app"].Action)
+	is.Equal(types.StrategicMergePatchType, resources["app"].PatchType)
+	is.JSONEq(`{"data":{"key":"new"}}`, string(resources["app"].Patch))
+	is.Equal("3", resources["app"].ResourceVersion)
+	is.Equal(kube.PlanCreate, resources["fresh"].Action)
+	is.Equal(kube.PlanDelete, resources["stale"].Action)
+	is.Equal("8", resources["stale"].ResourceVersion)
+
+	is.Equal([]PlannedHook{{Name: "test-cm", Kind: "ConfigMap", Events: []release.HookEvent{release.HookPostUpgrade}}}, plan.Hooks)
+	is.Equal([]PlannedCRD{{Name: "hello/crds/widgets.yaml", Change: ChangeAdded}}, plan.CRDs)
+
+	upAction.DisableHooks = true
+	plan, err = upAction.Plan("planned", chrt, map[string]interface{}{})
+	req.NoError(err)
+	is.Empty(plan.Hooks)
+}
+
+func TestUpgradeApplyPlan(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction, client, chrt := planUpgrade(t)
+	upAction.DisableHooks = true
+	plan, err := upAction.Plan("planned", chrt, map[string]interface{}{})
+	req.NoError(err)
+
+	// Plans are applied as read back from a file.
+	data, err := json.Marshal(plan)
+	req.NoError(err)
+	var read UpgradePlan
+	req.NoError(json.Unmarshal(data, &read))
+
+	apply := NewUpgrade(upAction.cfg)
+	rel, err := apply.ApplyPlan(context.Background(), &read)
+	req.NoError(err)
+	is.Equal(2, rel.Version)
+	is.Equal(release.StatusDeployed, rel.Info.Status)
+	req.Len(client.calls, 1)
+	is.True(strings.HasPrefix(client.calls[0], "update "))
+	is.ElementsMatch([]string{"adopted", "app", "fresh"}, strings.Split(strings.TrimPrefix(client.calls[0], "update "), ","))
+
+	last, err := upAction.cfg.Releases.Last("planned")
+	req.NoError(err)
+	is.Equal(2, last.Version)
+	is.Equal(release.StatusDeployed, last.Info.Status)
+
+	// The release has changed since the plan was made.
+	_, err = apply.ApplyPlan(context.Background(), &read)
+	is.EqualError(err, "release planned changed since the plan was made: the plan was made at revision 1, the last revision is now 2")
+}
+
+func TestUpgradeApplyPlan_ClusterChanged(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction, client, chrt := planUpgrade(t)
+	plan, err := upAction.Plan("planned", chrt, map[string]interface{}{})
+	req.NoError(err)
+
+	client.live["spaced/app"] = strings.Replace(client.live["spaced/app"], `resourceVersion: "3"`, `resourceVersion: "4"`, 1)
+	client.live["spaced/fresh"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: fresh\n  namespace: spaced\n  resourceVersion: \"1\"" + strings.ReplaceAll(driftLiveOwned, "drifty", "planned")
+	_, err = NewUpgrade(upAction.cfg).ApplyPlan(context.Background(), plan)
+	req.Error(err)
+	is.Contains(err.Error(), "the cluster changed since the plan was made:\n")
+	is.Contains(err.Error(), "v1/ConfigMap/spaced/app: planned patch at resourceVersion 3, now patch at resourceVersion 4")
+	is.Contains(err.Error(), "v1/ConfigMap/spaced/fresh: planned create, now adopt at resourceVersion 1")
+	is.Empty(client.calls)
+
+	last, err := upAction.cfg.Releases.Last("planned")
+	req.NoError(err)
+	is.Equal(1, last.Version)
+}
+
+func TestUpgradeRelease_AdoptionConflict(t *testing.T) {
+	upAction, client, chrt := planUpgrade(t)
+	client.live["spaced/fresh"] = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: fresh\n  namespace: spaced\n"
+
+	rel, err := upAction.Run("planned", chrt, map[string]interface{}{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unable to continue with update")
+	assert.Nil(t, rel)
+	assert.Empty(t, client.calls)
+}
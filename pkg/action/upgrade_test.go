@@ -18,6 +18,7 @@ package action
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
@@ -29,6 +30,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"helm.sh/helm/v3/pkg/kube"
 	kubefake "helm.sh/helm/v3/pkg/kube/fake"
 	"helm.sh/helm/v3/pkg/release"
 	helmtime "helm.sh/helm/v3/pkg/time"
@@ -139,6 +141,33 @@ func TestUpgradeRelease_CleanupOnFail(t *testing.T) {
 	is.Equal(res.Info.Status, release.StatusFailed)
 }
 
+func TestUpgradeRelease_FailureHooks(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "nuketown"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.WatchUntilReadyError = fmt.Errorf("arming key removed")
+	upAction.Atomic = true
+	phases := recordPhases(upAction.cfg)
+
+	res, err := upAction.Run(rel.Name, buildChart(withFailureHook()), map[string]interface{}{})
+	req.Error(err)
+	is.Contains(err.Error(), "rolled back")
+	is.Equal([]string{"upgrade", "wait", "post-upgrade", "on-failure", "rollback", "wait"}, *phases)
+
+	failed, err := upAction.cfg.Releases.Get(rel.Name, res.Version)
+	req.NoError(err)
+	is.Equal(release.StatusFailed, failed.Info.Status)
+	req.NotNil(findHook(failed, "notify"))
+	is.Equal(release.HookPhaseFailed, findHook(failed, "notify").LastRun.Phase)
+}
+
 func TestUpgradeRelease_Atomic(t *testing.T) {
 	is := assert.New(t)
 	req := require.New(t)
@@ -586,3 +615,43 @@ func TestUpgradeRelease_DryRun(t *testing.T) {
 	done()
 	req.Error(err)
 }
+
+func TestUpgradeRelease_ServerSideApply(t *testing.T) {
+	is := assert.New(t)
+	req := require.New(t)
+
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "ssa"
+	rel.Info.Status = release.StatusDeployed
+	req.NoError(upAction.cfg.Releases.Create(rel))
+
+	failer := upAction.cfg.KubeClient.(*kubefake.FailingKubeClient)
+	failer.UpdateError = &kube.ConflictError{Kind: "Deployment", Name: "web", Namespace: "spaced"}
+	upAction.ServerSideApply = true
+
+	res, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	req.Error(err)
+	var conflict *kube.ConflictError
+	is.True(errors.As(err, &conflict), "expected a conflict error, got %T", err)
+	is.Equal(release.StatusFailed, res.Info.Status)
+}
+
+func TestUpgradeRelease_ServerSideApplyOptions(t *testing.T) {
+	upAction := upgradeAction(t)
+	rel := releaseStub()
+	rel.Name = "ssa"
+	rel.Info.Status = release.StatusDeployed
+	require.NoError(t, upAction.cfg.Releases.Create(rel))
+
+	upAction.ServerSideApply = true
+	upAction.Force = true
+	_, err := upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	assert.ErrorContains(t, err, "cannot be used with server-side apply")
+
+	upAction.ServerSideApply = false
+	upAction.Force = false
+	upAction.ForceConflicts = true
+	_, err = upAction.Run(rel.Name, buildChart(), map[string]interface{}{})
+	assert.ErrorContains(t, err, "requires server-side apply")
+}
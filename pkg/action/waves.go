@@ -0,0 +1,92 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// applyWaves calls apply for each of the deploy waves in order. Before moving
+// on to the next wave, it waits for the resources of the previous one to be
+// ready. Waiting for the last wave is left to the caller.
+func (cfg *Configuration) applyWaves(rel *release.Release, waves []kube.ResourceList, timeout time.Duration, withJobs bool, apply func(kube.ResourceList) (*kube.Result, error)) (*kube.Result, error) {
+	result := &kube.Result{}
+	for i, wave := range waves {
+		if len(waves) > 1 {
+			cfg.Log("applying deploy wave %d of %d for %s (%d resources)", i+1, len(waves), rel.Name, len(wave))
+		}
+		res, err := apply(wave)
+		mergeResult(result, res)
+		if err != nil {
+			return result, err
+		}
+		if i < len(waves)-1 {
+			if err := cfg.waitForResources(rel, wave, timeout, withJobs); err != nil {
+				return result, errors.Wrapf(err, "deploy wave %d of %d is not ready", i+1, len(waves))
+			}
+		}
+	}
+	return result, nil
+}
+
+// updateWaves updates the resources from current to target like
+// updateResources. If the target resources span several deploy waves, they
+// are applied wave by wave, and the resources removed from the release are
+// deleted afterwards in the reverse order of their waves.
+func (cfg *Configuration) updateWaves(rel *release.Release, current, target kube.ResourceList, force, serverSide, forceConflicts bool, timeout time.Duration, withJobs bool) (*kube.Result, error) {
+	waves, err := kube.DeployWaves(target)
+	if err != nil {
+		return &kube.Result{}, err
+	}
+	if len(waves) <= 1 {
+		return updateResources(cfg.KubeClient, current, target, force, serverSide, forceConflicts)
+	}
+
+	result, err := cfg.applyWaves(rel, waves, timeout, withJobs, func(wave kube.ResourceList) (*kube.Result, error) {
+		return updateResources(cfg.KubeClient, current.Intersect(wave), wave, force, serverSide, forceConflicts)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	removed, err := kube.DeployWaves(current.Difference(target))
+	if err != nil {
+		return result, err
+	}
+	for i := len(removed) - 1; i >= 0; i-- {
+		res, err := updateResources(cfg.KubeClient, removed[i], kube.ResourceList{}, force, serverSide, forceConflicts)
+		mergeResult(result, res)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func mergeResult(dst, src *kube.Result) {
+	if src == nil {
+		return
+	}
+	dst.Created = append(dst.Created, src.Created...)
+	dst.Updated = append(dst.Updated, src.Updated...)
+	dst.Deleted = append(dst.Deleted, src.Deleted...)
+}
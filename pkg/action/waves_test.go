@@ -0,0 +1,188 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package action
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/release"
+)
+
+// waveKubeClient records the order in which resources are applied, waited
+// for and deleted.
+type waveKubeClient struct {
+	clusterKubeClient
+	calls []string
+}
+
+func (c *waveKubeClient) record(op string, resources kube.ResourceList) {
+	var names []string
+	for _, r := range resources {
+		names = append(names, r.Name)
+	}
+	c.calls = append(c.calls, op+" "+strings.Join(names, ","))
+}
+
+func (c *waveKubeClient) Create(resources kube.ResourceList) (*kube.Result, error) {
+	c.record("create", resources)
+	return &kube.Result{Created: resources}, nil
+}
+
+func (c *waveKubeClient) Update(original, target kube.ResourceList, _ bool) (*kube.Result, error) {
+	if len(target) == 0 {
+		c.record("prune", original)
+		return &kube.Result{Deleted: original}, nil
+	}
+	c.record("update", target)
+	return &kube.Result{Updated: target}, nil
+}
+
+func (c *waveKubeClient) Wait(resources kube.ResourceList, _ time.Duration) error {
+	c.record("wait", resources)
+	return nil
+}
+
+func (c *waveKubeClient) DeleteWithPropagationPolicy(resources kube.ResourceList, _ metav1.DeletionPropagation) (*kube.Result, []error) {
+	c.record("delete", resources)
+	return &kube.Result{Deleted: resources}, nil
+}
+
+func (c *waveKubeClient) WaitForDelete(resources kube.ResourceList, _ time.Duration) error {
+	c.record("wait-delete", resources)
+	return nil
+}
+
+func waveManifest(name, wave string) string {
+	manifest := fmt.Sprintf("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: %s\n", name)
+	if wave != "" {
+		manifest += fmt.Sprintf("  annotations:\n    helm.sh/deploy-wave: %q\n", wave)
+	}
+	return manifest
+}
+
+func waveConfig(t *testing.T) (*Configuration, *waveKubeClient) {
+	config := actionConfigFixture(t)
+	client := &waveKubeClient{clusterKubeClient: clusterKubeClient{t: t, live: map[string]string{}}}
+	config.KubeClient = client
+	return config, client
+}
+
+func TestInstallRelease_DeployWaves(t *testing.T) {
+	config, client := waveConfig(t)
+	instAction := NewInstall(config)
+	instAction.Namespace = "spaced"
+	instAction.ReleaseName = "waves"
+	instAction.DisableHooks = true
+
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", "10"))},
+			{Name: "templates/config.yaml", Data: []byte(waveManifest("config", ""))},
+			{Name: "templates/db.yaml", Data: []byte(waveManifest("db", "-1"))},
+		}
+	})
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"create db",
+		"wait db",
+		"create config",
+		"wait config",
+		"create app",
+	}, client.calls)
+}
+
+func TestInstallRelease_DeployWavesInvalid(t *testing.T) {
+	config, _ := waveConfig(t)
+	instAction := NewInstall(config)
+	instAction.Namespace = "spaced"
+	instAction.ReleaseName = "waves"
+	instAction.DisableHooks = true
+
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", "last"))},
+		}
+	})
+	_, err := instAction.Run(chrt, map[string]interface{}{})
+	assert.ErrorContains(t, err, `invalid helm.sh/deploy-wave annotation "last" on ConfigMap "app"`)
+}
+
+func TestUpgradeRelease_DeployWaves(t *testing.T) {
+	config, client := waveConfig(t)
+	upAction := NewUpgrade(config)
+	upAction.Namespace = "spaced"
+	upAction.DisableHooks = true
+	upAction.Wait = true
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Namespace = "spaced"
+	rel.Manifest = "---\n" + waveManifest("db", "-1") + "---\n" + waveManifest("cache", "") + "---\n" + waveManifest("worker", "5")
+	require.NoError(t, config.Releases.Create(rel))
+
+	chrt := buildChart(func(opts *chartOptions) {
+		opts.Templates = []*chart.File{
+			{Name: "templates/app.yaml", Data: []byte(waveManifest("app", "10"))},
+			{Name: "templates/db.yaml", Data: []byte(waveManifest("db", "-1"))},
+		}
+	})
+	res, err := upAction.Run(rel.Name, chrt, map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, release.StatusDeployed, res.Info.Status)
+	require.Len(t, client.calls, 6)
+	assert.Equal(t, []string{
+		"update db",
+		"wait db",
+		"update app",
+		"prune worker",
+		"prune cache",
+	}, client.calls[:5])
+	// The final wait is for all the resources, in the order they were
+	// rendered.
+	assert.ElementsMatch(t, []string{"app", "db"}, strings.Split(strings.TrimPrefix(client.calls[5], "wait "), ","))
+}
+
+func TestUninstallRelease_DeployWaves(t *testing.T) {
+	config, client := waveConfig(t)
+	unAction := NewUninstall(config)
+	unAction.DisableHooks = true
+
+	rel := releaseStub()
+	rel.Name = "waves"
+	rel.Manifest = "---\n" + waveManifest("db", "-1") + "---\n" + waveManifest("config", "") + "---\n" + waveManifest("app", "10")
+	require.NoError(t, config.Releases.Create(rel))
+
+	_, err := unAction.Run(rel.Name)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"delete app",
+		"wait-delete app",
+		"delete config",
+		"wait-delete config",
+		"delete db",
+	}, client.calls)
+}
@@ -17,6 +17,7 @@ package chart
 
 import (
 	"path/filepath"
+	"regexp"
 	"strings"
 	"unicode"
 
@@ -81,6 +82,10 @@ type Metadata struct {
 	Dependencies []*Dependency `json:"dependencies,omitempty"`
 	// Specifies the chart type: application or library
 	Type string `json:"type,omitempty"`
+	// RequiredFunctions are the template functions the chart uses that are
+	// not built into Helm, usually provided by plugins. Rendering fails if
+	// one of them is not available.
+	RequiredFunctions []string `json:"requiredFunctions,omitempty"`
 }
 
 // Validate checks the metadata for known issues and sanitizes string
@@ -104,6 +109,9 @@ func (md *Metadata) Validate() error {
 	for i := range md.Keywords {
 		md.Keywords[i] = sanitizeString(md.Keywords[i])
 	}
+	for i := range md.RequiredFunctions {
+		md.RequiredFunctions[i] = sanitizeString(md.RequiredFunctions[i])
+	}
 
 	if md.APIVersion == "" {
 		return ValidationError("chart.metadata.apiVersion is required")
@@ -131,6 +139,11 @@ func (md *Metadata) Validate() error {
 			return err
 		}
 	}
+	for _, fn := range md.RequiredFunctions {
+		if !functionNameRegex.MatchString(fn) {
+			return ValidationErrorf("chart.metadata.requiredFunctions %q is invalid", fn)
+		}
+	}
 
 	// Aliases need to be validated here to make sure that the alias name does
 	// not contain any illegal characters.
@@ -151,6 +164,9 @@ func (md *Metadata) Validate() error {
 	return nil
 }
 
+// functionNameRegex matches the names of template functions.
+var functionNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
 func isValidChartType(in string) bool {
 	switch in {
 	case "", "application", "library":
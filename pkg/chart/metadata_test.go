@@ -177,6 +177,11 @@ func TestValidate(t *testing.T) {
 			&Metadata{APIVersion: "v2", Name: "test", Version: "1.2.3.4"},
 			ValidationError("chart.metadata.version \"1.2.3.4\" is invalid"),
 		},
+		{
+			"required function invalid",
+			&Metadata{APIVersion: "v2", Name: "test", Version: "1.0", Type: "application", RequiredFunctions: []string{"vault", "get-secret"}},
+			ValidationError("chart.metadata.requiredFunctions \"get-secret\" is invalid"),
+		},
 	}
 
 	for _, tt := range tests {
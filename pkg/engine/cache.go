@@ -0,0 +1,73 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// TemplateCache holds parsed templates, so that an Engine rendering the same
+// chart repeatedly parses each template file only once. A cache may be shared
+// by engines used concurrently.
+//
+// The cache holds the last version of each template file, by name, so a file
+// that changes is parsed again.
+type TemplateCache struct {
+	mu    sync.Mutex
+	files map[string]*cachedFile
+}
+
+type cachedFile struct {
+	text string
+	// trees holds the templates parsed from text, by name: the file itself
+	// and the templates it defines.
+	trees map[string]*parse.Tree
+}
+
+// NewTemplateCache creates an empty TemplateCache.
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{files: make(map[string]*cachedFile)}
+}
+
+// parse returns the templates parsed from the text of the named file, using
+// funcs to check the functions called. The trees must not be modified.
+func (c *TemplateCache) parse(name, text string, funcs template.FuncMap) (map[string]*parse.Tree, error) {
+	c.mu.Lock()
+	f, ok := c.files[name]
+	c.mu.Unlock()
+	if ok && f.text == text {
+		return f.trees, nil
+	}
+
+	t, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	f = &cachedFile{text: text, trees: make(map[string]*parse.Tree)}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			f.trees[tmpl.Name()] = tmpl.Tree
+		}
+	}
+
+	c.mu.Lock()
+	c.files[name] = f
+	c.mu.Unlock()
+	return f.trees, nil
+}
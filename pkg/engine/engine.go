@@ -24,6 +24,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/pkg/errors"
@@ -44,6 +45,23 @@ type Engine struct {
 	clientProvider *ClientProvider
 	// EnableDNS tells the engine to allow DNS lookups when rendering templates
 	EnableDNS bool
+	// Trace, if set, records the templates included and the values read
+	// while rendering
+	Trace *Trace
+	// Parallelism is the number of charts, the parent chart and its
+	// subcharts, rendered at once. The files of a chart are rendered one at
+	// a time, with a copy of the values of the chart, so that a template
+	// changing the values (with 'set', for example) does not affect the
+	// other charts. Charts are rendered one at a time, with shared values, if
+	// it is less than two, or if Trace is set.
+	Parallelism int
+	// Cache, if set, holds the parsed templates across renders. A cache is
+	// only shared by engines with the same custom template functions.
+	Cache *TemplateCache
+	// CustomTemplateFuncs are added to the functions available to templates.
+	// They may replace the Sprig functions, but not the functions Helm binds
+	// at render time, like 'include', 'tpl', 'required', 'fail' and 'lookup'.
+	CustomTemplateFuncs template.FuncMap
 }
 
 // New creates a new instance of Engine using the passed in rest config.
@@ -74,8 +92,28 @@ func New(config *rest.Config) Engine {
 // section contains a value named "bar", that value will be passed on to the
 // bar chart during render time.
 func (e Engine) Render(chrt *chart.Chart, values chartutil.Values) (map[string]string, error) {
+	if err := e.checkRequiredFunctions(chrt); err != nil {
+		return map[string]string{}, err
+	}
+	tmap := allTemplates(chrt, values)
+	rendered, err := e.render(tmap)
+	e.Trace.finish(chrt, values)
+	return rendered, err
+}
+
+// RenderWithSourceMaps renders the templates like Render, and also returns
+// the source map of each rendered file. Source maps link the lines of the
+// rendered files to the template lines that produced them, including
+// through 'template', and through 'include' when its output is only
+// indented.
+func (e Engine) RenderWithSourceMaps(chrt *chart.Chart, values chartutil.Values) (map[string]string, map[string]SourceMap, error) {
+	if err := e.checkRequiredFunctions(chrt); err != nil {
+		return map[string]string{}, nil, err
+	}
 	tmap := allTemplates(chrt, values)
-	return e.render(tmap)
+	rendered, maps, err := e.renderFiles(tmap, true)
+	e.Trace.finish(chrt, values)
+	return rendered, maps, err
 }
 
 // Render takes a chart, optional values, and value overrides, and attempts to
@@ -126,8 +164,18 @@ func warnWrap(warn string) string {
 
 // 'include' needs to be defined in the scope of a 'tpl' template as
 // well as regular file-loaded templates.
-func includeFun(t *template.Template, includedNames map[string]int) func(string, interface{}) (string, error) {
+func includeFun(t *template.Template, includedNames map[string]int, trace *Trace) func(string, interface{}) (string, error) {
+	include := includeSourceFun(t, includedNames, trace)
 	return func(name string, data interface{}) (string, error) {
+		s, err := include(name, data)
+		return stripSourceMarkers(s), err
+	}
+}
+
+// includeSourceFun is 'include' without the removal of source markers.
+func includeSourceFun(t *template.Template, includedNames map[string]int, trace *Trace) func(string, interface{}) (string, error) {
+	return func(name string, data interface{}) (string, error) {
+		defer trace.call("include", name, data)()
 		var buf strings.Builder
 		if v, ok := includedNames[name]; ok {
 			if v > recursionMaxNums {
@@ -145,8 +193,9 @@ func includeFun(t *template.Template, includedNames map[string]int) func(string,
 
 // As does 'tpl', so that nested calls to 'tpl' see the templates
 // defined by their enclosing contexts.
-func tplFun(parent *template.Template, includedNames map[string]int, strict bool) func(string, interface{}) (string, error) {
+func tplFun(parent *template.Template, includedNames map[string]int, strict bool, trace *Trace) func(string, interface{}) (string, error) {
 	return func(tpl string, vals interface{}) (string, error) {
+		defer trace.call("tpl", tpl, vals)()
 		t, err := parent.Clone()
 		if err != nil {
 			return "", errors.Wrapf(err, "cannot clone template")
@@ -164,8 +213,8 @@ func tplFun(parent *template.Template, includedNames map[string]int, strict bool
 		// Re-inject 'include' so that it can close over our clone of t;
 		// this lets any 'define's inside tpl be 'include'd.
 		t.Funcs(template.FuncMap{
-			"include": includeFun(t, includedNames),
-			"tpl":     tplFun(t, includedNames, strict),
+			"include": includeFun(t, includedNames, trace),
+			"tpl":     tplFun(t, includedNames, strict, trace),
 		})
 
 		// We need a .New template, as template text which is just blanks
@@ -178,6 +227,9 @@ func tplFun(parent *template.Template, includedNames map[string]int, strict bool
 		if err != nil {
 			return "", errors.Wrapf(err, "cannot parse template %q", tpl)
 		}
+		if trace != nil && t.Tree != nil {
+			instrumentValuesList(t.Tree.Root)
+		}
 
 		var buf strings.Builder
 		if err := t.Execute(&buf, vals); err != nil {
@@ -185,18 +237,24 @@ func tplFun(parent *template.Template, includedNames map[string]int, strict bool
 		}
 
 		// See comment in renderWithReferences explaining the <no value> hack.
-		return strings.ReplaceAll(buf.String(), "<no value>", ""), nil
+		return strings.ReplaceAll(stripSourceMarkers(buf.String()), "<no value>", ""), nil
 	}
 }
 
 // initFunMap creates the Engine's FuncMap and adds context-specific functions.
-func (e Engine) initFunMap(t *template.Template) {
-	funcMap := funcMap()
+func (e Engine) initFunMap(t *template.Template, sourceMaps bool) {
+	funcMap := e.funcMap()
 	includedNames := make(map[string]int)
 
 	// Add the template-rendering functions here so we can close over t.
-	funcMap["include"] = includeFun(t, includedNames)
-	funcMap["tpl"] = tplFun(t, includedNames, e.Strict)
+	funcMap["include"] = includeFun(t, includedNames, e.Trace)
+	if sourceMaps {
+		funcMap[includeSourceFunc] = includeSourceFun(t, includedNames, e.Trace)
+	}
+	funcMap["tpl"] = tplFun(t, includedNames, e.Strict, e.Trace)
+	if e.Trace != nil {
+		funcMap[traceValuesFunc] = e.Trace.readValues
+	}
 
 	// Add the `required` function here so we can use lintMode
 	funcMap["required"] = func(warn string, val interface{}) (interface{}, error) {
@@ -247,8 +305,86 @@ func (e Engine) initFunMap(t *template.Template) {
 	t.Funcs(funcMap)
 }
 
+// funcMap returns the functions of the templates, before the functions
+// bound at render time are added.
+func (e Engine) funcMap() template.FuncMap {
+	funcs := funcMap()
+	for name, fn := range e.CustomTemplateFuncs {
+		if !renderTimeFuncs[name] {
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
+// renderTimeFuncs are the functions bound at render time, which custom
+// template functions cannot replace.
+var renderTimeFuncs = map[string]bool{
+	"include":  true,
+	"tpl":      true,
+	"required": true,
+	"fail":     true,
+	"lookup":   true,
+}
+
+// checkRequiredFunctions returns an error if the chart or its subcharts
+// require template functions that are not available. In LintMode the
+// missing functions are replaced with functions returning an empty string.
+func (e *Engine) checkRequiredFunctions(chrt *chart.Chart) error {
+	type requirement struct{ name, chart string }
+	var required []requirement
+	var walk func(c *chart.Chart)
+	walk = func(c *chart.Chart) {
+		if c.Metadata != nil {
+			for _, name := range c.Metadata.RequiredFunctions {
+				required = append(required, requirement{name, c.Name()})
+			}
+		}
+		for _, dep := range c.Dependencies() {
+			walk(dep)
+		}
+	}
+	walk(chrt)
+	if len(required) == 0 {
+		return nil
+	}
+
+	funcs := e.funcMap()
+	var missing []string
+	stubs := make(template.FuncMap)
+	for _, r := range required {
+		if _, ok := funcs[r.name]; ok {
+			continue
+		}
+		missing = append(missing, fmt.Sprintf("%q required by chart %q", r.name, r.chart))
+		stubs[r.name] = func(...interface{}) string { return "" }
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+
+	if e.LintMode {
+		// Don't fail on missing functions when linting
+		log.Printf("[INFO] Missing template functions: %s", strings.Join(missing, ", "))
+		for name, fn := range e.CustomTemplateFuncs {
+			stubs[name] = fn
+		}
+		e.CustomTemplateFuncs = stubs
+		return nil
+	}
+	return errors.Errorf("missing template functions: %s. A plugin providing them may need to be installed", strings.Join(missing, ", "))
+}
+
 // render takes a map of templates/values and renders them.
-func (e Engine) render(tpls map[string]renderable) (rendered map[string]string, err error) {
+func (e Engine) render(tpls map[string]renderable) (map[string]string, error) {
+	rendered, _, err := e.renderFiles(tpls, false)
+	return rendered, err
+}
+
+// renderFiles renders the templates, and if sourceMaps is set also returns
+// the source map of each rendered file.
+func (e Engine) renderFiles(tpls map[string]renderable, sourceMaps bool) (rendered map[string]string, maps map[string]SourceMap, err error) {
 	// Basically, what we do here is start with an empty parent template and then
 	// build up a list of templates -- one for each file. Once all of the templates
 	// have been parsed, we loop through again and execute every template.
@@ -270,78 +406,230 @@ func (e Engine) render(tpls map[string]renderable) (rendered map[string]string,
 		t.Option("missingkey=zero")
 	}
 
-	e.initFunMap(t)
+	e.initFunMap(t, sourceMaps)
 
 	// We want to parse the templates in a predictable order. The order favors
 	// higher-level (in file system) templates over deeply nested templates.
 	keys := sortTemplates(tpls)
 
+	if err := e.parse(t, tpls, keys, sourceMaps || e.Trace != nil); err != nil {
+		return map[string]string{}, nil, err
+	}
+
+	if e.Trace != nil {
+		instrumentValues(t)
+	}
+	if sourceMaps {
+		instrument(t, tpls)
+		maps = make(map[string]SourceMap, len(keys))
+	}
+
+	// Don't render partials. We don't care out the direct output of partials.
+	// They are only included from other templates.
+	files := make([]string, 0, len(keys))
 	for _, filename := range keys {
-		r := tpls[filename]
-		if _, err := t.New(filename).Parse(r.tpl); err != nil {
-			return map[string]string{}, cleanupParseError(filename, err)
+		if !strings.HasPrefix(path.Base(filename), "_") {
+			files = append(files, filename)
 		}
 	}
+	outs, errs := e.execute(t, tpls, files, sourceMaps)
 
-	rendered = make(map[string]string, len(keys))
-	for _, filename := range keys {
-		// Don't render partials. We don't care out the direct output of partials.
-		// They are only included from other templates.
-		if strings.HasPrefix(path.Base(filename), "_") {
-			continue
+	rendered = make(map[string]string, len(files))
+	for i, filename := range files {
+		if errs[i] != nil {
+			return map[string]string{}, nil, cleanupExecError(filename, errs[i])
 		}
-		// At render time, add information about the template that is being rendered.
-		vals := tpls[filename].vals
-		vals["Template"] = chartutil.Values{"Name": filename, "BasePath": tpls[filename].basePath}
-		var buf strings.Builder
-		if err := t.ExecuteTemplate(&buf, filename, vals); err != nil {
-			return map[string]string{}, cleanupExecError(filename, err)
+
+		out := outs[i]
+		if sourceMaps {
+			out, maps[filename] = extractSourceMap(out)
 		}
 
 		// Work around the issue where Go will emit "<no value>" even if Options(missing=zero)
 		// is set. Since missing=error will never get here, we do not need to handle
 		// the Strict case.
-		rendered[filename] = strings.ReplaceAll(buf.String(), "<no value>", "")
+		rendered[filename] = strings.ReplaceAll(out, "<no value>", "")
 	}
 
-	return rendered, nil
+	return rendered, maps, nil
 }
 
-func cleanupParseError(filename string, err error) error {
-	tokens := strings.Split(err.Error(), ": ")
-	if len(tokens) == 1 {
-		// This might happen if a non-templating error occurs
-		return fmt.Errorf("parse error in (%s): %s", filename, err)
-	}
-	// The first token is "template"
-	// The second token is either "filename:lineno" or "filename:lineNo:columnNo"
-	location := tokens[1]
-	// The remaining tokens make up a stacktrace-like chain, ending with the relevant error
-	errMsg := tokens[len(tokens)-1]
-	return fmt.Errorf("parse error at (%s): %s", string(location), errMsg)
+// parse parses the templates into t, in the order of keys. If instrumented
+// is set the templates are to be modified, so cached templates are copied.
+func (e Engine) parse(t *template.Template, tpls map[string]renderable, keys []string, instrumented bool) error {
+	if e.Cache == nil {
+		for _, filename := range keys {
+			if _, err := t.New(filename).Parse(tpls[filename].tpl); err != nil {
+				return cleanupParseError(filename, err)
+			}
+		}
+		return nil
+	}
+
+	// Parsing only checks the names of the functions.
+	funcs := e.funcMap()
+	for _, filename := range keys {
+		trees, err := e.Cache.parse(filename, tpls[filename].tpl, funcs)
+		if err != nil {
+			return cleanupParseError(filename, err)
+		}
+		for name, tree := range trees {
+			if instrumented {
+				tree = tree.Copy()
+			}
+			if _, err := t.AddParseTree(name, tree); err != nil {
+				return cleanupParseError(filename, err)
+			}
+		}
+	}
+	return nil
 }
 
-func cleanupExecError(filename string, err error) error {
-	if _, isExecError := err.(template.ExecError); !isExecError {
-		return err
+// execute renders the files, returning the output or the error of each.
+// Like rendering the files in order, it stops at the first file that fails:
+// the files after it may not be rendered.
+func (e Engine) execute(t *template.Template, tpls map[string]renderable, files []string, sourceMaps bool) ([]string, []error) {
+	outs := make([]string, len(files))
+	errs := make([]error, len(files))
+
+	// The files of a chart share its base path.
+	var charts [][]int
+	index := make(map[string]int)
+	for i, filename := range files {
+		basePath := tpls[filename].basePath
+		c, ok := index[basePath]
+		if !ok {
+			c = len(charts)
+			index[basePath] = c
+			charts = append(charts, nil)
+		}
+		charts[c] = append(charts[c], i)
+	}
+
+	workers := e.Parallelism
+	if workers > len(charts) {
+		workers = len(charts)
+	}
+	// The trace records a file at a time.
+	if workers < 2 || e.Trace != nil {
+		for i, filename := range files {
+			outs[i], errs[i] = e.executeFile(t, filename, tpls[filename])
+			if errs[i] != nil {
+				break
+			}
+		}
+		return outs, errs
+	}
+
+	var (
+		mu     sync.Mutex
+		next   int
+		failed = len(files)
+		wg     sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		// Each worker has its own 'include' and 'tpl', as they keep track of
+		// the templates being included.
+		wt, err := t.Clone()
+		if err != nil {
+			errs[0] = errors.Wrap(err, "cannot clone template")
+			return outs, errs
+		}
+		e.initFunMap(wt, sourceMaps)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				c := next
+				next++
+				mu.Unlock()
+				if c >= len(charts) {
+					return
+				}
+
+				vals := copyValues(tpls[files[charts[c][0]]].vals)
+				for _, i := range charts[c] {
+					// All the files before one that fails are rendered, so
+					// the first error is the same as when rendering one file
+					// at a time.
+					mu.Lock()
+					stop := i > failed
+					mu.Unlock()
+					if stop {
+						break
+					}
+
+					r := tpls[files[i]]
+					r.vals = vals
+					outs[i], errs[i] = e.executeFile(wt, files[i], r)
+					if errs[i] != nil {
+						mu.Lock()
+						if i < failed {
+							failed = i
+						}
+						mu.Unlock()
+						break
+					}
+				}
+			}
+		}()
 	}
+	wg.Wait()
+	return outs, errs
+}
+
+// copyValues returns a deep copy of the tables and lists of vals.
+func copyValues(vals chartutil.Values) chartutil.Values {
+	return copyValue(vals).(chartutil.Values)
+}
 
-	tokens := strings.SplitN(err.Error(), ": ", 3)
-	if len(tokens) != 3 {
-		// This might happen if a non-templating error occurs
-		return fmt.Errorf("execution error in (%s): %s", filename, err)
+func copyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case chartutil.Values:
+		c := make(chartutil.Values, len(v))
+		for k, e := range v {
+			c[k] = copyValue(e)
+		}
+		return c
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			c[k] = copyValue(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i, e := range v {
+			c[i] = copyValue(e)
+		}
+		return c
 	}
+	return v
+}
 
-	// The first token is "template"
-	// The second token is either "filename:lineno" or "filename:lineNo:columnNo"
-	location := tokens[1]
+// executeFile renders a file.
+func (e Engine) executeFile(t *template.Template, filename string, r renderable) (out string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.Errorf("rendering template failed: %v", r)
+		}
+	}()
 
-	parts := warnRegex.FindStringSubmatch(tokens[2])
-	if len(parts) >= 2 {
-		return fmt.Errorf("execution error at (%s): %s", string(location), parts[1])
+	// At render time, add information about the template that is being
+	// rendered. The values are copied, as the files of a chart share them.
+	vals := make(chartutil.Values, len(r.vals)+1)
+	for k, v := range r.vals {
+		vals[k] = v
 	}
+	vals["Template"] = chartutil.Values{"Name": filename, "BasePath": r.basePath}
 
-	return err
+	var buf strings.Builder
+	done := e.Trace.startFile(filename, r.basePath)
+	err = t.ExecuteTemplate(&buf, filename, vals)
+	done()
+	return buf.String(), err
 }
 
 func sortTemplates(tpls map[string]renderable) []string {
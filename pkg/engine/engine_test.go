@@ -19,6 +19,7 @@ package engine
 import (
 	"fmt"
 	"path"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -1300,3 +1301,288 @@ func TestRenderTplMissingKeyString(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// umbrellaChart returns a chart with n subcharts, whose templates use the
+// helpers of the parent chart.
+func umbrellaChart(n int) (*chart.Chart, chartutil.Values) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "umbrella", Version: "1.0.0"},
+		Templates: []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte(`{{- define "umbrella.labels" -}}
+app.kubernetes.io/name: {{ .Chart.Name }}
+app.kubernetes.io/instance: {{ .Release.Name }}
+{{- end }}`)},
+			{Name: "templates/configmap.yaml", Data: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+  labels:
+    {{- include "umbrella.labels" . | nindent 4 }}
+data:
+  {{- range $name, $sub := .Values }}
+  {{ $name }}: {{ $sub.image | quote }}
+  {{- end }}
+`)},
+		},
+	}
+
+	values := make(map[string]interface{})
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("sub%02d", i)
+		sub := &chart.Chart{
+			Metadata: &chart.Metadata{Name: name, Version: "1.0.0"},
+			Templates: []*chart.File{
+				{Name: "templates/_helpers.tpl", Data: []byte(fmt.Sprintf(`{{- define "%s.fullname" -}}
+{{ .Release.Name }}-{{ .Chart.Name }}
+{{- end }}`, name))},
+				{Name: "templates/deployment.yaml", Data: []byte(fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ include "%[1]s.fullname" . }}
+  labels:
+    {{- include "umbrella.labels" . | nindent 4 }}
+spec:
+  replicas: {{ .Values.replicas }}
+  template:
+    spec:
+      containers:
+      - name: {{ .Chart.Name }}
+        image: {{ tpl .Values.image . }}
+        ports:
+        {{- range .Values.ports }}
+        - containerPort: {{ . }}
+        {{- end }}
+        env:
+        {{- toYaml .Values.env | nindent 8 }}
+`, name))},
+				{Name: "templates/service.yaml", Data: []byte(fmt.Sprintf(`apiVersion: v1
+kind: Service
+metadata:
+  name: {{ include "%[1]s.fullname" . }}
+spec:
+  ports:
+  {{- range .Values.ports }}
+  - port: {{ . }}
+  {{- end }}
+`, name))},
+			},
+		}
+		c.AddDependency(sub)
+		values[name] = map[string]interface{}{
+			"replicas": i%3 + 1,
+			"image":    "registry.example.com/{{ .Chart.Name }}:1.0",
+			"ports":    []interface{}{80, 443},
+			"env":      []interface{}{map[string]interface{}{"name": "INDEX", "value": fmt.Sprint(i)}},
+		}
+	}
+
+	vals := chartutil.Values{
+		"Values":  values,
+		"Release": chartutil.Values{"Name": "umbrella"},
+	}
+	return c, vals
+}
+
+func TestRenderParallel(t *testing.T) {
+	c, vals := umbrellaChart(20)
+
+	expected, err := Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Engine{Parallelism: 8}
+	out, err := e.Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, out) {
+		t.Errorf("expected parallel rendering to give the same output")
+	}
+
+	// The error is the one of the first file to fail in render order.
+	for _, i := range []int{3, 12} {
+		sub := c.Dependencies()[i]
+		sub.Templates = append(sub.Templates, &chart.File{
+			Name: "templates/fail.yaml",
+			Data: []byte(fmt.Sprintf(`{{ fail "%s failed" }}`, sub.Name())),
+		})
+	}
+	_, expectedErr := Render(c, vals)
+	if expectedErr == nil {
+		t.Fatal("expected rendering to fail")
+	}
+	for i := 0; i < 10; i++ {
+		if _, err := e.Render(c, vals); err == nil || err.Error() != expectedErr.Error() {
+			t.Fatalf("expected error %q, got %v", expectedErr, err)
+		}
+	}
+}
+
+func TestRenderParallelValues(t *testing.T) {
+	c, vals := umbrellaChart(20)
+	// Templates may change the values of their chart, which the files
+	// rendered after them see.
+	for _, sub := range c.Dependencies() {
+		sub.Templates = append(sub.Templates,
+			&chart.File{Name: "templates/z-set.yaml", Data: []byte(`{{ $_ := set .Values "touched" .Chart.Name }}{{ $_ := unset .Values "replicas" }}`)},
+			&chart.File{Name: "templates/a-read.yaml", Data: []byte(`touched: {{ .Values.touched }} replicas: {{ .Values.replicas }}`)},
+		)
+	}
+
+	expected, err := Render(c, copyValues(vals))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Engine{Parallelism: 8}
+	for i := 0; i < 5; i++ {
+		out, err := e.Render(c, vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("expected parallel rendering to give the same output")
+		}
+	}
+	if got := expected["umbrella/charts/sub07/templates/a-read.yaml"]; got != "touched: sub07 replicas: " {
+		t.Errorf("expected the changed values to be read, got %q", got)
+	}
+}
+
+func TestRenderCache(t *testing.T) {
+	c, vals := umbrellaChart(3)
+
+	expected, err := Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := Engine{Cache: NewTemplateCache()}
+	for i := 0; i < 2; i++ {
+		out, err := e.Render(c, vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("expected render %d to give the same output", i)
+		}
+		// Adding source markers does not change the cached templates.
+		out, _, err = e.RenderWithSourceMaps(c, vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(expected, out) {
+			t.Fatalf("expected render %d with source maps to give the same output", i)
+		}
+	}
+
+	// A changed template is parsed again.
+	c.Templates[1].Data = []byte(`name: {{ .Release.Name }}`)
+	out, err := e.Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out["umbrella/templates/configmap.yaml"]; got != "name: umbrella" {
+		t.Errorf("expected the changed template to be rendered, got %q", got)
+	}
+
+	c.Templates[1].Data = []byte(`name: {{ nosuchfunc }}`)
+	_, err = e.Render(c, vals)
+	expectedErr := `parse error at (umbrella/templates/configmap.yaml:1): function "nosuchfunc" not defined`
+	if err == nil || err.Error() != expectedErr {
+		t.Errorf("expected error %q, got %v", expectedErr, err)
+	}
+}
+
+func TestRenderCustomTemplateFuncs(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Templates: []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte(`{{ define "name" }}whale{{ end }}`)},
+			{Name: "templates/test", Data: []byte(`{{ shout .Values.what }} {{ include "name" . }} {{ upper "blue" }}`)},
+		},
+	}
+	vals := map[string]interface{}{
+		"Values": map[string]interface{}{"what": "hello"},
+	}
+
+	e := Engine{CustomTemplateFuncs: template.FuncMap{
+		"shout": func(s string) string { return strings.ToUpper(s) + "!" },
+		// Functions built into Helm may be replaced, but not the ones bound
+		// at render time.
+		"upper":   func(s string) string { return "big " + s },
+		"include": func(string, interface{}) string { return "replaced" },
+	}}
+	out, err := e.Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "HELLO! whale big blue"
+	if got := out["moby/templates/test"]; got != expect {
+		t.Errorf("Expected %q, got %q", expect, got)
+	}
+}
+
+func TestRenderRequiredFunctions(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby", RequiredFunctions: []string{"vault"}},
+		Templates: []*chart.File{
+			{Name: "templates/test", Data: []byte(`secret: {{ vault "db" }}`)},
+		},
+	}
+	sub := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "sub", RequiredFunctions: []string{"upper", "lookupSecret"}},
+	}
+	c.AddDependency(sub)
+	vals := map[string]interface{}{"Values": map[string]interface{}{}}
+
+	_, err := Render(c, vals)
+	expectErr := `missing template functions: "lookupSecret" required by chart "sub", "vault" required by chart "moby". A plugin providing them may need to be installed`
+	if err == nil || err.Error() != expectErr {
+		t.Errorf("Expected error %q, got %v", expectErr, err)
+	}
+
+	// Missing functions render as empty strings when linting.
+	e := Engine{LintMode: true}
+	out, err := e.Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out["moby/templates/test"]; got != "secret: " {
+		t.Errorf("Expected %q, got %q", "secret: ", got)
+	}
+
+	e = Engine{CustomTemplateFuncs: template.FuncMap{
+		"vault":        func(key string) string { return "s3cret-" + key },
+		"lookupSecret": func() string { return "" },
+	}}
+	out, err = e.Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out["moby/templates/test"]; got != "secret: s3cret-db" {
+		t.Errorf("Expected %q, got %q", "secret: s3cret-db", got)
+	}
+}
+
+func BenchmarkRender(b *testing.B) {
+	c, vals := umbrellaChart(60)
+	const parallelism = 8
+
+	benchmarks := []struct {
+		name   string
+		engine Engine
+	}{
+		{"serial", Engine{}},
+		{"parallel", Engine{Parallelism: parallelism}},
+		{"cached", Engine{Cache: NewTemplateCache()}},
+		{"cached parallel", Engine{Parallelism: parallelism, Cache: NewTemplateCache()}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := bm.engine.Render(c, vals); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// RenderStage is the stage of rendering in which an error occurred.
+type RenderStage string
+
+const (
+	// StageParse is the parsing of the templates.
+	StageParse RenderStage = "parse"
+	// StageExecution is the execution of the templates.
+	StageExecution RenderStage = "execution"
+	// StageManifest is the parsing of the rendered manifests.
+	StageManifest RenderStage = "manifest"
+)
+
+// StackFrame is a template being executed when an error occurred.
+type StackFrame struct {
+	// Template is the name of the template, either a file or a 'define'.
+	Template string `json:"template"`
+	// File, Line and Column are the position of Action.
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column,omitempty"`
+	// Action is the action being executed, like 'include "labels" .'.
+	Action string `json:"action,omitempty"`
+}
+
+// RenderError is an error rendering a chart, with the position in the
+// templates that caused it.
+type RenderError struct {
+	Stage RenderStage `json:"stage"`
+	// File, Line and Column are the position of the error. For execution
+	// errors it is the innermost frame of Stack.
+	File   string `json:"file"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	// Message describes the error, without its position.
+	Message string `json:"message"`
+	// Stack holds the templates being executed, outermost first, through
+	// 'include', 'template' and 'tpl' calls.
+	Stack []StackFrame `json:"stack,omitempty"`
+	// Err is the underlying error.
+	Err error `json:"-"`
+
+	// fail is set when Message was raised with the 'fail' or 'required'
+	// functions.
+	fail bool
+}
+
+func (e *RenderError) Error() string {
+	switch e.Stage {
+	case StageParse:
+		if e.Line == 0 {
+			return fmt.Sprintf("parse error in (%s): %s", e.File, e.Message)
+		}
+		return fmt.Sprintf("parse error at (%s): %s", location(e.File, e.Line, e.Column), e.Message)
+	case StageExecution:
+		if len(e.Stack) == 0 {
+			return fmt.Sprintf("execution error in (%s): %s", e.File, e.Message)
+		}
+		if !e.fail {
+			return e.Err.Error()
+		}
+		// Report where the failing template was entered, which is the file
+		// being rendered.
+		f := e.Stack[0]
+		return fmt.Sprintf("execution error at (%s): %s", location(f.File, f.Line, f.Column), e.Message)
+	default:
+		return fmt.Sprintf("%s: %s", location(e.File, e.Line, e.Column), e.Message)
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+func location(file string, line, column int) string {
+	switch {
+	case line == 0:
+		return file
+	case column == 0:
+		return fmt.Sprintf("%s:%d", file, line)
+	default:
+		return fmt.Sprintf("%s:%d:%d", file, line, column)
+	}
+}
+
+var (
+	locationRegex = regexp.MustCompile(`^(.*?):(\d+)(?::(\d+))?$`)
+	frameRegex    = regexp.MustCompile(`template: ([^\s]+?):(\d+)(?::(\d+))?: executing "([^"]*)" at <(.*?)>: `)
+)
+
+func parseLocation(s string) (file string, line, column int) {
+	m := locationRegex.FindStringSubmatch(s)
+	if m == nil {
+		return s, 0, 0
+	}
+	line, _ = strconv.Atoi(m[2])
+	column, _ = strconv.Atoi(m[3])
+	return m[1], line, column
+}
+
+func cleanupParseError(filename string, err error) error {
+	tokens := strings.Split(err.Error(), ": ")
+	if len(tokens) == 1 {
+		// This might happen if a non-templating error occurs
+		return &RenderError{Stage: StageParse, File: filename, Message: err.Error(), Err: err}
+	}
+	// The first token is "template"
+	// The second token is either "filename:lineno" or "filename:lineNo:columnNo"
+	file, line, column := parseLocation(tokens[1])
+	// The remaining tokens make up a stacktrace-like chain, ending with the relevant error
+	errMsg := tokens[len(tokens)-1]
+	return &RenderError{Stage: StageParse, File: file, Line: line, Column: column, Message: errMsg, Err: err}
+}
+
+// cleanupExecError returns the error of executing filename. Errors of the
+// template package are still returned as a template.ExecError, wrapping a
+// *RenderError that errors.As finds.
+func cleanupExecError(filename string, err error) error {
+	execErr, isExecError := err.(template.ExecError)
+	if !isExecError {
+		return err
+	}
+
+	// Errors of nested templates are chained in the message as
+	// "template: file:line:col: executing "name" at <action>: ...".
+	msg := err.Error()
+	matches := frameRegex.FindAllStringSubmatchIndex(msg, -1)
+	if len(matches) == 0 {
+		// This might happen if a non-templating error occurs
+		return &RenderError{Stage: StageExecution, File: filename, Message: msg, Err: err}
+	}
+
+	rerr := &RenderError{Stage: StageExecution, Err: err}
+	for _, m := range matches {
+		frame := StackFrame{
+			File:     msg[m[2]:m[3]],
+			Template: msg[m[8]:m[9]],
+			Action:   msg[m[10]:m[11]],
+		}
+		frame.Line, _ = strconv.Atoi(msg[m[4]:m[5]])
+		if m[6] >= 0 {
+			frame.Column, _ = strconv.Atoi(msg[m[6]:m[7]])
+		}
+		rerr.Stack = append(rerr.Stack, frame)
+	}
+	inner := rerr.Stack[len(rerr.Stack)-1]
+	rerr.File, rerr.Line, rerr.Column = inner.File, inner.Line, inner.Column
+
+	rerr.Message = msg[matches[len(matches)-1][1]:]
+	if parts := warnRegex.FindStringSubmatch(msg); len(parts) >= 2 {
+		rerr.Message = parts[1]
+		rerr.fail = true
+		return rerr
+	}
+	return template.ExecError{Name: execErr.Name, Err: rerr}
+}
@@ -0,0 +1,205 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FunctionRequest is written as JSON to the standard input of a function
+// provider, once per call of a function.
+type FunctionRequest struct {
+	// Function is the name of the function called.
+	Function string `json:"function"`
+	// Args are the arguments of the call.
+	Args []interface{} `json:"args"`
+}
+
+// FunctionResponse is read as JSON from the standard output of a function
+// provider.
+type FunctionResponse struct {
+	// Result is the value returned to the template.
+	Result interface{} `json:"result"`
+	// Error, if set, fails the call.
+	Error string `json:"error,omitempty"`
+}
+
+// DefaultFunctionTimeout is the time a function provider has to answer a
+// call.
+const DefaultFunctionTimeout = 30 * time.Second
+
+// maxFunctionResponse is the size of the largest response read from a
+// function provider.
+const maxFunctionResponse = 4 << 20
+
+// FunctionProvider provides template functions backed by an external
+// command, usually provided by a Helm plugin. A new process of the command
+// is started for every call of a function, so calls are as slow as starting
+// the command. It reads a FunctionRequest on its standard input and must
+// write a FunctionResponse to its standard output. A non-zero exit status
+// fails the call.
+//
+// The command is sandboxed: it is started in an empty, read-only temporary
+// directory with the environment in Env, without any open file but its
+// standard input, output and error, and is killed after Timeout. On Linux, it
+// may not write to files nor open more than a few files at once, and the
+// processes it starts are killed with it. The limits are set as soon as the
+// command has started. The command still runs as the user, so it may read
+// the files and use the network the user can.
+type FunctionProvider struct {
+	name    string
+	command string
+	args    []string
+
+	// Env is the environment of the command. If nil, it only holds PATH, so
+	// that the command does not see the credentials given to Helm.
+	Env []string
+	// Timeout is the time the command has to answer a call. If zero,
+	// DefaultFunctionTimeout is used.
+	Timeout time.Duration
+}
+
+// NewFunctionProvider initializes a new FunctionProvider named name, which
+// runs command with args. A relative command is resolved against the
+// current directory.
+func NewFunctionProvider(name, command string, args []string) *FunctionProvider {
+	if filepath.Base(command) != command {
+		if abs, err := filepath.Abs(command); err == nil {
+			command = abs
+		}
+	}
+	return &FunctionProvider{
+		name:    name,
+		command: command,
+		args:    args,
+	}
+}
+
+// Name returns the name of the provider.
+func (p *FunctionProvider) Name() string {
+	return p.name
+}
+
+// Funcs returns the template functions with the given names, which call the
+// command.
+func (p *FunctionProvider) Funcs(names ...string) template.FuncMap {
+	funcs := make(template.FuncMap, len(names))
+	for _, name := range names {
+		name := name
+		funcs[name] = func(args ...interface{}) (interface{}, error) {
+			return p.call(name, args)
+		}
+	}
+	return funcs
+}
+
+// call runs the command for a call of the named function.
+func (p *FunctionProvider) call(fn string, args []interface{}) (interface{}, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	in, err := json.Marshal(FunctionRequest{Function: fn, Args: args})
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: failed to encode arguments", fn)
+	}
+
+	dir, err := os.MkdirTemp("", "helm-function-")
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: failed to create working directory", fn)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chmod(dir, 0500); err != nil {
+		return nil, errors.Wrapf(err, "%s: failed to create working directory", fn)
+	}
+	defer os.Chmod(dir, 0700)
+
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultFunctionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxFunctionResponse}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p.command, p.args...)
+	cmd.Dir = dir
+	cmd.Env = p.Env
+	if cmd.Env == nil {
+		cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	}
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	// The output is not waited for once the command exited, in case the
+	// processes it started hold on to it.
+	cmd.WaitDelay = time.Second
+	sandbox(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Errorf("%s: function provider %q failed: %s", fn, p.name, err)
+	}
+	if err := limit(cmd); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, errors.Errorf("%s: function provider %q failed: %s", fn, p.name, err)
+	}
+	err = cmd.Wait()
+	_ = killGroup(cmd)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, errors.Errorf("%s: function provider %q did not answer within %s", fn, p.name, timeout)
+		}
+		return nil, errors.Errorf("%s: function provider %q failed: %s: %s", fn, p.name, err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.exceeded {
+		return nil, errors.Errorf("%s: response of function provider %q is larger than %d bytes", fn, p.name, maxFunctionResponse)
+	}
+
+	var res FunctionResponse
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, errors.Wrapf(err, "%s: invalid response from function provider %q", fn, p.name)
+	}
+	if res.Error != "" {
+		return nil, errors.Errorf("%s: %s", fn, res.Error)
+	}
+	return res.Result, nil
+}
+
+// limitedBuffer is a buffer that discards what is written past its limit.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.exceeded || b.Len()+len(p) > b.limit {
+		b.exceeded = true
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"os/exec"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// maxFunctionOpenFiles is the number of files a function provider may have
+// open at once.
+const maxFunctionOpenFiles = 64
+
+// sandbox runs cmd in its own process group, which is killed with it. The
+// group is killed if Helm dies.
+func sandbox(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid:   true,
+		Pdeathsig: syscall.SIGKILL,
+	}
+	cmd.Cancel = func() error {
+		return killGroup(cmd)
+	}
+}
+
+// limit sets the resource limits of the started cmd: it may not write to
+// files, only to its standard output and error, and may only open a few
+// files.
+func limit(cmd *exec.Cmd) error {
+	pid := cmd.Process.Pid
+	for _, l := range []struct {
+		resource int
+		max      uint64
+	}{
+		{unix.RLIMIT_FSIZE, 0},
+		{unix.RLIMIT_NOFILE, maxFunctionOpenFiles},
+	} {
+		err := unix.Prlimit(pid, l.resource, &unix.Rlimit{Cur: l.max, Max: l.max}, nil)
+		// The command may have exited already.
+		if err != nil && err != unix.ESRCH {
+			return errors.Wrap(err, "failed to limit resources")
+		}
+	}
+	return nil
+}
+
+// killGroup kills the process group of cmd, so that the processes it
+// started do not outlive it.
+func killGroup(cmd *exec.Cmd) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build !linux
+
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import "os/exec"
+
+// sandbox does nothing: function providers are only sandboxed on Linux.
+func sandbox(_ *exec.Cmd) {}
+
+// limit does nothing: resource limits are only set on Linux.
+func limit(_ *exec.Cmd) error {
+	return nil
+}
+
+// killGroup does nothing: the processes started by a function provider are
+// only killed with it on Linux.
+func killGroup(_ *exec.Cmd) error {
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+const functionProviderEnv = "HELM_TEST_FUNCTION_PROVIDER"
+
+// TestFunctionProviderHelperProcess is not a real test. It is run as the
+// function provider by the tests below.
+func TestFunctionProviderHelperProcess(_ *testing.T) {
+	if os.Getenv(functionProviderEnv) != "1" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req FunctionRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+
+	var res FunctionResponse
+	switch req.Function {
+	case "upper":
+		res.Result = strings.ToUpper(fmt.Sprint(req.Args...))
+	case "env":
+		res.Result = os.Getenv(fmt.Sprint(req.Args...))
+	case "cwd":
+		res.Result, _ = os.Getwd()
+	case "write":
+		if err := os.WriteFile(fmt.Sprint(req.Args...), []byte("data"), 0644); err != nil {
+			res.Error = err.Error()
+		}
+	case "sleep":
+		time.Sleep(10 * time.Second)
+	case "crash":
+		os.Stderr.WriteString("out of luck")
+		os.Exit(2)
+	default:
+		res.Error = fmt.Sprintf("unknown function %q", req.Function)
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func newTestFunctionProvider() *FunctionProvider {
+	p := NewFunctionProvider("test", os.Args[0], []string{"-test.run=TestFunctionProviderHelperProcess"})
+	p.Env = []string{functionProviderEnv + "=1"}
+	return p
+}
+
+func TestFunctionProvider(t *testing.T) {
+	t.Setenv("HELM_TEST_SECRET", "s3cret")
+	p := newTestFunctionProvider()
+	if p.Name() != "test" {
+		t.Errorf("Expected name to be %q, got %q", "test", p.Name())
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		tpl      string
+		expected string
+		err      string
+	}{
+		{"result", `{{ upper "hello" }}`, "HELLO", ""},
+		{"pipeline", `{{ "hello" | upper }}`, "HELLO", ""},
+		{"environment", `{{ env "HELM_TEST_SECRET" }}`, "", ""},
+		{"error", `{{ unknown }}`, "", `unknown: unknown function "unknown"`},
+		{"exit status", `{{ crash }}`, "", `crash: function provider "test" failed: exit status 2: out of luck`},
+	}
+	funcs := p.Funcs("upper", "env", "cwd", "crash", "unknown")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := execTemplate(funcs, tt.tpl)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, out)
+			}
+		})
+	}
+
+	out, err := execTemplate(funcs, `{{ cwd }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out == wd || out == "" {
+		t.Errorf("Expected the provider to run in a temporary directory, got %q", out)
+	}
+}
+
+func TestFunctionProviderSandbox(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("function providers are only limited on Linux")
+	}
+	path := filepath.Join(t.TempDir(), "written")
+	funcs := newTestFunctionProvider().Funcs("write")
+	for _, file := range []string{"written", path} {
+		if _, err := execTemplate(funcs, fmt.Sprintf(`{{ write %q }}`, file)); err == nil {
+			t.Errorf("Expected writing %s to fail", file)
+		}
+	}
+	if _, err := os.Stat(path); err == nil {
+		if data, _ := os.ReadFile(path); len(data) > 0 {
+			t.Errorf("Expected nothing to be written to %s, got %q", path, data)
+		}
+	}
+}
+
+func TestFunctionProviderTimeout(t *testing.T) {
+	p := newTestFunctionProvider()
+	p.Timeout = 100 * time.Millisecond
+	_, err := execTemplate(p.Funcs("sleep"), `{{ sleep }}`)
+	expected := `sleep: function provider "test" did not answer within 100ms`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("Expected error containing %q, got %v", expected, err)
+	}
+}
+
+func execTemplate(funcs template.FuncMap, text string) (string, error) {
+	t, err := template.New("test").Funcs(funcs).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = t.Execute(&b, nil)
+	return b.String(), err
+}
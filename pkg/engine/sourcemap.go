@@ -0,0 +1,256 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// SourceLocation is a line of a template file.
+type SourceLocation struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// SourceMap holds, for each line of a rendered file, the template line that
+// produced it.
+type SourceMap []SourceLocation
+
+// Lookup returns the template line that produced the given line, counting
+// from 1, of the rendered file.
+func (m SourceMap) Lookup(line int) (SourceLocation, bool) {
+	if len(m) == 0 || line < 1 {
+		return SourceLocation{}, false
+	}
+	// Parsers report errors at the end of the input one line past it.
+	if line > len(m) {
+		line = len(m)
+	}
+	loc := m[line-1]
+	return loc, loc.File != ""
+}
+
+// Source markers are written to the output of instrumented templates and
+// removed once rendered, leaving the output unchanged. A marker holds the
+// file and line of the template output following it.
+const markerDelim = '\x00'
+
+// includeSourceFunc is the name 'include' is renamed to where its output,
+// markers included, is written as is.
+const includeSourceFunc = "_includeWithSource"
+
+func marker(file string, line int) string {
+	return string(markerDelim) + file + ":" + strconv.Itoa(line) + string(markerDelim)
+}
+
+// stripSourceMarkers removes the source markers from s.
+func stripSourceMarkers(s string) string {
+	if strings.IndexByte(s, markerDelim) < 0 {
+		return s
+	}
+	out, _ := extractSourceMap(s)
+	return out
+}
+
+// extractSourceMap removes the source markers from s, and returns the source
+// map of the result. A line comes from the last marker before its first
+// non-blank character.
+func extractSourceMap(s string) (string, SourceMap) {
+	var (
+		out       strings.Builder
+		sm        SourceMap
+		last      SourceLocation
+		lineLoc   SourceLocation
+		lineFixed bool
+		lineLen   int
+	)
+	// write writes a part of the current line.
+	write := func(text string) {
+		out.WriteString(text)
+		lineLen += len(text)
+		if !lineFixed && strings.TrimSpace(text) != "" {
+			lineLoc, lineFixed = last, true
+		}
+	}
+	endLine := func() {
+		if !lineFixed {
+			lineLoc = last
+		}
+		sm = append(sm, lineLoc)
+		lineFixed, lineLen = false, 0
+	}
+
+	out.Grow(len(s))
+	for {
+		i := strings.IndexByte(s, markerDelim)
+		text := s
+		if i >= 0 {
+			text = s[:i]
+		}
+		for {
+			j := strings.IndexByte(text, '\n')
+			if j < 0 {
+				break
+			}
+			write(text[:j+1])
+			endLine()
+			text = text[j+1:]
+		}
+		write(text)
+		if i < 0 {
+			break
+		}
+
+		s = s[i+1:]
+		end := strings.IndexByte(s, markerDelim)
+		if end < 0 {
+			// Not a marker after all.
+			write(string(markerDelim) + s)
+			break
+		}
+		loc, ok := parseMarker(s[:end])
+		if !ok {
+			write(string(markerDelim))
+			continue
+		}
+		last = loc
+		s = s[end+1:]
+	}
+	if lineLen > 0 {
+		endLine()
+	}
+	return out.String(), sm
+}
+
+func parseMarker(s string) (SourceLocation, bool) {
+	i := strings.LastIndexByte(s, ':')
+	if i < 0 {
+		return SourceLocation{}, false
+	}
+	line, err := strconv.Atoi(s[i+1:])
+	if err != nil {
+		return SourceLocation{}, false
+	}
+	return SourceLocation{File: s[:i], Line: line}, true
+}
+
+// instrument adds source markers to the templates parsed from tpls.
+func instrument(t *template.Template, tpls map[string]renderable) {
+	lineStarts := make(map[string][]int)
+	done := make(map[*parse.Tree]bool)
+	for _, tmpl := range t.Templates() {
+		tree := tmpl.Tree
+		if tree == nil || tree.Root == nil || done[tree] {
+			continue
+		}
+		done[tree] = true
+		r, ok := tpls[tree.ParseName]
+		if !ok {
+			continue
+		}
+		starts, ok := lineStarts[tree.ParseName]
+		if !ok {
+			starts = []int{0}
+			for i := 0; i < len(r.tpl); i++ {
+				if r.tpl[i] == '\n' {
+					starts = append(starts, i+1)
+				}
+			}
+			lineStarts[tree.ParseName] = starts
+		}
+		instrumentList(tree.Root, tree.ParseName, starts)
+	}
+}
+
+func instrumentList(list *parse.ListNode, file string, starts []int) {
+	if list == nil {
+		return
+	}
+	lineOf := func(pos parse.Pos) int {
+		return sort.SearchInts(starts, int(pos)+1)
+	}
+	nodes := make([]parse.Node, 0, 2*len(list.Nodes))
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.TextNode:
+			n.Text = markLines(n.Text, file, lineOf(n.Pos))
+		case *parse.ActionNode:
+			// Assignments have no output.
+			if len(n.Pipe.Decl) == 0 {
+				nodes = append(nodes, markerNode(n.Pos, file, lineOf(n.Pos)))
+				keepIncludeSource(n.Pipe)
+			}
+		case *parse.TemplateNode:
+			nodes = append(nodes, markerNode(n.Pos, file, lineOf(n.Pos)))
+		case *parse.IfNode:
+			instrumentList(n.List, file, starts)
+			instrumentList(n.ElseList, file, starts)
+		case *parse.RangeNode:
+			instrumentList(n.List, file, starts)
+			instrumentList(n.ElseList, file, starts)
+		case *parse.WithNode:
+			instrumentList(n.List, file, starts)
+			instrumentList(n.ElseList, file, starts)
+		}
+		nodes = append(nodes, n)
+	}
+	list.Nodes = nodes
+}
+
+// markLines adds a marker at the start of every line of text, which starts
+// at the given line.
+func markLines(text []byte, file string, line int) []byte {
+	var b strings.Builder
+	b.WriteString(marker(file, line))
+	for _, c := range text {
+		b.WriteByte(c)
+		if c == '\n' {
+			line++
+			b.WriteString(marker(file, line))
+		}
+	}
+	return []byte(b.String())
+}
+
+func markerNode(pos parse.Pos, file string, line int) *parse.TextNode {
+	return &parse.TextNode{NodeType: parse.NodeText, Pos: pos, Text: []byte(marker(file, line))}
+}
+
+// keepIncludeSource keeps the markers of an included template when it is
+// written out unchanged but for its indentation, so that its lines map to
+// the template that defines them. Elsewhere 'include' removes the markers,
+// since its output may be transformed, for example hashed.
+func keepIncludeSource(pipe *parse.PipeNode) {
+	if len(pipe.Cmds) == 0 {
+		return
+	}
+	include, ok := pipe.Cmds[0].Args[0].(*parse.IdentifierNode)
+	if !ok || include.Ident != "include" {
+		return
+	}
+	for _, cmd := range pipe.Cmds[1:] {
+		fn, ok := cmd.Args[0].(*parse.IdentifierNode)
+		if !ok || (fn.Ident != "indent" && fn.Ident != "nindent") {
+			return
+		}
+	}
+	include.Ident = includeSourceFunc
+}
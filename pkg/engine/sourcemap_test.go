@@ -0,0 +1,170 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+const sourceMapHelpers = `{{- define "labels" -}}
+app: web
+tier: {{ .Values.tier }}
+{{- end }}
+{{- define "port" }}{{ required "port is required" .Values.port }}{{ end }}`
+
+const sourceMapConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  labels:
+    {{- include "labels" . | nindent 4 }}
+  annotations:
+    checksum: {{ include "labels" . | sha256sum }}
+data:
+{{- if .Values.data }}
+  {{- toYaml .Values.data | nindent 2 }}
+{{- end }}
+  tpl: {{ tpl "{{ .Values.tier }}" . }}
+`
+
+func sourceMapChart(configMap string) (*chart.Chart, chartutil.Values) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "web"},
+		Templates: []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte(sourceMapHelpers)},
+			{Name: "templates/configmap.yaml", Data: []byte(configMap)},
+		},
+	}
+	vals := chartutil.Values{
+		"Values": map[string]interface{}{
+			"tier": "frontend",
+			"data": map[string]interface{}{"a": "1", "b": "2"},
+		},
+		"Release": chartutil.Values{"Name": "web"},
+	}
+	return c, vals
+}
+
+func TestRenderWithSourceMaps(t *testing.T) {
+	c, vals := sourceMapChart(sourceMapConfigMap)
+
+	expected, err := Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, maps, err := new(Engine).RenderWithSourceMaps(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The output is the same as without source maps.
+	if !reflect.DeepEqual(expected, out) {
+		t.Fatalf("expected %q, got %q", expected, out)
+	}
+
+	const file = "web/templates/configmap.yaml"
+	const helpers = "web/templates/_helpers.tpl"
+	lines := strings.Split(strings.TrimSuffix(out[file], "\n"), "\n")
+	sources := []SourceLocation{
+		{file, 1},    // apiVersion: v1
+		{file, 2},    // kind: ConfigMap
+		{file, 3},    // metadata:
+		{file, 4},    //   name: web
+		{file, 5},    //   labels:
+		{helpers, 2}, //     app: web
+		{helpers, 3}, //     tier: frontend
+		{file, 7},    //   annotations:
+		{file, 8},    //     checksum: ...
+		{file, 9},    // data:
+		{file, 11},   //   a: "1"
+		{file, 11},   //   b: "2"
+		{file, 13},   //   tpl: frontend
+	}
+	if len(lines) != len(sources) {
+		t.Fatalf("expected %d lines, got %q", len(sources), lines)
+	}
+	for i, expect := range sources {
+		got, ok := maps[file].Lookup(i + 1)
+		if !ok || got != expect {
+			t.Errorf("expected line %d %q to come from %v, got %v", i+1, lines[i], expect, got)
+		}
+	}
+
+	if _, ok := maps[file].Lookup(0); ok {
+		t.Error("expected no source for line 0")
+	}
+	if got, _ := maps[file].Lookup(100); got != (SourceLocation{file, 13}) {
+		t.Errorf("expected lines past the end to map to the last line, got %v", got)
+	}
+	if _, ok := maps[helpers]; ok {
+		t.Error("expected no source map for partials")
+	}
+}
+
+func TestRenderErrorStack(t *testing.T) {
+	c, vals := sourceMapChart("port: {{ include \"port\" . }}\n")
+
+	_, err := Render(c, vals)
+	var rerr *RenderError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	if err.Error() != "execution error at (web/templates/configmap.yaml:1:9): port is required" {
+		t.Errorf("unexpected error %q", err)
+	}
+	if rerr.Stage != StageExecution || rerr.Message != "port is required" {
+		t.Errorf("unexpected stage %q or message %q", rerr.Stage, rerr.Message)
+	}
+	if rerr.File != "web/templates/_helpers.tpl" || rerr.Line != 5 || rerr.Column != 23 {
+		t.Errorf("expected the error at web/templates/_helpers.tpl:5:23, got %s:%d:%d", rerr.File, rerr.Line, rerr.Column)
+	}
+	stack := []StackFrame{
+		{Template: "web/templates/configmap.yaml", File: "web/templates/configmap.yaml", Line: 1, Column: 9, Action: `include "port" .`},
+		{Template: "port", File: "web/templates/_helpers.tpl", Line: 5, Column: 23, Action: `required "port is required" .Values.port`},
+	}
+	if !reflect.DeepEqual(stack, rerr.Stack) {
+		t.Errorf("expected stack %v, got %v", stack, rerr.Stack)
+	}
+
+	// Other execution errors are still returned as a template.ExecError.
+	c, vals = sourceMapChart("apiVersion: v1\nname: {{ .Values.missing.name }}\n")
+	_, err = Render(c, vals)
+	if _, ok := err.(template.ExecError); !ok {
+		t.Fatalf("expected an exec error, got %T", err)
+	}
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	if rerr.Stage != StageExecution || rerr.File != "web/templates/configmap.yaml" || rerr.Line != 2 || err.Error() != rerr.Err.Error() {
+		t.Errorf("unexpected execution error %#v", rerr)
+	}
+
+	c, vals = sourceMapChart("apiVersion: v1\nname: {{ .Values.name | nosuchfunc }}\n")
+	_, err = Render(c, vals)
+	if !errors.As(err, &rerr) {
+		t.Fatalf("expected a render error, got %v", err)
+	}
+	if rerr.Stage != StageParse || rerr.File != "web/templates/configmap.yaml" || rerr.Line != 2 || rerr.Message != `function "nosuchfunc" not defined` {
+		t.Errorf("unexpected parse error %#v", rerr)
+	}
+}
@@ -0,0 +1,392 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+// Trace records how the templates of a chart were rendered.
+type Trace struct {
+	// Files holds the trace of each rendered file, by name.
+	Files map[string]*FileTrace `json:"files"`
+	// UnusedValues are the paths of the values that no template read.
+	UnusedValues []string `json:"unusedValues,omitempty"`
+
+	current *FileTrace
+	depth   int
+}
+
+// FileTrace is the trace of a rendered file.
+type FileTrace struct {
+	// Duration is the time spent rendering the file.
+	Duration time.Duration `json:"duration"`
+	// Calls are the calls of 'include' and 'tpl', in the order they were made.
+	Calls []TemplateCall `json:"calls,omitempty"`
+	// Values are the paths of the values read, relative to the values of the
+	// chart of the file. An empty path is a read of all values.
+	Values []string `json:"values,omitempty"`
+	// Undeclared are the paths of Values that are defined neither in the
+	// values given to the templates nor in the values files of the charts.
+	Undeclared []string `json:"undeclared,omitempty"`
+
+	// prefix is the path of the values of the chart of the file in the
+	// values of the parent chart.
+	prefix string
+	values map[string]bool
+}
+
+// TemplateCall is a call of 'include' or 'tpl'.
+type TemplateCall struct {
+	// Func is either "include" or "tpl".
+	Func string `json:"func"`
+	// Name is the name of the included template, or the text given to 'tpl'.
+	Name string `json:"name"`
+	// Args summarizes the data the template was called with.
+	Args string `json:"args"`
+	// Depth is 1 for calls made by the file, 2 for calls made by those, and
+	// so on.
+	Depth    int           `json:"depth"`
+	Duration time.Duration `json:"duration"`
+}
+
+// NewTrace creates an empty Trace.
+func NewTrace() *Trace {
+	return &Trace{Files: make(map[string]*FileTrace)}
+}
+
+// traceValuesFunc is the function that records the values read by an action.
+const traceValuesFunc = "_traceValues"
+
+// startFile starts tracing the rendering of the named file, and returns a
+// function to call once it is rendered.
+func (tr *Trace) startFile(name, basePath string) func() {
+	if tr == nil {
+		return func() {}
+	}
+	file := &FileTrace{prefix: valuesPrefix(basePath), values: make(map[string]bool)}
+	tr.Files[name] = file
+	tr.current, tr.depth = file, 0
+	start := time.Now()
+	return func() {
+		file.Duration = time.Since(start)
+		file.Values = make([]string, 0, len(file.values))
+		for p := range file.values {
+			file.Values = append(file.Values, p)
+		}
+		sort.Strings(file.Values)
+		tr.current = nil
+	}
+}
+
+// call records a call of 'include' or 'tpl', and returns a function to call
+// once it returns.
+func (tr *Trace) call(fn, name string, data interface{}) func() {
+	if tr == nil || tr.current == nil {
+		return func() {}
+	}
+	file := tr.current
+	tr.depth++
+	file.Calls = append(file.Calls, TemplateCall{
+		Func:  fn,
+		Name:  summarize(name),
+		Args:  summarizeArgs(data),
+		Depth: tr.depth,
+	})
+	i := len(file.Calls) - 1
+	start := time.Now()
+	return func() {
+		file.Calls[i].Duration = time.Since(start)
+		tr.depth--
+	}
+}
+
+// readValues records the values read by an action.
+func (tr *Trace) readValues(paths ...string) string {
+	if tr != nil && tr.current != nil {
+		for _, p := range paths {
+			tr.current.values[p] = true
+		}
+	}
+	return ""
+}
+
+// finish computes the values no template read, and the values read that are
+// not defined.
+func (tr *Trace) finish(chrt *chart.Chart, values chartutil.Values) {
+	if tr == nil {
+		return
+	}
+	vals, _ := asTable(values["Values"])
+
+	var read []string
+	for _, file := range tr.Files {
+		file.Undeclared = nil
+		for _, p := range file.Values {
+			full := file.fullPath(p)
+			read = append(read, full)
+			if !declared(vals, full) && !declaredInChart(chrt, full) {
+				file.Undeclared = append(file.Undeclared, p)
+			}
+		}
+	}
+
+	tr.UnusedValues = nil
+	for _, leaf := range valueLeaves(vals, "") {
+		used := false
+		for _, p := range read {
+			if p == "" || p == leaf || strings.HasPrefix(leaf, p+".") || strings.HasPrefix(p, leaf+".") {
+				used = true
+				break
+			}
+		}
+		if !used {
+			tr.UnusedValues = append(tr.UnusedValues, leaf)
+		}
+	}
+	sort.Strings(tr.UnusedValues)
+}
+
+// valueLeaves returns the paths of the values that are not tables.
+func valueLeaves(vals map[string]interface{}, prefix string) []string {
+	var leaves []string
+	for k, v := range vals {
+		p := prefix + k
+		table, ok := asTable(v)
+		// Coalescing adds an empty global table to every chart.
+		if ok && k == "global" && len(table) == 0 {
+			continue
+		}
+		if ok && len(table) > 0 {
+			leaves = append(leaves, valueLeaves(table, p+".")...)
+		} else {
+			leaves = append(leaves, p)
+		}
+	}
+	return leaves
+}
+
+// fullPath returns the path of a value read by the file in the values of the
+// top level chart.
+func (f *FileTrace) fullPath(p string) string {
+	if f.prefix == "" || p == "global" || strings.HasPrefix(p, "global.") {
+		return p
+	}
+	return strings.TrimSuffix(f.prefix+"."+p, ".")
+}
+
+// declared returns true if the path is defined in vals. Paths into values
+// that are not tables, like a field of a null value, are considered defined.
+func declared(vals map[string]interface{}, p string) bool {
+	if p == "" {
+		return true
+	}
+	for _, key := range strings.Split(p, ".") {
+		v, ok := vals[key]
+		if !ok {
+			return false
+		}
+		if vals, ok = asTable(v); !ok {
+			return true
+		}
+	}
+	return true
+}
+
+// declaredInChart returns true if the path is defined in the values file of
+// the chart or of its subcharts.
+func declaredInChart(c *chart.Chart, p string) bool {
+	if c == nil {
+		return false
+	}
+	if declared(c.Values, p) {
+		return true
+	}
+	for _, dep := range c.Dependencies() {
+		if strings.HasPrefix(p, "global.") && declaredInChart(dep, p) {
+			return true
+		}
+		if rest, ok := strings.CutPrefix(p, dep.Name()+"."); ok && declaredInChart(dep, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+func asTable(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return v, true
+	case chartutil.Values:
+		return v, true
+	}
+	return nil, false
+}
+
+// valuesPrefix returns the path of the values of the chart of a template in
+// the values of the top level chart, from the base path of the template.
+func valuesPrefix(basePath string) string {
+	parts := strings.Split(path.Dir(basePath), "/")
+	var prefix []string
+	for i := 1; i < len(parts)-1; i++ {
+		if parts[i] == "charts" {
+			prefix = append(prefix, parts[i+1])
+		}
+	}
+	return strings.Join(prefix, ".")
+}
+
+const maxSummary = 40
+
+func summarize(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxSummary {
+		s = s[:maxSummary-3] + "..."
+	}
+	return s
+}
+
+// summarizeArgs summarizes the data a template is called with.
+func summarizeArgs(data interface{}) string {
+	var table map[string]interface{}
+	switch d := data.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(summarize(d))
+	case chartutil.Values:
+		table = d
+	case map[string]interface{}:
+		table = d
+	default:
+		return summarize(fmt.Sprintf("%v", d))
+	}
+	if _, ok := table["Values"]; ok {
+		if _, ok := table["Release"]; ok {
+			return "."
+		}
+	}
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return summarize("dict " + strings.Join(keys, " "))
+}
+
+// instrumentValues adds a call of traceValuesFunc before the actions of the
+// templates that read values, so that the values are recorded only when the
+// actions are executed.
+func instrumentValues(t *template.Template) {
+	done := make(map[*parse.Tree]bool)
+	for _, tmpl := range t.Templates() {
+		tree := tmpl.Tree
+		if tree == nil || tree.Root == nil || done[tree] {
+			continue
+		}
+		done[tree] = true
+		instrumentValuesList(tree.Root)
+	}
+}
+
+func instrumentValuesList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	nodes := make([]parse.Node, 0, len(list.Nodes))
+	for _, n := range list.Nodes {
+		var paths []string
+		switch n := n.(type) {
+		case *parse.ActionNode:
+			paths = valuePaths(n.Pipe, nil)
+		case *parse.TemplateNode:
+			paths = valuePaths(n.Pipe, nil)
+		case *parse.IfNode:
+			paths = valuePaths(n.Pipe, nil)
+			instrumentValuesList(n.List)
+			instrumentValuesList(n.ElseList)
+		case *parse.RangeNode:
+			paths = valuePaths(n.Pipe, nil)
+			instrumentValuesList(n.List)
+			instrumentValuesList(n.ElseList)
+		case *parse.WithNode:
+			paths = valuePaths(n.Pipe, nil)
+			instrumentValuesList(n.List)
+			instrumentValuesList(n.ElseList)
+		}
+		if len(paths) > 0 {
+			nodes = append(nodes, traceValuesNode(n.Position(), paths))
+		}
+		nodes = append(nodes, n)
+	}
+	list.Nodes = nodes
+}
+
+// valuePaths returns the paths of the values read with .Values or
+// $var.Values in node.
+func valuePaths(node parse.Node, paths []string) []string {
+	switch n := node.(type) {
+	case *parse.FieldNode:
+		if n.Ident[0] == "Values" {
+			paths = append(paths, strings.Join(n.Ident[1:], "."))
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[1] == "Values" {
+			paths = append(paths, strings.Join(n.Ident[2:], "."))
+		}
+	case *parse.ChainNode:
+		paths = valuePaths(n.Node, paths)
+	case *parse.PipeNode:
+		if n == nil {
+			return paths
+		}
+		for _, cmd := range n.Cmds {
+			paths = valuePaths(cmd, paths)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			paths = valuePaths(arg, paths)
+		}
+	}
+	return paths
+}
+
+func traceValuesNode(pos parse.Pos, paths []string) *parse.ActionNode {
+	args := []parse.Node{&parse.IdentifierNode{NodeType: parse.NodeIdentifier, Pos: pos, Ident: traceValuesFunc}}
+	for _, p := range paths {
+		args = append(args, &parse.StringNode{NodeType: parse.NodeString, Pos: pos, Quoted: strconv.Quote(p), Text: p})
+	}
+	return &parse.ActionNode{
+		NodeType: parse.NodeAction,
+		Pos:      pos,
+		Pipe: &parse.PipeNode{
+			NodeType: parse.NodePipe,
+			Pos:      pos,
+			Cmds:     []*parse.CommandNode{{NodeType: parse.NodeCommand, Pos: pos, Args: args}},
+		},
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+)
+
+func TestRenderTrace(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "web"},
+		Templates: []*chart.File{
+			{Name: "templates/_helpers.tpl", Data: []byte(`{{- define "labels" -}}
+app: {{ .Values.name }}
+{{ include "tier" (dict "tier" .Values.tier) }}
+{{- end }}
+{{- define "tier" }}tier: {{ .tier }}{{ end }}`)},
+			{Name: "templates/configmap.yaml", Data: []byte(`metadata:
+  labels:
+    {{- include "labels" . | nindent 4 }}
+{{- if .Values.debug }}
+  debug: {{ .Values.debugLevel }}
+{{- end }}
+{{- with $.Values.data }}
+data:
+  {{- toYaml . | nindent 2 }}
+{{- end }}
+  greeting: {{ tpl "{{ .Values.greeting }}" . }}
+`)},
+		},
+	}
+	sub := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "sub"},
+		Templates: []*chart.File{{Name: "templates/sub.yaml", Data: []byte(`image: {{ .Values.registry }}/{{ .Values.image }}
+pullPolicy: {{ .Values.pullPolicy }}`)}},
+		// A null default is not in the coalesced values, but is declared.
+		Values: map[string]interface{}{"registry": nil},
+	}
+	c.AddDependency(sub)
+
+	vals := chartutil.Values{
+		"Values": map[string]interface{}{
+			"name":       "web",
+			"tier":       "frontend",
+			"debug":      false,
+			"debugLevel": 3,
+			"greeting":   "hello",
+			"data":       map[string]interface{}{"a": "1"},
+			"unused":     map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": 2}},
+			"sub":        map[string]interface{}{"image": "nginx", "tag": "1.0"},
+		},
+		"Release": chartutil.Values{"Name": "web"},
+	}
+
+	e := new(Engine)
+	e.Trace = NewTrace()
+	out, err := e.Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := Render(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, out) {
+		t.Fatalf("expected tracing not to change the output %q, got %q", expected, out)
+	}
+	out, _, err = e.RenderWithSourceMaps(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, out) {
+		t.Fatalf("expected tracing with source maps not to change the output %q, got %q", expected, out)
+	}
+
+	file := e.Trace.Files["web/templates/configmap.yaml"]
+	if file == nil {
+		t.Fatalf("expected a trace of the config map, got %v", e.Trace.Files)
+	}
+	var calls []TemplateCall
+	for _, call := range file.Calls {
+		call.Duration = 0
+		calls = append(calls, call)
+	}
+	expectCalls := []TemplateCall{
+		{Func: "include", Name: "labels", Args: ".", Depth: 1},
+		{Func: "include", Name: "tier", Args: "dict tier", Depth: 2},
+		{Func: "tpl", Name: "{{ .Values.greeting }}", Args: ".", Depth: 1},
+	}
+	if !reflect.DeepEqual(expectCalls, calls) {
+		t.Errorf("expected calls %v, got %v", expectCalls, calls)
+	}
+	// debugLevel is not read, as debug is false.
+	expectValues := []string{"data", "debug", "greeting", "name", "tier"}
+	if !reflect.DeepEqual(expectValues, file.Values) {
+		t.Errorf("expected values %v, got %v", expectValues, file.Values)
+	}
+	if len(file.Undeclared) != 0 {
+		t.Errorf("expected the config map to read only declared values, got %v", file.Undeclared)
+	}
+	subFile := e.Trace.Files["web/charts/sub/templates/sub.yaml"]
+	if expect := []string{"image", "pullPolicy", "registry"}; !reflect.DeepEqual(expect, subFile.Values) {
+		t.Errorf("expected the subchart to read %v, got %v", expect, subFile.Values)
+	}
+	if expect := []string{"pullPolicy"}; !reflect.DeepEqual(expect, subFile.Undeclared) {
+		t.Errorf("expected undeclared values %v, got %v", expect, subFile.Undeclared)
+	}
+
+	expectUnused := []string{"debugLevel", "sub.tag", "unused.a", "unused.b.c"}
+	if !reflect.DeepEqual(expectUnused, e.Trace.UnusedValues) {
+		t.Errorf("expected unused values %v, got %v", expectUnused, e.Trace.UnusedValues)
+	}
+}
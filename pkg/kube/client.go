@@ -81,6 +81,12 @@ type Client struct {
 	Log     func(string, ...interface{})
 	// Namespace allows to bypass the kubeconfig file for the choice of the namespace
 	Namespace string
+	// ReadyCheckerOptions are applied to the ReadyChecker used by Wait and
+	// WaitWithJobs, e.g. to register a StatusCheck for a custom resource.
+	ReadyCheckerOptions []ReadyCheckerOption
+	// WaitProgress, if set, is called with the status of every resource each
+	// time Wait or WaitWithJobs polls the cluster.
+	WaitProgress func([]ResourceStatus)
 
 	kubeClient *kubernetes.Clientset
 }
@@ -286,11 +292,13 @@ func (c *Client) Wait(resources ResourceList, timeout time.Duration) error {
 	if err != nil {
 		return err
 	}
-	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true))
+	opts := append([]ReadyCheckerOption{PausedAsReady(true)}, c.ReadyCheckerOptions...)
+	checker := NewReadyChecker(cs, c.Log, opts...)
 	w := waiter{
-		c:       checker,
-		log:     c.Log,
-		timeout: timeout,
+		c:        checker,
+		log:      c.Log,
+		timeout:  timeout,
+		progress: c.WaitProgress,
 	}
 	return w.waitForResources(resources)
 }
@@ -301,11 +309,13 @@ func (c *Client) WaitWithJobs(resources ResourceList, timeout time.Duration) err
 	if err != nil {
 		return err
 	}
-	checker := NewReadyChecker(cs, c.Log, PausedAsReady(true), CheckJobs(true))
+	opts := append([]ReadyCheckerOption{PausedAsReady(true), CheckJobs(true)}, c.ReadyCheckerOptions...)
+	checker := NewReadyChecker(cs, c.Log, opts...)
 	w := waiter{
-		c:       checker,
-		log:     c.Log,
-		timeout: timeout,
+		c:        checker,
+		log:      c.Log,
+		timeout:  timeout,
+		progress: c.WaitProgress,
 	}
 	return w.waitForResources(resources)
 }
@@ -437,6 +447,72 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 		return res, errors.Errorf(strings.Join(updateErrors, " && "))
 	}
 
+	c.deleteStale(original, target, res)
+	return res, nil
+}
+
+// UpdateServerSide applies the target list of objects with server-side apply
+// using the field manager returned by getManagedFieldsManager. Resources that
+// don't already exist are created by the apply, and resources from the
+// original configuration that are not present in the target configuration are
+// deleted. If applying a resource would take over fields owned by another
+// field manager, a *ConflictError is returned for it unless forceConflicts is
+// set, in which case ownership of those fields is transferred.
+func (c *Client) UpdateServerSide(original, target ResourceList, forceConflicts bool) (*Result, error) {
+	var applyErrors error
+	res := &Result{}
+
+	c.Log("applying %d resources with server-side apply", len(target))
+	err := target.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+
+		helper := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager())
+		exists := true
+		if _, err := helper.Get(info.Namespace, info.Name); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return errors.Wrap(err, "could not get information about the resource")
+			}
+			exists = false
+		}
+
+		kind := info.Mapping.GroupVersionKind.Kind
+		if !exists {
+			// Append the created resource to the results, even if something fails
+			res.Created = append(res.Created, info)
+			if err := applyResource(info, forceConflicts); err != nil {
+				return err
+			}
+			c.Log("Created a new %s called %q in %s\n", kind, info.Name, info.Namespace)
+			return nil
+		}
+
+		if err := applyResource(info, forceConflicts); err != nil {
+			c.Log("error applying the resource %q:\n\t %v", info.Name, err)
+			applyErrors = multierror.Append(applyErrors, err)
+		}
+		// Because we check for errors later, append the info regardless
+		res.Updated = append(res.Updated, info)
+
+		return nil
+	})
+
+	switch {
+	case err != nil:
+		return res, err
+	case applyErrors != nil:
+		return res, applyErrors
+	}
+
+	c.deleteStale(original, target, res)
+	return res, nil
+}
+
+// deleteStale deletes the resources from the original configuration that are
+// not present in the target configuration, honoring the resource policy
+// annotation, and records them in res.
+func (c *Client) deleteStale(original, target ResourceList, res *Result) {
 	for _, info := range original.Difference(target) {
 		c.Log("Deleting %s %q in namespace %s...", info.Mapping.GroupVersionKind.Kind, info.Name, info.Namespace)
 
@@ -458,7 +534,6 @@ func (c *Client) Update(original, target ResourceList, force bool) (*Result, err
 		}
 		res.Deleted = append(res.Deleted, info)
 	}
-	return res, nil
 }
 
 // Delete deletes Kubernetes resources specified in the resources list with
@@ -602,6 +677,22 @@ func createResource(info *resource.Info) error {
 	return info.Refresh(obj, true)
 }
 
+func applyResource(info *resource.Info, forceConflicts bool) error {
+	data, err := json.Marshal(info.Object)
+	if err != nil {
+		return errors.Wrap(err, "serializing target configuration")
+	}
+	opts := &metav1.PatchOptions{Force: &forceConflicts}
+	obj, err := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager()).Patch(info.Namespace, info.Name, types.ApplyPatchType, data, opts)
+	if err != nil {
+		if apierrors.IsConflict(err) {
+			return newConflictError(info, err)
+		}
+		return errors.Wrapf(err, "cannot apply %q with kind %s", info.Name, info.Mapping.GroupVersionKind.Kind)
+	}
+	return info.Refresh(obj, true)
+}
+
 func deleteResource(info *resource.Info, policy metav1.DeletionPropagation) error {
 	opts := &metav1.DeleteOptions{PropagationPolicy: &policy}
 	_, err := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager()).DeleteWithOptions(info.Namespace, info.Name, opts)
@@ -631,6 +722,13 @@ func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.P
 		return nil, types.StrategicMergePatchType, errors.Wrap(err, "serializing live configuration")
 	}
 
+	return createThreeWayPatch(target, oldData, newData, currentData)
+}
+
+// createThreeWayPatch computes the patch that moves the live object towards
+// the target while preserving changes made to fields that the original
+// configuration did not set.
+func createThreeWayPatch(target *resource.Info, oldData, newData, currentData []byte) ([]byte, types.PatchType, error) {
 	// Get a versioned object
 	versionedObject := AsVersioned(target)
 
@@ -658,6 +756,47 @@ func createPatch(target *resource.Info, current runtime.Object) ([]byte, types.P
 	return patch, types.StrategicMergePatchType, err
 }
 
+// MergeLive returns the object that Update would leave in the cluster when
+// moving live from original to target. It applies the same three-way patch
+// computed by Update to the live object instead of sending it to the API
+// server, which makes it suitable for previewing changes.
+func MergeLive(target *resource.Info, original, live runtime.Object) (*unstructured.Unstructured, error) {
+	oldData, err := json.Marshal(original)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing current configuration")
+	}
+	newData, err := json.Marshal(target.Object)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing target configuration")
+	}
+	liveData, err := json.Marshal(live)
+	if err != nil {
+		return nil, errors.Wrap(err, "serializing live configuration")
+	}
+
+	patch, patchType, err := createThreeWayPatch(target, oldData, newData, liveData)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create patch")
+	}
+
+	var merged []byte
+	switch patchType {
+	case types.MergePatchType:
+		merged, err = jsonpatch.MergePatch(liveData, patch)
+	default:
+		merged, err = strategicpatch.StrategicMergePatch(liveData, patch, AsVersioned(target))
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to apply patch to %s/%s", target.Namespace, target.Name)
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(merged); err != nil {
+		return nil, errors.Wrap(err, "decoding merged configuration")
+	}
+	return obj, nil
+}
+
 func updateResource(c *Client, target *resource.Info, currentObj runtime.Object, force bool) error {
 	var (
 		obj    runtime.Object
@@ -23,9 +23,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest/fake"
@@ -213,6 +215,151 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestUpdateServerSide(t *testing.T) {
+	listA := newPodList("starfish", "otter", "squid")
+	listB := newPodList("starfish", "otter", "dolphin")
+
+	var actions []string
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			actions = append(actions, p+":"+m)
+			t.Logf("got request %s %s", p, m)
+			switch {
+			case p == "/namespaces/default/pods/starfish" && m == "GET":
+				return newResponse(200, &listA.Items[0])
+			case p == "/namespaces/default/pods/otter" && m == "GET":
+				return newResponse(200, &listA.Items[1])
+			case p == "/namespaces/default/pods/dolphin" && m == "GET":
+				return newResponse(404, notFoundBody())
+			case strings.HasPrefix(p, "/namespaces/default/pods/") && m == "PATCH":
+				if ct := req.Header.Get("Content-Type"); ct != string(types.ApplyPatchType) {
+					t.Errorf("expected content type %s, got %s", types.ApplyPatchType, ct)
+				}
+				if req.URL.Query().Get("force") != "true" {
+					t.Errorf("expected force=true, got %q", req.URL.Query().Get("force"))
+				}
+				if req.URL.Query().Get("fieldManager") == "" {
+					t.Error("expected a field manager")
+				}
+				name := strings.TrimPrefix(p, "/namespaces/default/pods/")
+				pod := newPod(name)
+				return newResponse(200, &pod)
+			case p == "/namespaces/default/pods/squid" && m == "DELETE":
+				return newResponse(200, &listA.Items[2])
+			case p == "/namespaces/default/pods/squid" && m == "GET":
+				return newResponse(200, &listA.Items[2])
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	first, err := c.Build(objBody(&listA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Build(objBody(&listB), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.UpdateServerSide(first, second, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Created) != 1 {
+		t.Errorf("expected 1 resource created, got %d", len(result.Created))
+	}
+	if len(result.Updated) != 2 {
+		t.Errorf("expected 2 resource updated, got %d", len(result.Updated))
+	}
+	if len(result.Deleted) != 1 {
+		t.Errorf("expected 1 resource deleted, got %d", len(result.Deleted))
+	}
+
+	expectedActions := []string{
+		"/namespaces/default/pods/starfish:GET",
+		"/namespaces/default/pods/starfish:PATCH",
+		"/namespaces/default/pods/otter:GET",
+		"/namespaces/default/pods/otter:PATCH",
+		"/namespaces/default/pods/dolphin:GET",
+		"/namespaces/default/pods/dolphin:PATCH",
+		"/namespaces/default/pods/squid:GET",
+		"/namespaces/default/pods/squid:DELETE",
+	}
+	if len(expectedActions) != len(actions) {
+		t.Fatalf("unexpected number of requests, expected %d, got %d", len(expectedActions), len(actions))
+	}
+	for k, v := range expectedActions {
+		if actions[k] != v {
+			t.Errorf("expected %s request got %s", v, actions[k])
+		}
+	}
+}
+
+func TestUpdateServerSideConflict(t *testing.T) {
+	list := newPodList("starfish")
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			switch {
+			case p == "/namespaces/default/pods/starfish" && m == "GET":
+				return newResponse(200, &list.Items[0])
+			case p == "/namespaces/default/pods/starfish" && m == "PATCH":
+				return newResponse(http.StatusConflict, &metav1.Status{
+					Status: metav1.StatusFailure,
+					Code:   http.StatusConflict,
+					Reason: metav1.StatusReasonConflict,
+					Details: &metav1.StatusDetails{
+						Causes: []metav1.StatusCause{{
+							Type:    metav1.CauseTypeFieldManagerConflict,
+							Message: `conflict with "kubectl-edit" using v1`,
+							Field:   ".spec.containers[name=\"app:v4\"].image",
+						}},
+					},
+				})
+			default:
+				t.Fatalf("unexpected request: %s %s", req.Method, req.URL.Path)
+				return nil, nil
+			}
+		}),
+	}
+	target, err := c.Build(objBody(&list), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = c.UpdateServerSide(target, target, false)
+	if err == nil {
+		t.Fatal("expected a conflict error")
+	}
+
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("expected a *ConflictError, got %T: %s", err, err)
+	}
+	if conflict.Name != "starfish" || conflict.Kind != "Pod" {
+		t.Errorf("unexpected conflicting resource %s %s", conflict.Kind, conflict.Name)
+	}
+	if len(conflict.Conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(conflict.Conflicts))
+	}
+	if conflict.Conflicts[0].Manager != "kubectl-edit" {
+		t.Errorf("expected manager kubectl-edit, got %q", conflict.Conflicts[0].Manager)
+	}
+	if !strings.Contains(err.Error(), "kubectl-edit") {
+		t.Errorf("expected error to name the conflicting manager, got %q", err)
+	}
+}
+
 func TestBuild(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,99 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// FieldConflict describes a single field whose ownership conflicts with
+// another field manager during server-side apply.
+type FieldConflict struct {
+	// Manager is the field manager that currently owns the field.
+	Manager string `json:"manager,omitempty"`
+	// Field is the path of the conflicting field, e.g. ".spec.replicas".
+	Field string `json:"field"`
+	// Message is the message reported by the API server.
+	Message string `json:"message"`
+}
+
+// ConflictError is returned when server-side apply of a resource conflicts
+// with fields owned by other field managers.
+type ConflictError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Conflicts []FieldConflict
+
+	err error
+}
+
+func (e *ConflictError) Error() string {
+	fields := make([]string, 0, len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		if c.Manager != "" {
+			fields = append(fields, fmt.Sprintf("%s (owned by %q)", c.Field, c.Manager))
+		} else {
+			fields = append(fields, c.Field)
+		}
+	}
+	if len(fields) == 0 {
+		return fmt.Sprintf("conflict applying %s %q in namespace %q: %s", e.Kind, e.Name, e.Namespace, e.err)
+	}
+	return fmt.Sprintf("conflict applying %s %q in namespace %q: %s", e.Kind, e.Name, e.Namespace, strings.Join(fields, ", "))
+}
+
+// Unwrap returns the error reported by the API server.
+func (e *ConflictError) Unwrap() error {
+	return e.err
+}
+
+// conflictManager extracts the field manager from a conflict message such as
+// `conflict with "kubectl" using apps/v1`.
+var conflictManager = regexp.MustCompile(`conflict with "([^"]*)"`)
+
+func newConflictError(info *resource.Info, err error) *ConflictError {
+	ce := &ConflictError{
+		Kind:      info.Mapping.GroupVersionKind.Kind,
+		Namespace: info.Namespace,
+		Name:      info.Name,
+		err:       err,
+	}
+
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) || status.Status().Details == nil {
+		return ce
+	}
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		c := FieldConflict{Field: cause.Field, Message: cause.Message}
+		if m := conflictManager.FindStringSubmatch(cause.Message); m != nil {
+			c.Manager = m[1]
+		}
+		ce.Conflicts = append(ce.Conflicts, c)
+	}
+	return ce
+}
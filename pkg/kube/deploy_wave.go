@@ -0,0 +1,63 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// DeployWaveAnno is the annotation name for the deployment wave of a resource.
+//
+// Resources are applied wave by wave in ascending order, and deleted in
+// descending order. Resources without the annotation are in wave 0.
+const DeployWaveAnno = "helm.sh/deploy-wave"
+
+// DeployWaves splits the resources into waves by their deploy wave annotation.
+// The waves are sorted in ascending order and keep the order of the resources
+// within each wave.
+func DeployWaves(resources ResourceList) ([]ResourceList, error) {
+	byWave := map[int]ResourceList{}
+	for _, info := range resources {
+		wave := 0
+		accessor, err := meta.Accessor(info.Object)
+		if err == nil {
+			if v, ok := accessor.GetAnnotations()[DeployWaveAnno]; ok {
+				wave, err = strconv.Atoi(v)
+				if err != nil {
+					return nil, errors.Errorf("invalid %s annotation %q on %s %q: must be an integer", DeployWaveAnno, v, info.Mapping.GroupVersionKind.Kind, info.Name)
+				}
+			}
+		}
+		byWave[wave] = append(byWave[wave], info)
+	}
+
+	keys := make([]int, 0, len(byWave))
+	for k := range byWave {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	waves := make([]ResourceList, 0, len(keys))
+	for _, k := range keys {
+		waves = append(waves, byWave[k])
+	}
+	return waves, nil
+}
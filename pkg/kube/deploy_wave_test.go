@@ -0,0 +1,74 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+func TestDeployWaves(t *testing.T) {
+	info := func(name, wave string) *resource.Info {
+		obj := &unstructured.Unstructured{}
+		obj.SetName(name)
+		if wave != "" {
+			obj.SetAnnotations(map[string]string{DeployWaveAnno: wave})
+		}
+		return &resource.Info{
+			Name:    name,
+			Object:  obj,
+			Mapping: &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}},
+		}
+	}
+
+	waves, err := DeployWaves(ResourceList{info("app", "10"), info("db", "-1"), info("config", ""), info("secret", "0"), info("api", "10")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got [][]string
+	for _, wave := range waves {
+		var names []string
+		for _, r := range wave {
+			names = append(names, r.Name)
+		}
+		got = append(got, names)
+	}
+	expect := [][]string{{"db"}, {"config", "secret"}, {"app", "api"}}
+	if len(got) != len(expect) {
+		t.Fatalf("expected waves %v, got %v", expect, got)
+	}
+	for i := range expect {
+		if len(got[i]) != len(expect[i]) {
+			t.Fatalf("expected waves %v, got %v", expect, got)
+		}
+		for j := range expect[i] {
+			if got[i][j] != expect[i][j] {
+				t.Fatalf("expected waves %v, got %v", expect, got)
+			}
+		}
+	}
+
+	_, err = DeployWaves(ResourceList{info("app", "first")})
+	if err == nil || err.Error() != `invalid helm.sh/deploy-wave annotation "first" on ConfigMap "app": must be an integer` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -101,6 +101,30 @@ func (p *PrintingKubeClient) Update(_, modified kube.ResourceList, _ bool) (*kub
 	return &kube.Result{Updated: modified}, nil
 }
 
+// UpdateServerSide implements KubeClient UpdateServerSide.
+func (p *PrintingKubeClient) UpdateServerSide(_, modified kube.ResourceList, _ bool) (*kube.Result, error) {
+	_, err := io.Copy(p.Out, bufferize(modified))
+	if err != nil {
+		return nil, err
+	}
+	return &kube.Result{Updated: modified}, nil
+}
+
+// Plan implements KubeClient Plan.
+//
+// Every target resource is created and every other original resource is
+// deleted.
+func (p *PrintingKubeClient) Plan(original, target kube.ResourceList, _, _ bool) ([]kube.PlannedChange, error) {
+	var changes []kube.PlannedChange
+	for _, info := range target {
+		changes = append(changes, plannedChange(kube.PlanCreate, info))
+	}
+	for _, info := range original.Difference(target) {
+		changes = append(changes, plannedChange(kube.PlanDelete, info))
+	}
+	return changes, nil
+}
+
 // Build implements KubeClient Build.
 func (p *PrintingKubeClient) Build(_ io.Reader, _ bool) (kube.ResourceList, error) {
 	return []*resource.Info{}, nil
@@ -127,6 +151,17 @@ func (p *PrintingKubeClient) DeleteWithPropagationPolicy(resources kube.Resource
 	return &kube.Result{Deleted: resources}, nil
 }
 
+func plannedChange(action kube.PlanAction, info *resource.Info) kube.PlannedChange {
+	gvk := info.Mapping.GroupVersionKind
+	return kube.PlannedChange{
+		Action:     action,
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  info.Namespace,
+		Name:       info.Name,
+	}
+}
+
 func bufferize(resources kube.ResourceList) io.Reader {
 	var builder strings.Builder
 	for _, info := range resources {
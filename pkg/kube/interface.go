@@ -110,7 +110,40 @@ type InterfaceResources interface {
 	BuildTable(reader io.Reader, validate bool) (ResourceList, error)
 }
 
+// InterfaceServerSideApply is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceServerSideApply and integrate its method(s) into the Interface.
+type InterfaceServerSideApply interface {
+	// UpdateServerSide applies the target resources with server-side apply
+	// and deletes the resources in original that are not in target.
+	//
+	// Field ownership conflicts with other field managers are returned as a
+	// *ConflictError unless forceConflicts is true.
+	UpdateServerSide(original, target ResourceList, forceConflicts bool) (*Result, error)
+}
+
+// InterfaceLogs is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfaceLogs and integrate its method(s) into the Interface.
+type InterfaceLogs interface {
+	// Logs returns the logs of the containers of the Pods and of the Pods run
+	// by the Jobs in resources, keeping at most limit bytes of each log.
+	Logs(resources ResourceList, limit int64) ([]ContainerLog, error)
+}
+
+// InterfacePlan is introduced to avoid breaking backwards compatibility for Interface implementers.
+//
+// TODO Helm 4: Remove InterfacePlan and integrate its method(s) into the Interface.
+type InterfacePlan interface {
+	// Plan returns the changes an update from original to target would make,
+	// without modifying anything. serverSide plans an UpdateServerSide.
+	Plan(original, target ResourceList, force, serverSide bool) ([]PlannedChange, error)
+}
+
 var _ Interface = (*Client)(nil)
 var _ InterfaceExt = (*Client)(nil)
 var _ InterfaceDeletionPropagation = (*Client)(nil)
 var _ InterfaceResources = (*Client)(nil)
+var _ InterfaceServerSideApply = (*Client)(nil)
+var _ InterfaceLogs = (*Client)(nil)
+var _ InterfacePlan = (*Client)(nil)
@@ -0,0 +1,270 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	coordinationclient "k8s.io/client-go/kubernetes/typed/coordination/v1"
+)
+
+// LockedError is returned when a release is locked by another holder.
+type LockedError struct {
+	Release string
+	Holder  string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("release %q is locked by %q", e.Release, e.Holder)
+}
+
+// ErrNotLocked is returned when unlocking a release which is not locked.
+var ErrNotLocked = errors.New("release is not locked")
+
+// LeaseLocker locks releases with coordination.k8s.io Leases in the release
+// namespace. A lock is renewed while it is held, so the lock of a process
+// which died expires after LeaseDuration.
+type LeaseLocker struct {
+	client    func() (kubernetes.Interface, error)
+	namespace string
+
+	// LeaseDuration is how long a lock is valid without being renewed.
+	LeaseDuration time.Duration
+	// PollInterval is how often a locked release is checked while waiting.
+	PollInterval time.Duration
+	Log          func(string, ...interface{})
+}
+
+// NewLeaseLocker creates a LeaseLocker storing its leases in the given
+// namespace. The client is only loaded once a release is locked.
+func NewLeaseLocker(client func() (kubernetes.Interface, error), namespace string) *LeaseLocker {
+	return &LeaseLocker{
+		client:        client,
+		namespace:     namespace,
+		LeaseDuration: time.Minute,
+		PollInterval:  time.Second,
+		Log:           func(_ string, _ ...interface{}) {},
+	}
+}
+
+func leaseName(release string) string {
+	return "sh.helm.release.lock.v1." + release
+}
+
+// Lock acquires the lock of the named release for holder, waiting up to
+// timeout for another holder to release it. It returns a function releasing
+// the lock, or a *LockedError if the release is still locked after timeout.
+//
+// The lock is renewed while it is held. If it is lost, because it was
+// removed, taken over or could not be renewed before it expired, lost is
+// called with the reason, if it is not nil.
+//
+// If the client is not allowed to manage Leases, the release is not locked
+// and a warning is logged.
+func (l *LeaseLocker) Lock(release, holder string, timeout time.Duration, lost func(error)) (func() error, error) {
+	client, err := l.client()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to lock release")
+	}
+	leases := client.CoordinationV1().Leases(l.namespace)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		lease, err := l.acquire(leases, release, holder)
+		if apierrors.IsForbidden(err) {
+			l.Log("warning: not locking release %q: %s", release, err)
+			return func() error { return nil }, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to lock release %q", release)
+		}
+		if leaseHolder(lease) == holder {
+			return l.keep(leases, release, lease, lost), nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, &LockedError{Release: release, Holder: leaseHolder(lease)}
+		}
+		l.Log("release %q is locked, waiting", release)
+		wait := l.PollInterval
+		if remaining := time.Until(deadline); remaining < wait {
+			wait = remaining
+		}
+		time.Sleep(wait)
+	}
+}
+
+// acquire tries once to take the lease of the release. It returns the
+// current lease, which is held by holder if it was acquired. Conflicting
+// writes are reported as a lease held by someone else.
+func (l *LeaseLocker) acquire(leases coordinationclient.LeaseInterface, release, holder string) (*coordinationv1.Lease, error) {
+	ctx := context.Background()
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(l.LeaseDuration / time.Second)
+
+	lease, err := leases.Get(ctx, leaseName(release), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   leaseName(release),
+				Labels: map[string]string{"name": release, "owner": "helm"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &holder,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}
+		created, err := leases.Create(ctx, lease, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return leases.Get(ctx, leaseName(release), metav1.GetOptions{})
+		}
+		return created, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if other := leaseHolder(lease); other != "" && other != holder && !leaseExpired(lease) {
+		return lease, nil
+	}
+	lease.Spec.HolderIdentity = &holder
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.AcquireTime = &now
+	lease.Spec.RenewTime = &now
+	updated, err := leases.Update(ctx, lease, metav1.UpdateOptions{})
+	if apierrors.IsConflict(err) {
+		return leases.Get(ctx, leaseName(release), metav1.GetOptions{})
+	}
+	return updated, err
+}
+
+func leaseHolder(lease *coordinationv1.Lease) string {
+	if lease == nil || lease.Spec.HolderIdentity == nil {
+		return ""
+	}
+	return *lease.Spec.HolderIdentity
+}
+
+func leaseExpired(lease *coordinationv1.Lease) bool {
+	if lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return true
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return time.Now().After(expiry)
+}
+
+// keep renews the acquired lease until the returned function is called,
+// which deletes it. It stops renewing the lease and calls lost once the lease
+// is lost.
+func (l *LeaseLocker) keep(leases coordinationclient.LeaseInterface, release string, lease *coordinationv1.Lease, lost func(error)) func() error {
+	if lost == nil {
+		lost = func(error) {}
+	}
+	var mu sync.Mutex
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(l.LeaseDuration / 3)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				mu.Lock()
+				err := l.renew(leases, &lease)
+				mu.Unlock()
+				switch {
+				case err == nil:
+					renewed = time.Now()
+				case apierrors.IsNotFound(err) || apierrors.IsConflict(err):
+					lost(errors.Errorf("lock of release %q was removed or taken over", release))
+					return
+				case time.Since(renewed) >= l.LeaseDuration:
+					lost(errors.Wrapf(err, "lock of release %q expired as it could not be renewed", release))
+					return
+				default:
+					l.Log("warning: failed to renew lock of release %q: %s", release, err)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() {
+			close(done)
+			mu.Lock()
+			defer mu.Unlock()
+			err = leases.Delete(context.Background(), lease.Name, metav1.DeleteOptions{
+				Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+			})
+			if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+				// The lock was removed or taken over in the meantime.
+				err = nil
+			}
+		})
+		return err
+	}
+}
+
+// renew renews the lease.
+func (l *LeaseLocker) renew(leases coordinationclient.LeaseInterface, lease **coordinationv1.Lease) error {
+	now := metav1.NewMicroTime(time.Now())
+	update := (*lease).DeepCopy()
+	update.Spec.RenewTime = &now
+	renewed, err := leases.Update(context.Background(), update, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	*lease = renewed
+	return nil
+}
+
+// Unlock removes the lock of the named release regardless of its holder and
+// returns the holder. It returns ErrNotLocked if the release is not locked.
+func (l *LeaseLocker) Unlock(release string) (string, error) {
+	client, err := l.client()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to unlock release")
+	}
+	leases := client.CoordinationV1().Leases(l.namespace)
+
+	lease, err := leases.Get(context.Background(), leaseName(release), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", ErrNotLocked
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to unlock release %q", release)
+	}
+	holder := leaseHolder(lease)
+	if err := leases.Delete(context.Background(), lease.Name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "unable to unlock release %q", release)
+	}
+	return holder, nil
+}
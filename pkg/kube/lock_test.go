@@ -0,0 +1,184 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func newTestLocker(client kubernetes.Interface) *LeaseLocker {
+	l := NewLeaseLocker(func() (kubernetes.Interface, error) { return client, nil }, "default")
+	l.PollInterval = 10 * time.Millisecond
+	return l
+}
+
+func TestLeaseLocker(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	l := newTestLocker(client)
+
+	unlock, err := l.Lock("thomas-guide", "ci-1", 0, nil)
+	if err != nil {
+		t.Fatalf("failed to lock release: %s", err)
+	}
+	lease, err := client.CoordinationV1().Leases("default").Get(context.Background(), leaseName("thomas-guide"), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected lease to exist: %s", err)
+	}
+	if leaseHolder(lease) != "ci-1" {
+		t.Errorf("expected lease to be held by ci-1, got %q", leaseHolder(lease))
+	}
+
+	// another holder fails once the timeout elapsed
+	_, err = l.Lock("thomas-guide", "ci-2", 30*time.Millisecond, nil)
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.Holder != "ci-1" {
+		t.Fatalf("expected release to be locked by ci-1, got %v", err)
+	}
+
+	// other releases are not affected
+	unlockOther, err := l.Lock("atlas-guide", "ci-2", 0, nil)
+	if err != nil {
+		t.Fatalf("failed to lock other release: %s", err)
+	}
+	if err := unlockOther(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a waiting holder gets the lock once it is released
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_ = unlock()
+	}()
+	unlock, err = l.Lock("thomas-guide", "ci-2", 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("expected to get the lock once released: %s", err)
+	}
+
+	holder, err := l.Unlock("thomas-guide")
+	if err != nil || holder != "ci-2" {
+		t.Errorf("expected to remove the lock of ci-2, got %q (%v)", holder, err)
+	}
+	if _, err := l.Unlock("thomas-guide"); !errors.Is(err, ErrNotLocked) {
+		t.Errorf("expected %v, got %v", ErrNotLocked, err)
+	}
+	if err := unlock(); err != nil {
+		t.Errorf("expected unlocking a removed lock to succeed, got %v", err)
+	}
+}
+
+func TestLeaseLockerExpired(t *testing.T) {
+	holder := "crashed"
+	seconds := int32(60)
+	renewed := metav1.NewMicroTime(time.Now().Add(-time.Hour))
+	client := fake.NewSimpleClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{Name: leaseName("thomas-guide"), Namespace: "default"},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &renewed,
+		},
+	})
+
+	unlock, err := newTestLocker(client).Lock("thomas-guide", "ci-1", 0, nil)
+	if err != nil {
+		t.Fatalf("expected an expired lock to be taken over: %s", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeaseLockerLost(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	l := newTestLocker(client)
+	l.LeaseDuration = 30 * time.Millisecond
+
+	lost := make(chan error, 1)
+	unlock, err := l.Lock("thomas-guide", "ci-1", 0, func(err error) { lost <- err })
+	if err != nil {
+		t.Fatalf("failed to lock release: %s", err)
+	}
+	if _, err := l.Unlock("thomas-guide"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-lost:
+		expected := `lock of release "thomas-guide" was removed or taken over`
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the lost lock to be reported")
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeaseLockerRenewalFailure(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	l := newTestLocker(client)
+	l.LeaseDuration = 30 * time.Millisecond
+
+	// the lease is created, and then cannot be renewed
+	client.PrependReactor("update", "leases", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewServiceUnavailable("unavailable")
+	})
+	lost := make(chan error, 1)
+	unlock, err := l.Lock("thomas-guide", "ci-1", 0, func(err error) { lost <- err })
+	if err != nil {
+		t.Fatalf("failed to lock release: %s", err)
+	}
+	select {
+	case err := <-lost:
+		expected := `lock of release "thomas-guide" expired as it could not be renewed: unavailable`
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %q, got %v", expected, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the expired lock to be reported")
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLeaseLockerForbidden(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("get", "leases", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(coordinationv1.Resource("leases"), "", errors.New("denied"))
+	})
+
+	unlock, err := newTestLocker(client).Lock("thomas-guide", "ci-1", 0, nil)
+	if err != nil {
+		t.Fatalf("expected locking to be skipped without permissions, got %v", err)
+	}
+	if err := unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
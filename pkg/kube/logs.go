@@ -0,0 +1,127 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"context"
+	"io"
+
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ContainerLog is the output of a container of a Pod.
+type ContainerLog struct {
+	Pod       string
+	Container string
+	Log       string
+	// Truncated indicates that the beginning of the output was dropped.
+	Truncated bool
+}
+
+// Logs returns the logs of the containers of the Pods and of the Pods run by
+// the Jobs in resources. Other kinds are ignored. Only the last limit bytes of
+// each log are kept.
+//
+// The logs which could be fetched are returned along with the first error.
+func (c *Client) Logs(resources ResourceList, limit int64) ([]ContainerLog, error) {
+	client, err := c.getKubeClient()
+	if err != nil {
+		return nil, err
+	}
+	return containerLogs(client, resources, limit)
+}
+
+func containerLogs(client kubernetes.Interface, resources ResourceList, limit int64) ([]ContainerLog, error) {
+	var (
+		logs     []ContainerLog
+		firstErr error
+	)
+	for _, info := range resources {
+		pods, err := podsOf(client, info.Mapping.GroupVersionKind.Kind, info.Namespace, info.Name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		for _, pod := range pods {
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				log, truncated, err := containerLog(client, pod.Namespace, pod.Name, container.Name, limit)
+				if err != nil {
+					if firstErr == nil {
+						firstErr = errors.Wrapf(err, "unable to get logs of container %s of pod %s", container.Name, pod.Name)
+					}
+					continue
+				}
+				logs = append(logs, ContainerLog{Pod: pod.Name, Container: container.Name, Log: log, Truncated: truncated})
+			}
+		}
+	}
+	return logs, firstErr
+}
+
+// podsOf returns the Pod of the given name, or the Pods run by the Job of the
+// given name.
+func podsOf(client kubernetes.Interface, kind, namespace, name string) ([]v1.Pod, error) {
+	switch kind {
+	case "Pod":
+		pod, err := client.CoreV1().Pods(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get pod %s", name)
+		}
+		return []v1.Pod{*pod}, nil
+	case "Job":
+		list, err := client.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: "job-name=" + name})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to list pods of job %s", name)
+		}
+		return list.Items, nil
+	}
+	return nil, nil
+}
+
+// containerLog returns the last limit bytes of the log of a container.
+func containerLog(client kubernetes.Interface, namespace, pod, container string, limit int64) (string, bool, error) {
+	stream, err := client.CoreV1().Pods(namespace).GetLogs(pod, &v1.PodLogOptions{Container: container}).Stream(context.Background())
+	if err != nil {
+		return "", false, err
+	}
+	defer stream.Close()
+
+	var (
+		buf       []byte
+		truncated bool
+	)
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := stream.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if over := int64(len(buf)) - limit; over > 0 {
+			buf = append(buf[:0], buf[over:]...)
+			truncated = true
+		}
+		if err == io.EOF {
+			return string(buf), truncated, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestContainerLogs(t *testing.T) {
+	pod := func(name string, labels map[string]string, containers ...string) *v1.Pod {
+		p := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels}}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, v1.Container{Name: c})
+		}
+		return p
+	}
+	client := fake.NewSimpleClientset(
+		pod("hook", nil, "main"),
+		pod("migrate-abcde", map[string]string{"job-name": "migrate"}, "migrate", "sidecar"),
+		pod("other", map[string]string{"job-name": "other"}, "main"),
+	)
+	info := func(kind, name string) *resource.Info {
+		return &resource.Info{
+			Name:      name,
+			Namespace: "default",
+			Mapping:   &meta.RESTMapping{GroupVersionKind: schema.GroupVersionKind{Version: "v1", Kind: kind}},
+		}
+	}
+
+	// The fake client returns "fake logs" for every container.
+	logs, err := containerLogs(client, ResourceList{info("Pod", "hook"), info("ConfigMap", "config"), info("Job", "migrate")}, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []ContainerLog{
+		{Pod: "hook", Container: "main", Log: "fake logs"},
+		{Pod: "migrate-abcde", Container: "migrate", Log: "fake logs"},
+		{Pod: "migrate-abcde", Container: "sidecar", Log: "fake logs"},
+	}
+	if !reflect.DeepEqual(logs, expect) {
+		t.Errorf("expected %v, got %v", expect, logs)
+	}
+
+	logs, err = containerLogs(client, ResourceList{info("Pod", "hook")}, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (ContainerLog{Pod: "hook", Container: "main", Log: "logs", Truncated: true}); !reflect.DeepEqual(logs, []ContainerLog{expect}) {
+		t.Errorf("expected %v, got %v", expect, logs)
+	}
+
+	logs, err = containerLogs(client, ResourceList{info("Pod", "missing"), info("Pod", "hook")}, 1024)
+	if err == nil || !strings.Contains(err.Error(), "unable to get pod missing") {
+		t.Errorf("expected an error for the missing pod, got %v", err)
+	}
+	if len(logs) != 1 {
+		t.Errorf("expected the logs of the other pods, got %v", logs)
+	}
+}
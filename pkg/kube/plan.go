@@ -0,0 +1,181 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/cli-runtime/pkg/resource"
+)
+
+// PlanAction is what an update does to a resource.
+type PlanAction string
+
+const (
+	// PlanCreate indicates the resource does not exist and is created.
+	PlanCreate PlanAction = "create"
+	// PlanPatch indicates the resource is patched.
+	PlanPatch PlanAction = "patch"
+	// PlanApply indicates the resource is patched with server-side apply.
+	PlanApply PlanAction = "apply"
+	// PlanReplace indicates the resource is replaced, as with --force.
+	PlanReplace PlanAction = "replace"
+	// PlanDelete indicates the resource is deleted.
+	PlanDelete PlanAction = "delete"
+	// PlanNone indicates the resource does not change.
+	PlanNone PlanAction = "none"
+)
+
+// PlannedChange is the change an update makes to a single resource.
+type PlannedChange struct {
+	Action     PlanAction `json:"action"`
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Name       string     `json:"name"`
+	// PatchType and Patch are the patch sent to the API server for the
+	// patch and apply actions.
+	PatchType types.PatchType `json:"patchType,omitempty"`
+	Patch     json.RawMessage `json:"patch,omitempty"`
+	// ResourceVersion is the version of the live object the change was
+	// planned against. It is empty for created resources.
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+}
+
+// Plan returns the changes Update, or UpdateServerSide if serverSide is set,
+// would make to move the cluster from original to target. Nothing is
+// modified.
+func (c *Client) Plan(original, target ResourceList, force, serverSide bool) ([]PlannedChange, error) {
+	c.Log("planning changes of %d resources", len(target))
+	return PlanUpdate(original, target, force, serverSide)
+}
+
+// PlanUpdate computes the changes of an update with the same requests to the
+// API server and the same patches as Update and UpdateServerSide, without
+// sending the changes.
+func PlanUpdate(original, target ResourceList, force, serverSide bool) ([]PlannedChange, error) {
+	var changes []PlannedChange
+
+	err := target.Visit(func(info *resource.Info, err error) error {
+		if err != nil {
+			return err
+		}
+		change := newPlannedChange(info)
+
+		helper := resource.NewHelper(info.Client, info.Mapping).WithFieldManager(getManagedFieldsManager())
+		live, err := helper.Get(info.Namespace, info.Name)
+		if err != nil {
+			if !apierrors.IsNotFound(err) {
+				return errors.Wrap(err, "could not get information about the resource")
+			}
+			change.Action = PlanCreate
+			changes = append(changes, change)
+			return nil
+		}
+		change.ResourceVersion = resourceVersion(live)
+
+		switch {
+		case serverSide:
+			data, err := json.Marshal(info.Object)
+			if err != nil {
+				return errors.Wrap(err, "serializing target configuration")
+			}
+			change.Action, change.PatchType, change.Patch = PlanApply, types.ApplyPatchType, data
+		case force:
+			change.Action = PlanReplace
+		default:
+			originalInfo := original.Get(info)
+			if originalInfo == nil {
+				return errors.Errorf("no %s with the name %q found", info.Mapping.GroupVersionKind.Kind, info.Name)
+			}
+			patch, patchType, err := planPatch(info, originalInfo.Object, live)
+			if err != nil {
+				return errors.Wrapf(err, "failed to create patch for %s %q", info.Mapping.GroupVersionKind.Kind, info.Name)
+			}
+			if patch == nil || string(patch) == "{}" {
+				change.Action = PlanNone
+			} else {
+				change.Action, change.PatchType, change.Patch = PlanPatch, patchType, patch
+			}
+		}
+		changes = append(changes, change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, info := range original.Difference(target) {
+		live, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, errors.Wrapf(err, "could not get information about %q", info.Name)
+		}
+		annotations, err := metadataAccessor.Annotations(live)
+		if err == nil && annotations[ResourcePolicyAnno] == KeepPolicy {
+			continue
+		}
+		change := newPlannedChange(info)
+		change.Action = PlanDelete
+		change.ResourceVersion = resourceVersion(live)
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// planPatch returns the patch updateResource sends to move live from original
+// to target.
+func planPatch(target *resource.Info, original, live runtime.Object) ([]byte, types.PatchType, error) {
+	oldData, err := json.Marshal(original)
+	if err != nil {
+		return nil, types.StrategicMergePatchType, errors.Wrap(err, "serializing current configuration")
+	}
+	newData, err := json.Marshal(target.Object)
+	if err != nil {
+		return nil, types.StrategicMergePatchType, errors.Wrap(err, "serializing target configuration")
+	}
+	liveData, err := json.Marshal(live)
+	if err != nil {
+		return nil, types.StrategicMergePatchType, errors.Wrap(err, "serializing live configuration")
+	}
+	return createThreeWayPatch(target, oldData, newData, liveData)
+}
+
+func newPlannedChange(info *resource.Info) PlannedChange {
+	gvk := info.Mapping.GroupVersionKind
+	return PlannedChange{
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Namespace:  info.Namespace,
+		Name:       info.Name,
+	}
+}
+
+func resourceVersion(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetResourceVersion()
+}
@@ -0,0 +1,110 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube
+
+import (
+	"net/http"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest/fake"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
+)
+
+func TestPlan(t *testing.T) {
+	listA := newPodList("starfish", "otter", "squid", "clam")
+	listB := newPodList("starfish", "otter", "dolphin")
+	listB.Items[0].Spec.Containers[0].Ports = []v1.ContainerPort{{Name: "https", ContainerPort: 443}}
+	live := listA.DeepCopy()
+	live.Items[0].ResourceVersion = "7"
+	live.Items[2].ResourceVersion = "3"
+	live.Items[3].Annotations = map[string]string{ResourcePolicyAnno: KeepPolicy}
+
+	c := newTestClient(t)
+	c.Factory.(*cmdtesting.TestFactory).UnstructuredClient = &fake.RESTClient{
+		NegotiatedSerializer: unstructuredSerializer,
+		Client: fake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			p, m := req.URL.Path, req.Method
+			if m != "GET" {
+				t.Fatalf("unexpected request: %s %s", m, p)
+			}
+			for i := range live.Items {
+				if p == "/namespaces/default/pods/"+live.Items[i].Name {
+					return newResponse(200, &live.Items[i])
+				}
+			}
+			return newResponse(404, notFoundBody())
+		}),
+	}
+	first, err := c.Build(objBody(&listA), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.Build(objBody(&listB), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := c.Plan(first, second, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []struct {
+		action          PlanAction
+		name            string
+		resourceVersion string
+		patch           string
+	}{
+		{PlanPatch, "starfish", "7", `{"spec":{"$setElementOrder/containers":[{"name":"app:v4"}],"containers":[{"$setElementOrder/ports":[{"containerPort":443}],"name":"app:v4","ports":[{"containerPort":443,"name":"https"},{"$patch":"delete","containerPort":80}]}]}}`},
+		{PlanNone, "otter", "", ""},
+		{PlanCreate, "dolphin", "", ""},
+		{PlanDelete, "squid", "3", ""},
+	}
+	if len(changes) != len(expect) {
+		t.Fatalf("expected %d changes, got %v", len(expect), changes)
+	}
+	for i, e := range expect {
+		got := changes[i]
+		if got.Action != e.action || got.Name != e.name || got.ResourceVersion != e.resourceVersion || string(got.Patch) != e.patch {
+			t.Errorf("expected %s of %s at %q with patch %s, got %s of %s at %q with patch %s",
+				e.action, e.name, e.resourceVersion, e.patch, got.Action, got.Name, got.ResourceVersion, got.Patch)
+		}
+		if got.Kind != "Pod" || got.APIVersion != "v1" || got.Namespace != "default" {
+			t.Errorf("unexpected resource %s %s %s", got.APIVersion, got.Kind, got.Namespace)
+		}
+	}
+	if changes[0].PatchType != types.StrategicMergePatchType {
+		t.Errorf("expected a strategic merge patch, got %s", changes[0].PatchType)
+	}
+
+	changes, err = c.Plan(first, second, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes[0].Action != PlanReplace || changes[0].Patch != nil {
+		t.Errorf("expected a replace without patch with force, got %v", changes[0])
+	}
+
+	changes, err = c.Plan(first, second, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes[1].Action != PlanApply || changes[1].PatchType != types.ApplyPatchType || len(changes[1].Patch) == 0 {
+		t.Errorf("expected an apply patch with server-side apply, got %v", changes[1])
+	}
+}
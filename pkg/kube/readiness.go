@@ -0,0 +1,227 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kube // import "helm.sh/helm/v3/pkg/kube"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/resource"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// ReadinessAnnotation is the annotation a chart can set on a resource to
+// declare when the resource is ready. The value is an expression evaluated
+// against the live object, for example:
+//
+//	{.status.phase} == "Running" && {.status.endpoint}
+//
+// Each clause is a JSONPath, optionally compared with == or != to a value.
+// A clause without a comparison holds when the JSONPath yields a value other
+// than "" or "false". All clauses joined with && must hold for the resource
+// to be ready.
+const ReadinessAnnotation = "helm.sh/readiness"
+
+// ResourceStatus describes the readiness of a single resource.
+type ResourceStatus struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Ready     bool   `json:"ready"`
+	// Message explains why the resource is not ready yet.
+	Message string `json:"message,omitempty"`
+}
+
+func (s ResourceStatus) String() string {
+	if s.Namespace == "" {
+		return fmt.Sprintf("%s/%s", s.Kind, s.Name)
+	}
+	return fmt.Sprintf("%s %s/%s", s.Kind, s.Namespace, s.Name)
+}
+
+// StatusCheckFunc reports whether the live state of a resource is ready. The
+// returned message explains why the resource is not ready.
+type StatusCheckFunc func(obj *unstructured.Unstructured) (ready bool, message string, err error)
+
+// StatusCheck returns a ReadyCheckerOption that configures a ReadyChecker to
+// use fn to check the readiness of resources of the given kind. It takes
+// precedence over the built-in checks.
+func StatusCheck(gk schema.GroupKind, fn StatusCheckFunc) ReadyCheckerOption {
+	return func(c *ReadyChecker) {
+		if c.statusChecks == nil {
+			c.statusChecks = map[schema.GroupKind]StatusCheckFunc{}
+		}
+		c.statusChecks[gk] = fn
+	}
+}
+
+// GenericStatusCheck checks the readiness of an arbitrary resource using the
+// conventions followed by most controllers:
+//
+//   - status.observedGeneration must have caught up with metadata.generation
+//   - a "Stalled" or "Reconciling" condition must not be "True"
+//   - a "Ready" condition, if present, must be "True"
+//
+// Resources without a status are considered ready.
+func GenericStatusCheck(obj *unstructured.Unstructured) (bool, string, error) {
+	observed, found, err := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if err != nil {
+		return false, "", err
+	}
+	if found && observed < obj.GetGeneration() {
+		return false, fmt.Sprintf("observed generation %d does not match generation %d", observed, obj.GetGeneration()), nil
+	}
+
+	conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	if err != nil {
+		return false, "", err
+	}
+	byType := map[string]map[string]interface{}{}
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]interface{}); ok {
+			if t, ok := cond["type"].(string); ok {
+				byType[t] = cond
+			}
+		}
+	}
+
+	for _, t := range []string{"Stalled", "Reconciling"} {
+		if cond, ok := byType[t]; ok && cond["status"] == "True" {
+			return false, conditionMessage(t, cond), nil
+		}
+	}
+	if cond, ok := byType["Ready"]; ok && cond["status"] != "True" {
+		return false, conditionMessage("Ready", cond), nil
+	}
+	return true, "", nil
+}
+
+func conditionMessage(t string, cond map[string]interface{}) string {
+	msg := fmt.Sprintf("condition %s is %v", t, cond["status"])
+	if reason, ok := cond["reason"].(string); ok && reason != "" {
+		msg += ": " + reason
+	}
+	if message, ok := cond["message"].(string); ok && message != "" {
+		msg += ": " + message
+	}
+	return msg
+}
+
+type readinessClause struct {
+	expr  string
+	path  *jsonpath.JSONPath
+	op    string
+	value string
+}
+
+// ExpressionStatusCheck parses a readiness expression as documented on
+// ReadinessAnnotation and returns a StatusCheckFunc evaluating it.
+func ExpressionStatusCheck(expr string) (StatusCheckFunc, error) {
+	parts := splitOutsideBraces(expr, "&&")
+	clauses := make([]readinessClause, 0, len(parts))
+	for _, part := range parts {
+		clause := readinessClause{expr: strings.TrimSpace(part)}
+		if clause.expr == "" {
+			return nil, errors.Errorf("invalid readiness expression %q: empty clause", expr)
+		}
+		path := clause.expr
+		for _, op := range []string{"==", "!="} {
+			if s := splitOutsideBraces(clause.expr, op); len(s) == 2 {
+				path, clause.op, clause.value = strings.TrimSpace(s[0]), op, unquote(strings.TrimSpace(s[1]))
+				break
+			} else if len(s) > 2 {
+				return nil, errors.Errorf("invalid readiness expression %q: too many %q in %q", expr, op, clause.expr)
+			}
+		}
+		clause.path = jsonpath.New(ReadinessAnnotation).AllowMissingKeys(true)
+		if err := clause.path.Parse(path); err != nil {
+			return nil, errors.Wrapf(err, "invalid readiness expression %q", expr)
+		}
+		clauses = append(clauses, clause)
+	}
+
+	return func(obj *unstructured.Unstructured) (bool, string, error) {
+		for _, clause := range clauses {
+			var buf bytes.Buffer
+			if err := clause.path.Execute(&buf, obj.Object); err != nil {
+				return false, "", errors.Wrapf(err, "unable to evaluate readiness expression %q", clause.expr)
+			}
+			got := buf.String()
+			var ok bool
+			switch clause.op {
+			case "==":
+				ok = got == clause.value
+			case "!=":
+				ok = got != clause.value
+			default:
+				ok = got != "" && got != "false"
+			}
+			if !ok {
+				return false, fmt.Sprintf("readiness expression %q not satisfied (got %q)", clause.expr, got), nil
+			}
+		}
+		return true, "", nil
+	}, nil
+}
+
+// splitOutsideBraces splits s around sep, ignoring occurrences of sep within
+// JSONPath braces.
+func splitOutsideBraces(s, sep string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '{':
+			depth++
+		case s[i] == '}':
+			depth--
+		case depth == 0 && strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// getUnstructured fetches the live state of a resource as unstructured.
+func getUnstructured(info *resource.Info) (*unstructured.Unstructured, error) {
+	obj, err := resource.NewHelper(info.Client, info.Mapping).Get(info.Namespace, info.Name)
+	if err != nil {
+		return nil, err
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
@@ -30,6 +30,7 @@ import (
 	apiextv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes"
@@ -80,16 +81,73 @@ type ReadyChecker struct {
 	log           func(string, ...interface{})
 	checkJobs     bool
 	pausedAsReady bool
+	statusChecks  map[schema.GroupKind]StatusCheckFunc
 }
 
 // IsReady checks if v is ready. It supports checking readiness for pods,
 // deployments, persistent volume claims, services, daemon sets, custom
 // resource definitions, stateful sets, replication controllers, jobs (optional),
-// and replica sets. All other resource kinds are always considered ready.
+// and replica sets. Custom resources are checked with GenericStatusCheck. All
+// other resource kinds are always considered ready.
 //
 // IsReady will fetch the latest state of the object from the server prior to
 // performing readiness checks, and it will return any error encountered.
 func (c *ReadyChecker) IsReady(ctx context.Context, v *resource.Info) (bool, error) {
+	status, err := c.Status(ctx, v)
+	return status.Ready, err
+}
+
+// Status checks if v is ready like IsReady does and describes why it is not.
+//
+// A readiness expression declared with the ReadinessAnnotation takes
+// precedence over checks registered with StatusCheck, which in turn take
+// precedence over the built-in checks.
+func (c *ReadyChecker) Status(ctx context.Context, v *resource.Info) (ResourceStatus, error) {
+	status := ResourceStatus{Namespace: v.Namespace, Name: v.Name}
+	var gk schema.GroupKind
+	if v.Mapping != nil {
+		gk = v.Mapping.GroupVersionKind.GroupKind()
+		status.Kind = gk.Kind
+	}
+
+	check, err := c.statusCheckFor(v, gk)
+	if err != nil {
+		return status, err
+	}
+	if check == nil {
+		status.Ready, err = c.builtinReady(ctx, v)
+		if !status.Ready && err == nil {
+			status.Message = "not ready"
+		}
+		return status, err
+	}
+
+	obj, err := getUnstructured(v)
+	if err != nil {
+		return status, err
+	}
+	status.Ready, status.Message, err = check(obj)
+	return status, err
+}
+
+// statusCheckFor returns the check evaluated against the live state of v, or
+// nil if the built-in checks apply.
+func (c *ReadyChecker) statusCheckFor(v *resource.Info, gk schema.GroupKind) (StatusCheckFunc, error) {
+	if annotations, err := metadataAccessor.Annotations(v.Object); err == nil {
+		if expr, ok := annotations[ReadinessAnnotation]; ok {
+			return ExpressionStatusCheck(expr)
+		}
+	}
+	if check, ok := c.statusChecks[gk]; ok {
+		return check, nil
+	}
+	if _, ok := AsVersioned(v).(runtime.Unstructured); ok {
+		return GenericStatusCheck, nil
+	}
+	return nil, nil
+}
+
+func (c *ReadyChecker) builtinReady(ctx context.Context, v *resource.Info) (bool, error) {
 	switch value := AsVersioned(v).(type) {
 	case *corev1.Pod:
 		pod, err := c.client.CoreV1().Pods(v.Namespace).Get(ctx, v.Name, metav1.GetOptions{})
@@ -16,16 +16,25 @@ limitations under the License.
 package kube // import "helm.sh/helm/v3/pkg/kube"
 
 import (
+	"bytes"
 	"context"
+	"io"
+	"net/http"
 	"testing"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/cli-runtime/pkg/resource"
 	"k8s.io/client-go/kubernetes/fake"
+	restfake "k8s.io/client-go/rest/fake"
 )
 
 const defaultNamespace = metav1.NamespaceDefault
@@ -463,6 +472,173 @@ func Test_ReadyChecker_volumeReady(t *testing.T) {
 	}
 }
 
+func Test_GenericStatusCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		status map[string]interface{}
+		want   bool
+	}{
+		{
+			name: "no status",
+			want: true,
+		},
+		{
+			name:   "observed generation behind",
+			status: map[string]interface{}{"observedGeneration": int64(1)},
+			want:   false,
+		},
+		{
+			name:   "observed generation current",
+			status: map[string]interface{}{"observedGeneration": int64(2)},
+			want:   true,
+		},
+		{
+			name:   "ready condition true",
+			status: map[string]interface{}{"conditions": []interface{}{newCondition("Ready", "True")}},
+			want:   true,
+		},
+		{
+			name:   "ready condition false",
+			status: map[string]interface{}{"conditions": []interface{}{newCondition("Ready", "False")}},
+			want:   false,
+		},
+		{
+			name:   "reconciling",
+			status: map[string]interface{}{"conditions": []interface{}{newCondition("Ready", "True"), newCondition("Reconciling", "True")}},
+			want:   false,
+		},
+		{
+			name:   "stalled",
+			status: map[string]interface{}{"conditions": []interface{}{newCondition("Stalled", "True")}},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			obj := newWidget("foo", tt.status)
+			got, msg, err := GenericStatusCheck(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("GenericStatusCheck() = %v (%s), want %v", got, msg, tt.want)
+			}
+			if !got && msg == "" {
+				t.Error("expected a message for a resource that is not ready")
+			}
+		})
+	}
+}
+
+func Test_ExpressionStatusCheck(t *testing.T) {
+	obj := newWidget("foo", map[string]interface{}{
+		"phase":    "Running",
+		"endpoint": "10.0.0.1",
+		"healthy":  false,
+	})
+	tests := []struct {
+		expr    string
+		want    bool
+		wantErr bool
+	}{
+		{expr: `{.status.phase} == "Running"`, want: true},
+		{expr: `{.status.phase} == 'Pending'`, want: false},
+		{expr: `{.status.phase} != Pending && {.status.endpoint}`, want: true},
+		{expr: `{.status.missing}`, want: false},
+		{expr: `{.status.healthy}`, want: false},
+		{expr: `{.status.phase} == "Running" && {.status.healthy} == true`, want: false},
+		{expr: `{.status.phase} == Running == Running`, wantErr: true},
+		{expr: `{.status.phase} &&`, wantErr: true},
+		{expr: `{.status.phase`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			check, err := ExpressionStatusCheck(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpressionStatusCheck() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, _, err := check(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_ReadyChecker_Status(t *testing.T) {
+	notReady := newWidget("foo", map[string]interface{}{
+		"phase":      "Running",
+		"conditions": []interface{}{newCondition("Ready", "False")},
+	})
+	annotated := notReady.DeepCopy()
+	annotated.SetAnnotations(map[string]string{ReadinessAnnotation: `{.status.phase} == "Running"`})
+
+	tests := []struct {
+		name string
+		obj  *unstructured.Unstructured
+		opts []ReadyCheckerOption
+		want bool
+	}{
+		{
+			name: "custom resource uses generic check",
+			obj:  notReady,
+			want: false,
+		},
+		{
+			name: "readiness annotation takes precedence",
+			obj:  annotated,
+			want: true,
+		},
+		{
+			name: "registered status check takes precedence",
+			obj:  notReady,
+			opts: []ReadyCheckerOption{StatusCheck(widgetGVK.GroupKind(), func(obj *unstructured.Unstructured) (bool, string, error) {
+				return obj.GetName() == "foo", "", nil
+			})},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewReadyChecker(fake.NewSimpleClientset(), nil, tt.opts...)
+			got, err := c.Status(context.Background(), newWidgetInfo(t, tt.obj))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Ready != tt.want {
+				t.Errorf("Status() = %+v, want ready %v", got, tt.want)
+			}
+			if got.Kind != "Widget" || got.Name != "foo" || got.Namespace != defaultNamespace {
+				t.Errorf("unexpected resource in status %+v", got)
+			}
+		})
+	}
+}
+
+func Test_waiter_reportsProgress(t *testing.T) {
+	c := NewReadyChecker(fake.NewSimpleClientset(), nil)
+	var reported [][]ResourceStatus
+	w := waiter{
+		c:        c,
+		log:      nopLogger,
+		timeout:  time.Minute,
+		progress: func(s []ResourceStatus) { reported = append(reported, s) },
+	}
+	ready := newWidget("foo", map[string]interface{}{"conditions": []interface{}{newCondition("Ready", "True")}})
+	if err := w.waitForResources(ResourceList{newWidgetInfo(t, ready)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(reported) != 1 || len(reported[0]) != 1 || !reported[0][0].Ready {
+		t.Errorf("unexpected progress reported: %+v", reported)
+	}
+}
+
 func newStatefulSetWithUpdateRevision(name string, replicas, partition, readyReplicas, updatedReplicas int, updateRevision string, generationInSync bool) *appsv1.StatefulSet {
 	ss := newStatefulSet(name, replicas, partition, readyReplicas, updatedReplicas, generationInSync)
 	ss.Status.UpdateRevision = updateRevision
@@ -703,3 +879,52 @@ func intToInt32(i int) *int32 {
 	i32 := int32(i)
 	return &i32
 }
+
+var widgetGVK = schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+func newWidget(name string, status map[string]interface{}) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(widgetGVK)
+	obj.SetName(name)
+	obj.SetNamespace(defaultNamespace)
+	obj.SetGeneration(2)
+	if status != nil {
+		obj.Object["status"] = status
+	}
+	return obj
+}
+
+func newCondition(conditionType, status string) map[string]interface{} {
+	return map[string]interface{}{"type": conditionType, "status": status}
+}
+
+// newWidgetInfo returns an info for obj backed by a client that serves obj.
+func newWidgetInfo(t *testing.T, obj *unstructured.Unstructured) *resource.Info {
+	t.Helper()
+	body, err := obj.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &restfake.RESTClient{
+		NegotiatedSerializer: resource.UnstructuredPlusDefaultContentConfig().NegotiatedSerializer,
+		Client: restfake.CreateHTTPClient(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/namespaces/default/widgets/"+obj.GetName() {
+				t.Fatalf("unexpected request %s %s", req.Method, req.URL.Path)
+			}
+			header := http.Header{}
+			header.Set("Content-Type", runtime.ContentTypeJSON)
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: io.NopCloser(bytes.NewReader(body))}, nil
+		}),
+	}
+	return &resource.Info{
+		Client:    client,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Object:    obj,
+		Mapping: &meta.RESTMapping{
+			Resource:         widgetGVK.GroupVersion().WithResource("widgets"),
+			GroupVersionKind: widgetGVK,
+			Scope:            meta.RESTScopeNamespace,
+		},
+	}
+}
@@ -39,9 +39,10 @@ import (
 )
 
 type waiter struct {
-	c       ReadyChecker
-	timeout time.Duration
-	log     func(string, ...interface{})
+	c        ReadyChecker
+	timeout  time.Duration
+	log      func(string, ...interface{})
+	progress func([]ResourceStatus)
 }
 
 // waitForResources polls to get the current status of all pods, PVCs, Services and
@@ -56,11 +57,15 @@ func (w *waiter) waitForResources(created ResourceList) error {
 	for i := range numberOfErrors {
 		numberOfErrors[i] = 0
 	}
+	previous := make([]ResourceStatus, len(created))
 
 	return wait.PollUntilContextCancel(ctx, 2*time.Second, true, func(ctx context.Context) (bool, error) {
 		waitRetries := 30
+		statuses := make([]ResourceStatus, len(created))
+		ready := 0
 		for i, v := range created {
-			ready, err := w.c.IsReady(ctx, v)
+			status, err := w.c.Status(ctx, v)
+			statuses[i] = status
 
 			if waitRetries > 0 && w.isRetryableError(err, v) {
 				numberOfErrors[i]++
@@ -69,17 +74,46 @@ func (w *waiter) waitForResources(created ResourceList) error {
 					return false, err
 				}
 				w.log("Retrying as current number of retries %d less than max number of retries %d", numberOfErrors[i]-1, waitRetries)
-				return false, nil
+				statuses[i].Message = err.Error()
+				continue
 			}
 			numberOfErrors[i] = 0
-			if !ready {
+			if err != nil {
 				return false, err
 			}
+			if status.Ready {
+				ready++
+			}
 		}
-		return true, nil
+		w.reportProgress(previous, statuses, ready)
+		previous = statuses
+		return ready == len(created), nil
 	})
 }
 
+// reportProgress logs the resources whose status changed since the previous
+// poll and passes the current statuses to the progress function, if any.
+func (w *waiter) reportProgress(previous, current []ResourceStatus, ready int) {
+	changed := false
+	for i, status := range current {
+		if status == previous[i] {
+			continue
+		}
+		changed = true
+		if status.Ready {
+			w.log("%s is ready", status)
+		} else {
+			w.log("%s is not ready: %s", status, status.Message)
+		}
+	}
+	if changed {
+		w.log("%d of %d resources ready", ready, len(current))
+	}
+	if w.progress != nil {
+		w.progress(current)
+	}
+}
+
 func (w *waiter) isRetryableError(err error, resource *resource.Info) bool {
 	if err == nil {
 		return false
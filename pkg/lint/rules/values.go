@@ -19,10 +19,14 @@ package rules
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 
+	"helm.sh/helm/v3/pkg/chart/loader"
 	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
 	"helm.sh/helm/v3/pkg/lint/support"
 )
 
@@ -51,6 +55,70 @@ func ValuesWithOverrides(linter *support.Linter, values map[string]interface{})
 	linter.RunLinterRule(support.ErrorSev, file, validateValuesFile(vf, values))
 }
 
+// ValuesUsage renders the chart and warns of the values no template reads, and
+// of the values the templates read that are defined neither in the values
+// files of the charts nor in the given values.
+//
+// Only the values read by the templates that are executed with the given
+// values are known, so a value read under a condition that is false is
+// reported as unused.
+func ValuesUsage(linter *support.Linter, values map[string]interface{}, namespace string, kubeVersion *chartutil.KubeVersion) {
+	chart, err := loader.Load(linter.ChartDir)
+	if err != nil {
+		// Reported by the templates rule.
+		return
+	}
+
+	options := chartutil.ReleaseOptions{
+		Name:      "test-release",
+		Namespace: namespace,
+	}
+	caps := chartutil.DefaultCapabilities.Copy()
+	if kubeVersion != nil {
+		caps.KubeVersion = *kubeVersion
+	}
+	if err := chartutil.ProcessDependenciesWithMerge(chart, values); err != nil {
+		return
+	}
+	cvals, err := chartutil.CoalesceValues(chart, values)
+	if err != nil {
+		return
+	}
+	valuesToRender, err := chartutil.ToRenderValuesWithSchemaValidation(chart, cvals, options, caps, true)
+	if err != nil {
+		return
+	}
+
+	var e engine.Engine
+	e.LintMode = true
+	e.Trace = engine.NewTrace()
+	if _, err := e.Render(chart, valuesToRender); err != nil {
+		// Reported by the templates rule.
+		return
+	}
+
+	for _, p := range e.Trace.UnusedValues {
+		// Exports are read by the parent charts through import-values.
+		if strings.HasPrefix(p, "exports.") || strings.Contains(p, ".exports.") {
+			continue
+		}
+		linter.RunLinterRule(support.WarningSev, "values.yaml", errors.Errorf("value %q is not used by any template", p))
+	}
+
+	names := make([]string, 0, len(e.Trace.Files))
+	for name := range e.Trace.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Make the name relative to the chart directory.
+		fpath := name[strings.IndexByte(name, '/')+1:]
+		for _, p := range e.Trace.Files[name].Undeclared {
+			linter.RunLinterRule(support.WarningSev, fpath, errors.Errorf("template reads undefined value %q", ".Values."+p))
+		}
+	}
+}
+
 func validateValuesFileExistence(valuesPath string) error {
 	_, err := os.Stat(valuesPath)
 	if err != nil {
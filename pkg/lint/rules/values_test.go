@@ -24,6 +24,9 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"helm.sh/helm/v3/internal/test/ensure"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/lint/support"
 )
 
 var nonExistingValuesFilePath = filepath.Join("/fake/dir", "values.yaml")
@@ -159,6 +162,46 @@ func TestValidateValuesFile(t *testing.T) {
 	}
 }
 
+func TestValuesUsage(t *testing.T) {
+	sub := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "sub", Version: "0.1.0"},
+		Raw:      []*chart.File{{Name: "values.yaml", Data: []byte("image: nginx\ntag: \"1.0\"\n")}},
+		Templates: []*chart.File{
+			{Name: "templates/pod.yaml", Data: []byte("image: {{ .Values.image }}\npolicy: {{ .Values.pullPolicy }}\n")},
+		},
+	}
+	ch := &chart.Chart{
+		Metadata: &chart.Metadata{APIVersion: "v2", Name: "usage", Version: "0.1.0"},
+		Raw: []*chart.File{
+			{Name: "values.yaml", Data: []byte("name: web\ndebug: false\nextra: 1\nexports:\n  data:\n    a: 1\n")},
+		},
+		Templates: []*chart.File{
+			{Name: "templates/configmap.yaml", Data: []byte("name: {{ .Values.name }}\ndebug: {{ .Values.debug }}\nport: {{ .Values.prot | default 80 }}\n")},
+		},
+	}
+	ch.AddDependency(sub)
+	dir := t.TempDir()
+	if err := chartutil.SaveDir(ch, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	linter := &support.Linter{ChartDir: filepath.Join(dir, ch.Name())}
+	ValuesUsage(linter, map[string]interface{}{"debug": true}, namespace, nil)
+
+	expected := []string{
+		`values.yaml: value "extra" is not used by any template`,
+		`values.yaml: value "sub.tag" is not used by any template`,
+		`charts/sub/templates/pod.yaml: template reads undefined value ".Values.pullPolicy"`,
+		`templates/configmap.yaml: template reads undefined value ".Values.prot"`,
+	}
+	var got []string
+	for _, msg := range linter.Messages {
+		assert.Equal(t, support.WarningSev, msg.Severity)
+		got = append(got, msg.Path+": "+msg.Err.Error())
+	}
+	assert.Equal(t, expected, got)
+}
+
 func createTestingSchema(t *testing.T, dir string) string {
 	t.Helper()
 	schemafile := filepath.Join(dir, "values.schema.json")
@@ -42,6 +42,37 @@ type Downloaders struct {
 	Command string `json:"command"`
 }
 
+// StorageDriver represents the plugins capability if it can store
+// release records, see driver.Plugin for the protocol
+type StorageDriver struct {
+	// Name is the name of the storage driver as selected with HELM_DRIVER.
+	Name string `json:"name"`
+	// Command is the executable path with which the plugin performs
+	// the storage operations
+	Command string `json:"command"`
+}
+
+// KMSProvider represents the plugins capability if it can wrap the keys
+// encrypting release records, see driver.KMSPlugin for the protocol
+type KMSProvider struct {
+	// Name is the name of the provider as selected with
+	// HELM_DRIVER_ENCRYPTION_KMS.
+	Name string `json:"name"`
+	// Command is the executable path with which the plugin wraps and
+	// unwraps keys
+	Command string `json:"command"`
+}
+
+// FunctionProvider represents the plugins capability if it can provide
+// template functions, see engine.FunctionProvider for the protocol
+type FunctionProvider struct {
+	// Functions are the names of the template functions provided.
+	Functions []string `json:"functions"`
+	// Command is the executable path with which the plugin calls the
+	// functions
+	Command string `json:"command"`
+}
+
 // PlatformCommand represents a command for a particular operating system and architecture
 type PlatformCommand struct {
 	OperatingSystem string `json:"os"`
@@ -97,6 +128,18 @@ type Metadata struct {
 	// for special protocols.
 	Downloaders []Downloaders `json:"downloaders"`
 
+	// StorageDrivers field is used if the plugin supply storage drivers
+	// for release records.
+	StorageDrivers []StorageDriver `json:"storageDrivers"`
+
+	// KMSProviders field is used if the plugin supply key management
+	// services for encrypted release records.
+	KMSProviders []KMSProvider `json:"kmsProviders"`
+
+	// FunctionProviders field is used if the plugin supply template
+	// functions for charts.
+	FunctionProviders []FunctionProvider `json:"functionProviders"`
+
 	// UseTunnelDeprecated indicates that this command needs a tunnel.
 	// Setting this will cause a number of side effects, such as the
 	// automatic setting of HELM_HOST.
@@ -34,6 +34,9 @@ const (
 	HookPreRollback  HookEvent = "pre-rollback"
 	HookPostRollback HookEvent = "post-rollback"
 	HookTest         HookEvent = "test"
+	HookPreTest      HookEvent = "pre-test"
+	HookPostTest     HookEvent = "post-test"
+	HookOnFailure    HookEvent = "on-failure"
 )
 
 func (x HookEvent) String() string { return string(x) }
@@ -86,6 +89,23 @@ type HookExecution struct {
 	CompletedAt time.Time `json:"completed_at,omitempty"`
 	// Phase indicates whether the hook completed successfully
 	Phase HookPhase `json:"phase"`
+	// Message is the error of a failed hook.
+	Message string `json:"message,omitempty"`
+	// Logs are the end of the output of the containers run by the hook, if it
+	// is a Pod or a Job.
+	Logs []HookLog `json:"logs,omitempty"`
+}
+
+// HookLog is the output of a container run by a hook.
+type HookLog struct {
+	// Pod is the name of the Pod running the container.
+	Pod string `json:"pod"`
+	// Container is the name of the container.
+	Container string `json:"container"`
+	// Log is the output of the container.
+	Log string `json:"log"`
+	// Truncated indicates that the beginning of the output was dropped.
+	Truncated bool `json:"truncated,omitempty"`
 }
 
 // A HookPhase indicates the state of a hook execution
@@ -61,6 +61,9 @@ var events = map[string]release.HookEvent{
 	release.HookPreRollback.String():  release.HookPreRollback,
 	release.HookPostRollback.String(): release.HookPostRollback,
 	release.HookTest.String():         release.HookTest,
+	release.HookPreTest.String():      release.HookPreTest,
+	release.HookPostTest.String():     release.HookPostTest,
+	release.HookOnFailure.String():    release.HookOnFailure,
 	// Support test-success for backward compatibility with Helm 2 tests
 	"test-success": release.HookTest,
 }
@@ -129,6 +129,19 @@ metadata:
   name: example-test
   annotations:
     "helm.sh/hook": test
+`,
+		},
+		{
+			name:  []string{"ninth"},
+			path:  "nine",
+			kind:  []string{"Job"},
+			hooks: map[string][]release.HookEvent{"ninth": {release.HookPreTest, release.HookPostTest, release.HookOnFailure}},
+			manifest: `kind: Job
+apiVersion: batch/v1
+metadata:
+  name: ninth
+  annotations:
+    "helm.sh/hook": pre-test,post-test,on-failure
 `,
 		},
 	}
@@ -148,8 +161,8 @@ metadata:
 		t.Errorf("Expected 2 generic manifests, got %d", len(generic))
 	}
 
-	if len(hs) != 4 {
-		t.Errorf("Expected 4 hooks, got %d", len(hs))
+	if len(hs) != 5 {
+		t.Errorf("Expected 5 hooks, got %d", len(hs))
 	}
 
 	for _, out := range hs {
@@ -43,6 +43,13 @@ const ConfigMapsDriverName = "ConfigMap"
 type ConfigMaps struct {
 	impl corev1.ConfigMapInterface
 	Log  func(string, ...interface{})
+
+	// ChunkSize is the maximum size of the encoded release stored in a
+	// single ConfigMap. Larger releases are split across several ConfigMaps.
+	// DefaultChunkSize is used if it is not set.
+	ChunkSize int
+	// Encryption, if set, encrypts the releases stored from now on.
+	Encryption KeyProvider
 }
 
 // NewConfigMaps initializes a new ConfigMaps wrapping an implementation of
@@ -59,6 +66,27 @@ func (cfgmaps *ConfigMaps) Name() string {
 	return ConfigMapsDriverName
 }
 
+// Keys returns the keys of all releases, including those that cannot be
+// decoded.
+func (cfgmaps *ConfigMaps) Keys() ([]string, error) {
+	lsel := kblabels.Set{"owner": "helm"}.AsSelector()
+	opts := metav1.ListOptions{LabelSelector: lsel.String()}
+
+	list, err := cfgmaps.impl.List(context.Background(), opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "keys: failed to list")
+	}
+	keys := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		keys = append(keys, item.Name)
+	}
+	return keys, nil
+}
+
+func (cfgmaps *ConfigMaps) encryption() KeyProvider {
+	return cfgmaps.Encryption
+}
+
 // Get fetches the release named by key. The corresponding release is returned
 // or error if not found.
 func (cfgmaps *ConfigMaps) Get(key string) (*rspb.Release, error) {
@@ -73,7 +101,7 @@ func (cfgmaps *ConfigMaps) Get(key string) (*rspb.Release, error) {
 		return nil, err
 	}
 	// found the configmap, decode the base64 data string
-	r, err := decodeRelease(obj.Data["release"])
+	r, err := cfgmaps.decode(obj)
 	if err != nil {
 		cfgmaps.Log("get: failed to decode data %q: %s", key, err)
 		return nil, err
@@ -101,7 +129,7 @@ func (cfgmaps *ConfigMaps) List(filter func(*rspb.Release) bool) ([]*rspb.Releas
 	// iterate over the configmaps object list
 	// and decode each release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+		rls, err := cfgmaps.decode(&item)
 		if err != nil {
 			cfgmaps.Log("list: failed to decode release: %v: %s", item, err)
 			continue
@@ -141,7 +169,7 @@ func (cfgmaps *ConfigMaps) Query(labels map[string]string) ([]*rspb.Release, err
 
 	var results []*rspb.Release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(item.Data["release"])
+		rls, err := cfgmaps.decode(&item)
 		if err != nil {
 			cfgmaps.Log("query: failed to decode release: %s", err)
 			continue
@@ -168,8 +196,29 @@ func (cfgmaps *ConfigMaps) Create(key string, rls *rspb.Release) error {
 		cfgmaps.Log("create: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	if obj.Data[recordDataKey], err = encryptRecord(cfgmaps.Encryption, obj.Data[recordDataKey]); err != nil {
+		cfgmaps.Log("create: failed to encrypt release %q: %s", rls.Name, err)
+		return err
+	}
+	if cfgmaps.oversized(obj) {
+		if _, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+			return ErrReleaseExists
+		}
+		if err := cfgmaps.split(obj, nil); err != nil {
+			cfgmaps.Log("create: failed to split release %q: %s", rls.Name, err)
+			return err
+		}
+	}
 	// push the configmap object out into the kubiverse
 	if _, err := cfgmaps.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		// drop the chunks written, unless they are those of the release
+		// created in the meantime
+		var existing map[string]string
+		if other, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+			existing = other.Data
+		}
+		_ = dropChunks(configMapChunks{cfgmaps.impl}, key, obj.Data, existing)
+
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -196,12 +245,33 @@ func (cfgmaps *ConfigMaps) Update(key string, rls *rspb.Release) error {
 		cfgmaps.Log("update: failed to encode release %q: %s", rls.Name, err)
 		return err
 	}
+	if obj.Data[recordDataKey], err = encryptRecord(cfgmaps.Encryption, obj.Data[recordDataKey]); err != nil {
+		cfgmaps.Log("update: failed to encrypt release %q: %s", rls.Name, err)
+		return err
+	}
+	// remember the chunks of the previous record, which are deleted once the
+	// record is updated
+	var old map[string]string
+	if obj, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+		old = obj.Data
+	}
+	if cfgmaps.oversized(obj) {
+		if err := cfgmaps.split(obj, old); err != nil {
+			cfgmaps.Log("update: failed to split release %q: %s", rls.Name, err)
+			return err
+		}
+	}
 	// push the configmap object out into the kubiverse
 	_, err = cfgmaps.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
 	if err != nil {
+		_ = dropChunks(configMapChunks{cfgmaps.impl}, key, obj.Data, old)
 		cfgmaps.Log("update: failed to update: %s", err)
 		return err
 	}
+	if err := dropChunks(configMapChunks{cfgmaps.impl}, key, old, obj.Data); err != nil {
+		cfgmaps.Log("update: failed to clean up chunks: %s", err)
+		return err
+	}
 	return nil
 }
 
@@ -211,13 +281,91 @@ func (cfgmaps *ConfigMaps) Delete(key string) (rls *rspb.Release, err error) {
 	if rls, err = cfgmaps.Get(key); err != nil {
 		return nil, err
 	}
+	obj, err := cfgmaps.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
 	// delete the release
 	if err = cfgmaps.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
 		return rls, err
 	}
+	// delete its chunks, if any
+	if err = deleteChunks(configMapChunks{cfgmaps.impl}, key, obj.Data); err != nil {
+		return rls, err
+	}
 	return rls, nil
 }
 
+// decode returns the release held by obj, reassembling it from its chunks if
+// it was split and decrypting it if it was encrypted.
+func (cfgmaps *ConfigMaps) decode(obj *v1.ConfigMap) (*rspb.Release, error) {
+	s, err := readRecord(configMapChunks{cfgmaps.impl}, obj.Name, obj.Data)
+	if err != nil {
+		return nil, err
+	}
+	if s, err = decryptRecord(cfgmaps.Encryption, s); err != nil {
+		return nil, err
+	}
+	return decodeRelease(s)
+}
+
+// oversized returns true if the release held by obj must be split.
+func (cfgmaps *ConfigMaps) oversized(obj *v1.ConfigMap) bool {
+	size := cfgmaps.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return len(obj.Data[recordDataKey]) > size
+}
+
+// split moves the release held by obj into chunk ConfigMaps and replaces it
+// with the number of chunks and the digest of the release. The chunks of the
+// current record, held with the data current, are kept if it fails.
+func (cfgmaps *ConfigMaps) split(obj *v1.ConfigMap, current map[string]string) error {
+	index, err := writeChunks(configMapChunks{cfgmaps.impl}, obj.Name, obj.Data[recordDataKey], cfgmaps.ChunkSize, obj.Labels, current)
+	if err != nil {
+		return err
+	}
+	obj.Data = index
+	return nil
+}
+
+// configMapChunks stores the chunks of split releases in ConfigMaps.
+type configMapChunks struct {
+	impl corev1.ConfigMapInterface
+}
+
+func (c configMapChunks) getChunk(name string) (string, error) {
+	obj, err := c.impl.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return obj.Data[recordDataKey], nil
+}
+
+func (c configMapChunks) putChunk(name, data string, lbs labels) error {
+	obj := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: lbs.toMap(),
+		},
+		Data: map[string]string{recordDataKey: data},
+	}
+	_, err := c.impl.Create(context.Background(), obj, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func (c configMapChunks) deleteChunk(name string) error {
+	err := c.impl.Delete(context.Background(), name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
 // newConfigMapsObject constructs a kubernetes ConfigMap object
 // to store a release. Each configmap data entry is the base64
 // encoded gzipped string of a release.
@@ -17,6 +17,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
@@ -249,3 +250,139 @@ func TestConfigMapDelete(t *testing.T) {
 		t.Errorf("Expected {%v}, got {%v}", ErrReleaseNotFound, err)
 	}
 }
+
+func TestConfigMapChunks(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+
+	// chunks only fit the release without a manifest
+	small, err := encodeRelease(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel.Manifest = randomManifest(2048)
+
+	cfgmaps := newTestFixtureCfgMaps(t)
+	cfgmaps.ChunkSize = len(small)
+	mock := cfgmaps.impl.(*MockConfigMapsInterface)
+
+	if err := cfgmaps.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release with key %q: %s", key, err)
+	}
+	index := mock.objects[key]
+	if _, ok := index.Data["release"]; ok {
+		t.Fatal("Expected the release to be split across chunks")
+	}
+	chunks, err := chunkCount(index.Data)
+	if err != nil || chunks < 2 {
+		t.Fatalf("Expected several chunks, got %d: %v", chunks, err)
+	}
+	if len(mock.objects) != chunks+1 {
+		t.Errorf("Expected %d configmaps, got %d", chunks+1, len(mock.objects))
+	}
+	if err := cfgmaps.Create(key, rel); err != ErrReleaseExists {
+		t.Errorf("Expected %v, got %v", ErrReleaseExists, err)
+	}
+
+	// get, list and query reassemble the release
+	got, err := cfgmaps.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release with key %q: %s", key, err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+	all, err := cfgmaps.List(func(_ *rspb.Release) bool { return true })
+	if err != nil || len(all) != 1 || all[0].Manifest != rel.Manifest {
+		t.Errorf("Expected the release to be listed once, got %d releases: %v", len(all), err)
+	}
+	found, err := cfgmaps.Query(map[string]string{"name": name, "owner": "helm"})
+	if err != nil || len(found) != 1 || found[0].Manifest != rel.Manifest {
+		t.Errorf("Expected the release to be found once, got %d releases: %v", len(found), err)
+	}
+
+	// a corrupted chunk is detected
+	chunk := mock.objects[chunkKey(key, index.Data["digest"], 0)]
+	data := chunk.Data["release"]
+	chunk.Data["release"] = strings.ToUpper(data)
+	if _, err := cfgmaps.Get(key); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Expected digest mismatch, got %v", err)
+	}
+	chunk.Data["release"] = data
+
+	// a failed update leaves the current release and its chunks as they are
+	grown := *rel
+	grown.Manifest = randomManifest(4096)
+	cfgmaps.impl = failingConfigMapUpdates{mock}
+	if err := cfgmaps.Update(key, &grown); err == nil {
+		t.Fatal("Expected the update to fail")
+	}
+	cfgmaps.impl = mock
+	if got, err := cfgmaps.Get(key); err != nil || got.Manifest != rel.Manifest {
+		t.Errorf("Expected the release to be unchanged: %v", err)
+	}
+	if len(mock.objects) != chunks+1 {
+		t.Errorf("Expected the chunks written to be deleted, got %d objects", len(mock.objects))
+	}
+
+	// a smaller release drops the chunks
+	rel.Manifest = ""
+	if err := cfgmaps.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(mock.objects) != 1 {
+		t.Errorf("Expected stale chunks to be deleted, got %d configmaps", len(mock.objects))
+	}
+
+	// deleting a split release deletes its chunks
+	rel.Manifest = randomManifest(2048)
+	if err := cfgmaps.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if _, err := cfgmaps.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release with key %q: %s", key, err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected all configmaps to be deleted, got %d", len(mock.objects))
+	}
+}
+
+func TestConfigMapEncryption(t *testing.T) {
+	plain := releaseStub("smug-pigeon", 1, "default", rspb.StatusSuperseded)
+	cfgmaps := newTestFixtureCfgMaps(t, plain)
+	keys, err := NewLocalKeys(testKeyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfgmaps.Encryption = keys
+	cfgmaps.ChunkSize = 256
+	mock := cfgmaps.impl.(*MockConfigMapsInterface)
+
+	// records stored before encryption was enabled are still read
+	if _, err := cfgmaps.Get(testKey(plain.Name, plain.Version)); err != nil {
+		t.Fatalf("Failed to get plain release: %s", err)
+	}
+
+	rel := releaseStub("smug-pigeon", 2, "default", rspb.StatusDeployed)
+	rel.Manifest = randomManifest(2048)
+	key := testKey(rel.Name, rel.Version)
+	if err := cfgmaps.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release with key %q: %s", key, err)
+	}
+	if data := mock.objects[chunkKey(key, mock.objects[key].Data["digest"], 0)].Data["release"]; !strings.HasPrefix(data, encryptedRecordPrefix) {
+		t.Errorf("Expected the split release to be encrypted, got %q", data)
+	}
+
+	got, err := cfgmaps.Query(map[string]string{"name": rel.Name, "owner": "helm"})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected two releases, got %d: %v", len(got), err)
+	}
+
+	cfgmaps.Encryption = nil
+	if _, err := cfgmaps.Get(key); err == nil {
+		t.Error("Expected an encrypted release to fail without key")
+	}
+}
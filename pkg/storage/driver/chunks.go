@@ -0,0 +1,187 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultChunkSize is the default maximum size of the encoded release stored
+// in a single Secret or ConfigMap. Kubernetes rejects objects larger than
+// 1 MiB, so larger releases are split across several objects.
+const DefaultChunkSize = 768 * 1024
+
+// Records larger than the chunk size are stored as an index object, named
+// by the release key and carrying the usual labels, and a number of chunk
+// objects holding consecutive parts of the encoded release. The index
+// object holds the number of chunks and a digest of the encoded release in
+// place of the release itself, so older clients fail to decode it rather
+// than reading a partial release.
+//
+// The chunks are named by the digest of the record, so the chunks of a new
+// version of the record never overwrite those of the current one: they are
+// written first, then the index is switched to them, and then the chunks of
+// the previous version are deleted.
+const (
+	recordDataKey  = "release"
+	chunksDataKey  = "chunks"
+	digestDataKey  = "digest"
+	chunkLabel     = "chunk"
+	chunkOwner     = "helm-chunk"
+	digestPrefix   = "sha256:"
+	chunkKeyFormat = "%s.%s.chunk%d"
+	// chunkIDLength is the number of hexadecimal digits of the digest used
+	// in the names of the chunks.
+	chunkIDLength = 16
+)
+
+// chunkStore stores the objects holding chunks of a release record.
+type chunkStore interface {
+	// getChunk returns the data of the named chunk object.
+	getChunk(name string) (string, error)
+	// putChunk creates the named chunk object. Chunks are named by their
+	// content, so an existing chunk is left as it is.
+	putChunk(name, data string, lbs labels) error
+	// deleteChunk deletes the named chunk object.
+	deleteChunk(name string) error
+}
+
+// chunkKey returns the name of the chunk i of the record of key with the
+// given digest.
+func chunkKey(key, digest string, i int) string {
+	id := strings.TrimPrefix(digest, digestPrefix)
+	if len(id) > chunkIDLength {
+		id = id[:chunkIDLength]
+	}
+	return fmt.Sprintf(chunkKeyFormat, key, id, i)
+}
+
+func recordDigest(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
+
+// chunkCount returns the number of chunks of the record held by an index
+// object, or zero if the object holds the release itself.
+func chunkCount(data map[string]string) (int, error) {
+	v, ok := data[chunksDataKey]
+	if !ok {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, errors.Errorf("invalid number of chunks %q", v)
+	}
+	return n, nil
+}
+
+// writeChunks stores the encoded release s in the chunk objects of key if it
+// is larger than size and returns the data of the index object. Otherwise,
+// the returned data holds the release itself. If it fails, the chunks
+// written are deleted, except those of the record held with the data keep.
+func writeChunks(store chunkStore, key, s string, size int, lbs labels, keep map[string]string) (map[string]string, error) {
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if len(s) <= size {
+		return map[string]string{recordDataKey: s}, nil
+	}
+
+	digest := recordDigest(s)
+	n := 0
+	for start := 0; start < len(s); start += size {
+		end := start + size
+		if end > len(s) {
+			end = len(s)
+		}
+		var chunkLbs labels
+		chunkLbs.init()
+		chunkLbs.set("name", lbs.get("name"))
+		chunkLbs.set("version", lbs.get("version"))
+		chunkLbs.set("owner", chunkOwner)
+		chunkLbs.set(chunkLabel, strconv.Itoa(n))
+		if err := store.putChunk(chunkKey(key, digest, n), s[start:end], chunkLbs); err != nil {
+			if n > 0 {
+				written := map[string]string{chunksDataKey: strconv.Itoa(n), digestDataKey: digest}
+				_ = dropChunks(store, key, written, keep)
+			}
+			return nil, errors.Wrapf(err, "failed to store chunk %d of %q", n, key)
+		}
+		n++
+	}
+	return map[string]string{
+		chunksDataKey: strconv.Itoa(n),
+		digestDataKey: digest,
+	}, nil
+}
+
+// readRecord returns the encoded release held by the index object of key
+// with the given data, reassembling it from its chunks if needed.
+func readRecord(store chunkStore, key string, data map[string]string) (string, error) {
+	n, err := chunkCount(data)
+	if err != nil {
+		return "", err
+	}
+	if n == 0 {
+		return data[recordDataKey], nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		chunk, err := store.getChunk(chunkKey(key, data[digestDataKey], i))
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to get chunk %d of %q", i, key)
+		}
+		b.WriteString(chunk)
+	}
+	s := b.String()
+	if digest := recordDigest(s); digest != data[digestDataKey] {
+		return "", errors.Errorf("digest mismatch for %q: expected %s, got %s", key, data[digestDataKey], digest)
+	}
+	return s, nil
+}
+
+// deleteChunks deletes the chunks of the record held by the index object of
+// key with the given data, if any.
+func deleteChunks(store chunkStore, key string, data map[string]string) error {
+	n, err := chunkCount(data)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := store.deleteChunk(chunkKey(key, data[digestDataKey], i)); err != nil {
+			return errors.Wrapf(err, "failed to delete chunk %d of %q", i, key)
+		}
+	}
+	return nil
+}
+
+// dropChunks deletes the chunks of the record held by the index object of
+// key with the given data, unless the record held with the data keep uses
+// the same chunks.
+func dropChunks(store chunkStore, key string, data, keep map[string]string) error {
+	if n, _ := chunkCount(keep); n > 0 && data[digestDataKey] == keep[digestDataKey] {
+		return nil
+	}
+	return deleteChunks(store, key, data)
+}
@@ -92,6 +92,14 @@ type Queryor interface {
 	Query(labels map[string]string) ([]*rspb.Release, error)
 }
 
+// KeyLister is implemented by the drivers encrypting the releases they store.
+//
+// Keys returns the keys of the releases stored in the namespace, without
+// reading them, so that records which cannot be decrypted are still found.
+type KeyLister interface {
+	Keys() ([]string, error)
+}
+
 // Driver is the interface composed of Creator, Updator, Deletor, and Queryor
 // interfaces. It defines the behavior for storing, updating, deleted,
 // and retrieving Helm releases from some underlying storage mechanism,
@@ -0,0 +1,279 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Environment variables configuring the encryption of release records.
+const (
+	// EncryptionKeyEnvVar holds comma separated, base64 encoded AES keys.
+	EncryptionKeyEnvVar = "HELM_DRIVER_ENCRYPTION_KEY"
+	// EncryptionKeyFileEnvVar is the path of a file holding base64 encoded
+	// AES keys, one per line.
+	EncryptionKeyFileEnvVar = "HELM_DRIVER_ENCRYPTION_KEY_FILE"
+	// EncryptionKMSEnvVar is the name of the KMS provider wrapping the keys.
+	EncryptionKMSEnvVar = "HELM_DRIVER_ENCRYPTION_KMS"
+)
+
+// ErrUnknownKey indicates a record was encrypted with a key which is not
+// available.
+var ErrUnknownKey = errors.New("release: unknown encryption key")
+
+// Encrypted records are stored as
+//
+//	helm-enc-v1:<base64 header>:<base64 nonce and ciphertext>
+//
+// The ciphertext is the compressed release sealed with AES-256-GCM under a
+// random data key. The JSON header holds the ID of the key which wrapped the
+// data key and the wrapped data key. It is authenticated along with the
+// ciphertext. The prefix never occurs in base64, so records stored before
+// encryption was enabled are still read.
+const encryptedRecordPrefix = "helm-enc-v1:"
+
+type envelopeHeader struct {
+	KeyID string `json:"kid"`
+	Key   []byte `json:"key"`
+}
+
+// KeyProvider wraps the data keys encrypting release records, e.g. with a
+// local key or a key management service.
+type KeyProvider interface {
+	// KeyID identifies the key used by WrapKey. It is stored with each
+	// record, so keys can be rotated.
+	KeyID() string
+	// WrapKey encrypts a data key.
+	WrapKey(key []byte) ([]byte, error)
+	// UnwrapKey decrypts a data key wrapped with the identified key. It
+	// returns ErrUnknownKey if the key is not available.
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// encryptRecord encrypts the encoded release s, unless kp is nil.
+func encryptRecord(kp KeyProvider, s string) (string, error) {
+	if kp == nil {
+		return s, nil
+	}
+	plain, err := b64.DecodeString(s)
+	if err != nil {
+		return "", err
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	wrapped, err := kp.WrapKey(dataKey)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to wrap data key with key %q", kp.KeyID())
+	}
+	header, err := json.Marshal(envelopeHeader{KeyID: kp.KeyID(), Key: wrapped})
+	if err != nil {
+		return "", err
+	}
+	encodedHeader := b64.EncodeToString(header)
+
+	sealed, err := seal(dataKey, plain, []byte(encodedHeader))
+	if err != nil {
+		return "", err
+	}
+	return encryptedRecordPrefix + encodedHeader + ":" + b64.EncodeToString(sealed), nil
+}
+
+// decryptRecord returns the encoded release held by the record s. Records
+// which are not encrypted are returned as is.
+func decryptRecord(kp KeyProvider, s string) (string, error) {
+	if !strings.HasPrefix(s, encryptedRecordPrefix) {
+		return s, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(s, encryptedRecordPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("malformed encrypted release")
+	}
+	encodedHeader := parts[0]
+
+	b, err := b64.DecodeString(encodedHeader)
+	if err != nil {
+		return "", errors.Wrap(err, "malformed encrypted release header")
+	}
+	var header envelopeHeader
+	if err := json.Unmarshal(b, &header); err != nil {
+		return "", errors.Wrap(err, "malformed encrypted release header")
+	}
+	if kp == nil {
+		return "", errors.Errorf("release is encrypted with key %q but no encryption key is configured", header.KeyID)
+	}
+	dataKey, err := kp.UnwrapKey(header.KeyID, header.Key)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to unwrap data key with key %q", header.KeyID)
+	}
+
+	sealed, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.Wrap(err, "malformed encrypted release")
+	}
+	plain, err := open(dataKey, sealed, []byte(encodedHeader))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt release")
+	}
+	return b64.EncodeToString(plain), nil
+}
+
+// seal encrypts plain with AES-GCM and returns the nonce followed by the
+// ciphertext.
+func seal(key, plain, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plain)+gcm.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, additionalData), nil
+}
+
+// open decrypts the output of seal.
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LocalKeys wraps data keys with AES keys held by the client. The first key
+// wraps new data keys, the others are kept to read records written before
+// the keys were rotated.
+type LocalKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// NewLocalKeys creates a LocalKeys from AES-128, AES-192 or AES-256 keys.
+// Each key is identified by its fingerprint.
+func NewLocalKeys(keys ...[]byte) (*LocalKeys, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption key given")
+	}
+	l := &LocalKeys{keys: make(map[string][]byte, len(keys))}
+	for i, key := range keys {
+		switch len(key) {
+		case 16, 24, 32:
+		default:
+			return nil, errors.Errorf("invalid encryption key size %d: must be 16, 24 or 32 bytes", len(key))
+		}
+		id := keyFingerprint(key)
+		if i == 0 {
+			l.current = id
+		}
+		l.keys[id] = key
+	}
+	return l, nil
+}
+
+// ParseLocalKeys creates a LocalKeys from base64 encoded keys separated by
+// commas or newlines.
+func ParseLocalKeys(s string) (*LocalKeys, error) {
+	var keys [][]byte
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, err := b64.DecodeString(field)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid encryption key")
+		}
+		keys = append(keys, key)
+	}
+	return NewLocalKeys(keys...)
+}
+
+// KeyID returns the fingerprint of the first key.
+func (l *LocalKeys) KeyID() string {
+	return l.current
+}
+
+// WrapKey encrypts key with the first key.
+func (l *LocalKeys) WrapKey(key []byte) ([]byte, error) {
+	return seal(l.keys[l.current], key, []byte(l.current))
+}
+
+// UnwrapKey decrypts a key wrapped with the identified key.
+func (l *LocalKeys) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := l.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return open(kek, wrapped, []byte(keyID))
+}
+
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// KeyProviderFromEnv returns the KeyProvider configured by the environment,
+// or nil if the release records are not encrypted.
+func KeyProviderFromEnv() (KeyProvider, error) {
+	if name := os.Getenv(EncryptionKMSEnvVar); name != "" {
+		return kmsProvider(name)
+	}
+	if path := os.Getenv(EncryptionKeyFileEnvVar); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read encryption keys")
+		}
+		return localKeyProvider(string(b))
+	}
+	if keys := os.Getenv(EncryptionKeyEnvVar); keys != "" {
+		return localKeyProvider(keys)
+	}
+	return nil, nil
+}
+
+func localKeyProvider(s string) (KeyProvider, error) {
+	keys, err := ParseLocalKeys(s)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
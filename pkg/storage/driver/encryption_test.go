@@ -0,0 +1,201 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+var (
+	testKeyA = bytes.Repeat([]byte{'a'}, 32)
+	testKeyB = bytes.Repeat([]byte{'b'}, 16)
+)
+
+func testLocalKeys(t *testing.T, keys ...[]byte) *LocalKeys {
+	t.Helper()
+	l, err := NewLocalKeys(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestEncryptRecord(t *testing.T) {
+	rls := releaseStub("rls-a", 1, "default", rspb.StatusDeployed)
+	s, err := encodeRelease(rls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plain records are left alone
+	if got, err := encryptRecord(nil, s); err != nil || got != s {
+		t.Errorf("expected record to be unchanged without key, got %q (%v)", got, err)
+	}
+	if got, err := decryptRecord(nil, s); err != nil || got != s {
+		t.Errorf("expected plain record to be read as is, got %q (%v)", got, err)
+	}
+
+	keys := testLocalKeys(t, testKeyA)
+	enc, err := encryptRecord(keys, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enc, encryptedRecordPrefix) {
+		t.Fatalf("expected encrypted record, got %q", enc)
+	}
+	if got, err := decryptRecord(keys, enc); err != nil || got != s {
+		t.Errorf("expected record to round trip, got %q (%v)", got, err)
+	}
+
+	// rotating keeps the old key for reading
+	rotated := testLocalKeys(t, testKeyB, testKeyA)
+	if got, err := decryptRecord(rotated, enc); err != nil || got != s {
+		t.Errorf("expected record to be read with the old key, got %q (%v)", got, err)
+	}
+	reenc, err := encryptRecord(rotated, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decryptRecord(keys, reenc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("expected %v, got %v", ErrUnknownKey, err)
+	}
+
+	if _, err := decryptRecord(nil, enc); err == nil || !strings.Contains(err.Error(), "no encryption key is configured") {
+		t.Errorf("expected missing key error, got %v", err)
+	}
+
+	// the header is authenticated
+	parts := strings.SplitN(strings.TrimPrefix(enc, encryptedRecordPrefix), ":", 2)
+	header, _ := b64.DecodeString(parts[0])
+	tampered := encryptedRecordPrefix + b64.EncodeToString(append(header, ' ')) + ":" + parts[1]
+	if _, err := decryptRecord(keys, tampered); err == nil {
+		t.Error("expected tampered header to fail decryption")
+	}
+}
+
+func TestParseLocalKeys(t *testing.T) {
+	keys, err := ParseLocalKeys(b64.EncodeToString(testKeyB) + ",\n" + b64.EncodeToString(testKeyA) + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.KeyID() != keyFingerprint(testKeyB) || len(keys.keys) != 2 {
+		t.Errorf("unexpected keys %v", keys)
+	}
+
+	for _, s := range []string{"", "not base64!", b64.EncodeToString([]byte("short"))} {
+		if _, err := ParseLocalKeys(s); err == nil {
+			t.Errorf("expected %q to be rejected", s)
+		}
+	}
+}
+
+func TestKeyProviderFromEnv(t *testing.T) {
+	t.Setenv(EncryptionKMSEnvVar, "")
+	t.Setenv(EncryptionKeyFileEnvVar, "")
+	t.Setenv(EncryptionKeyEnvVar, "")
+	if kp, err := KeyProviderFromEnv(); kp != nil || err != nil {
+		t.Errorf("expected no encryption, got %v (%v)", kp, err)
+	}
+
+	t.Setenv(EncryptionKeyEnvVar, b64.EncodeToString(testKeyA))
+	if kp, err := KeyProviderFromEnv(); err != nil || kp.KeyID() != keyFingerprint(testKeyA) {
+		t.Errorf("expected key from environment, got %v (%v)", kp, err)
+	}
+
+	path := t.TempDir() + "/keys"
+	if err := os.WriteFile(path, []byte(b64.EncodeToString(testKeyB)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EncryptionKeyFileEnvVar, path)
+	if kp, err := KeyProviderFromEnv(); err != nil || kp.KeyID() != keyFingerprint(testKeyB) {
+		t.Errorf("expected key from file, got %v (%v)", kp, err)
+	}
+
+	t.Setenv(EncryptionKMSEnvVar, "missing")
+	if _, err := KeyProviderFromEnv(); err == nil {
+		t.Error("expected unknown KMS provider to fail")
+	}
+}
+
+const kmsKeyEnv = "HELM_TEST_KMS_KEY"
+
+// TestKMSHelperProcess is not a real test. It is run as the KMS plugin by
+// the tests below and wraps keys with the key in its environment.
+func TestKMSHelperProcess(_ *testing.T) {
+	key := os.Getenv(kmsKeyEnv)
+	if key == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req KMSRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+	var res KMSResponse
+	var err error
+	switch req.Operation {
+	case KMSOperationWrap:
+		res.Key, err = seal([]byte(key), req.Key, nil)
+	case KMSOperationUnwrap:
+		res.Key, err = open([]byte(key), req.Key, nil)
+	default:
+		res.Error = "unknown operation " + req.Operation
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	_ = json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func TestKMSPlugin(t *testing.T) {
+	t.Setenv(kmsKeyEnv, string(testKeyA))
+	kms := NewKMSPlugin("test-kms", os.Args[0], []string{"-test.run=TestKMSHelperProcess"})
+
+	secrets := newTestFixtureSecrets(t)
+	secrets.Encryption = kms
+	rls := releaseStub("rls-a", 1, "default", rspb.StatusDeployed)
+	key := testKey(rls.Name, rls.Version)
+	if err := secrets.Create(key, rls); err != nil {
+		t.Fatalf("Failed to create release: %s", err)
+	}
+
+	data := string(secrets.impl.(*MockSecretsInterface).objects[key].Data["release"])
+	if !strings.HasPrefix(data, encryptedRecordPrefix) {
+		t.Fatalf("Expected the release to be encrypted, got %q", data)
+	}
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release: %s", err)
+	}
+	if !reflect.DeepEqual(rls, got) {
+		t.Errorf("Expected {%v}, got {%v}", rls, got)
+	}
+
+	if _, err := kms.UnwrapKey("other-kms", nil); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected %v, got %v", ErrUnknownKey, err)
+	}
+}
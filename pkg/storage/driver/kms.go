@@ -0,0 +1,156 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Operations a KMS plugin must implement.
+const (
+	KMSOperationWrap   = "wrap"
+	KMSOperationUnwrap = "unwrap"
+)
+
+// KMSRequest is written as JSON to the standard input of a KMS plugin, once
+// per operation.
+type KMSRequest struct {
+	Operation string `json:"operation"`
+	// KeyID is the name the plugin was registered under for wrap, and the
+	// key ID stored with the record for unwrap.
+	KeyID string `json:"keyId"`
+	// Key is the data key to wrap or the wrapped data key to unwrap.
+	Key []byte `json:"key"`
+}
+
+// KMSResponse is read as JSON from the standard output of a KMS plugin.
+type KMSResponse struct {
+	Key   []byte `json:"key,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// KMSPlugin is a KeyProvider backed by an external command, usually
+// provided by a Helm plugin wrapping keys with a key management service.
+// The command is run once per operation with a KMSRequest on its standard
+// input and must write a KMSResponse to its standard output.
+type KMSPlugin struct {
+	name    string
+	command string
+	args    []string
+}
+
+// NewKMSPlugin initializes a new KMSPlugin registered under name, which runs
+// command with args.
+func NewKMSPlugin(name, command string, args []string) *KMSPlugin {
+	return &KMSPlugin{
+		name:    name,
+		command: command,
+		args:    args,
+	}
+}
+
+// KeyID returns the name of the plugin.
+func (p *KMSPlugin) KeyID() string {
+	return p.name
+}
+
+// WrapKey asks the plugin to encrypt key.
+func (p *KMSPlugin) WrapKey(key []byte) ([]byte, error) {
+	return p.run(KMSRequest{Operation: KMSOperationWrap, KeyID: p.name, Key: key})
+}
+
+// UnwrapKey asks the plugin to decrypt a wrapped key.
+func (p *KMSPlugin) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	if keyID != p.name {
+		return nil, ErrUnknownKey
+	}
+	return p.run(KMSRequest{Operation: KMSOperationUnwrap, KeyID: keyID, Key: wrapped})
+}
+
+func (p *KMSPlugin) run(req KMSRequest) ([]byte, error) {
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.command, p.args...)
+	cmd.Env = os.Environ()
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("%s: KMS plugin %q failed: %s: %s", req.Operation, p.name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var res KMSResponse
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, errors.Wrapf(err, "%s: invalid response from KMS plugin %q", req.Operation, p.name)
+	}
+	if res.Error != "" {
+		return nil, errors.Errorf("%s: KMS plugin %q failed: %s", req.Operation, p.name, res.Error)
+	}
+	if len(res.Key) == 0 {
+		return nil, errors.Errorf("%s: KMS plugin %q returned no key", req.Operation, p.name)
+	}
+	return res.Key, nil
+}
+
+// KMSConstructor creates the KeyProvider of a KMS provider.
+type KMSConstructor func() (KeyProvider, error)
+
+var (
+	kmsMu       sync.RWMutex
+	kmsRegistry = map[string]KMSConstructor{}
+)
+
+// RegisterKMS makes a KMS provider available under the given name for use
+// with HELM_DRIVER_ENCRYPTION_KMS. It returns an error if a provider is
+// already registered under the name.
+func RegisterKMS(name string, constructor KMSConstructor) error {
+	kmsMu.Lock()
+	defer kmsMu.Unlock()
+
+	if name == "" {
+		return errors.New("KMS provider name must not be empty")
+	}
+	if constructor == nil {
+		return errors.Errorf("KMS provider %q has no constructor", name)
+	}
+	if _, exists := kmsRegistry[name]; exists {
+		return errors.Errorf("KMS provider %q is already registered", name)
+	}
+	kmsRegistry[name] = constructor
+	return nil
+}
+
+func kmsProvider(name string) (KeyProvider, error) {
+	kmsMu.RLock()
+	constructor, ok := kmsRegistry[name]
+	kmsMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown KMS provider %q", name)
+	}
+	return constructor()
+}
@@ -14,12 +14,13 @@ See the License for the specific language governing permissions and
 limitations under the License.
 */
 
-package action
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 
 import (
 	"context"
 	"sync"
 
+	"github.com/pkg/errors"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -45,8 +46,19 @@ type lazyClient struct {
 	namespace string
 }
 
+func newLazyClient(namespace string, config Config) *lazyClient {
+	return &lazyClient{
+		namespace: namespace,
+		clientFn:  config.KubernetesClientSet,
+	}
+}
+
 func (s *lazyClient) init() error {
 	s.initClient.Do(func() {
+		if s.clientFn == nil {
+			s.clientErr = errors.New("no Kubernetes client configured for the storage driver")
+			return
+		}
 		s.client, s.clientErr = s.clientFn()
 	})
 	return s.clientErr
@@ -19,6 +19,7 @@ package driver // import "helm.sh/helm/v3/pkg/storage/driver"
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"testing"
 
 	sqlmock "github.com/DATA-DOG/go-sqlmock"
@@ -47,6 +48,13 @@ func releaseStub(name string, vers int, namespace string, status rspb.Status) *r
 	}
 }
 
+// randomManifest returns a manifest of about n bytes which does not compress.
+func randomManifest(n int) string {
+	b := make([]byte, n/2)
+	rand.New(rand.NewSource(int64(n))).Read(b)
+	return fmt.Sprintf("data: %x\n", b)
+}
+
 func testKey(name string, vers int) string {
 	return fmt.Sprintf("%s.v%d", name, vers)
 }
@@ -175,6 +183,15 @@ func newTestFixtureSecrets(t *testing.T, releases ...*rspb.Release) *Secrets {
 	return NewSecrets(&mock)
 }
 
+// failingConfigMapUpdates is a MockConfigMapsInterface whose updates fail.
+type failingConfigMapUpdates struct {
+	*MockConfigMapsInterface
+}
+
+func (failingConfigMapUpdates) Update(_ context.Context, cfgmap *v1.ConfigMap, _ metav1.UpdateOptions) (*v1.ConfigMap, error) {
+	return nil, apierrors.NewConflict(v1.Resource("tests"), cfgmap.Name, fmt.Errorf("update failed"))
+}
+
 // MockSecretsInterface mocks a kubernetes SecretsInterface
 type MockSecretsInterface struct {
 	corev1.SecretInterface
@@ -267,3 +284,12 @@ func newTestFixtureSQL(t *testing.T, _ ...*rspb.Release) (*SQL, sqlmock.Sqlmock)
 		statementBuilder: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
 	}, mock
 }
+
+// failingSecretUpdates is a MockSecretsInterface whose updates fail.
+type failingSecretUpdates struct {
+	*MockSecretsInterface
+}
+
+func (failingSecretUpdates) Update(_ context.Context, secret *v1.Secret, _ metav1.UpdateOptions) (*v1.Secret, error) {
+	return nil, apierrors.NewConflict(v1.Resource("tests"), secret.Name, fmt.Errorf("update failed"))
+}
@@ -0,0 +1,233 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+// Operations a storage plugin must implement.
+const (
+	PluginOperationGet    = "get"
+	PluginOperationQuery  = "query"
+	PluginOperationCreate = "create"
+	PluginOperationUpdate = "update"
+	PluginOperationDelete = "delete"
+)
+
+// Error codes a storage plugin reports in PluginError.Code.
+const (
+	PluginErrorNotFound      = "NotFound"
+	PluginErrorAlreadyExists = "AlreadyExists"
+)
+
+// PluginRecord is a release stored by a storage plugin.
+type PluginRecord struct {
+	Key string `json:"key"`
+	// Labels must be stored with the release and matched by queries. They
+	// include "name", "owner", "status" and "version".
+	Labels  map[string]string `json:"labels,omitempty"`
+	Release *rspb.Release     `json:"release"`
+}
+
+// PluginRequest is written as JSON to the standard input of a storage
+// plugin, once per operation.
+type PluginRequest struct {
+	Operation string `json:"operation"`
+	Namespace string `json:"namespace"`
+	// Key is set for get, delete and update.
+	Key string `json:"key,omitempty"`
+	// Labels is set for query. All records in the namespace whose labels
+	// contain every given label must be returned.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Record is set for create and update.
+	Record *PluginRecord `json:"record,omitempty"`
+}
+
+// PluginResponse is read as JSON from the standard output of a storage
+// plugin.
+type PluginResponse struct {
+	// Records holds the record for get and delete, and the matching records
+	// for query.
+	Records []PluginRecord `json:"records,omitempty"`
+	Error   *PluginError   `json:"error,omitempty"`
+}
+
+// PluginError is an error reported by a storage plugin.
+type PluginError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Plugin is a storage driver backed by an external command, usually
+// provided by a Helm plugin. The command is run once per operation with a
+// PluginRequest on its standard input and must write a PluginResponse to
+// its standard output. A non-zero exit status fails the operation. Releases
+// are handed to the plugin unencrypted, so it can't be used with release
+// encryption.
+type Plugin struct {
+	name      string
+	command   string
+	args      []string
+	namespace string
+
+	Log func(string, ...interface{})
+}
+
+// NewPlugin initializes a new Plugin driver registered under name, which
+// runs command with args.
+func NewPlugin(name, command string, args []string, namespace string) *Plugin {
+	return &Plugin{
+		name:      name,
+		command:   command,
+		args:      args,
+		namespace: namespace,
+		Log:       func(_ string, _ ...interface{}) {},
+	}
+}
+
+// Name returns the name of the driver.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Get returns the release named by key.
+func (p *Plugin) Get(key string) (*rspb.Release, error) {
+	records, err := p.run(PluginRequest{Operation: PluginOperationGet, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	return records[0], nil
+}
+
+// List returns the list of all releases such that filter(release) == true.
+func (p *Plugin) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
+	records, err := p.run(PluginRequest{Operation: PluginOperationQuery, Labels: map[string]string{"owner": "helm"}})
+	if err != nil && !errors.Is(err, ErrReleaseNotFound) {
+		return nil, errors.Wrap(err, "list: failed to list")
+	}
+	var results []*rspb.Release
+	for _, rls := range records {
+		if filter(rls) {
+			results = append(results, rls)
+		}
+	}
+	return results, nil
+}
+
+// Query returns the set of releases that match the provided set of labels.
+func (p *Plugin) Query(labels map[string]string) ([]*rspb.Release, error) {
+	records, err := p.run(PluginRequest{Operation: PluginOperationQuery, Labels: labels})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	return records, nil
+}
+
+// Create creates a new release or returns ErrReleaseExists.
+func (p *Plugin) Create(key string, rls *rspb.Release) error {
+	_, err := p.run(PluginRequest{Operation: PluginOperationCreate, Key: key, Record: newPluginRecord(key, rls)})
+	return err
+}
+
+// Update updates a release or returns ErrReleaseNotFound.
+func (p *Plugin) Update(key string, rls *rspb.Release) error {
+	_, err := p.run(PluginRequest{Operation: PluginOperationUpdate, Key: key, Record: newPluginRecord(key, rls)})
+	return err
+}
+
+// Delete deletes a release or returns ErrReleaseNotFound.
+func (p *Plugin) Delete(key string) (*rspb.Release, error) {
+	records, err := p.run(PluginRequest{Operation: PluginOperationDelete, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrReleaseNotFound
+	}
+	return records[0], nil
+}
+
+func newPluginRecord(key string, rls *rspb.Release) *PluginRecord {
+	var lbs labels
+	lbs.init()
+	lbs.fromMap(rls.Labels)
+	lbs.set("name", rls.Name)
+	lbs.set("owner", "helm")
+	lbs.set("status", rls.Info.Status.String())
+	lbs.set("version", strconv.Itoa(rls.Version))
+	return &PluginRecord{Key: key, Labels: lbs.toMap(), Release: rls}
+}
+
+// run sends req to the plugin and returns the releases of the response.
+func (p *Plugin) run(req PluginRequest) ([]*rspb.Release, error) {
+	req.Namespace = p.namespace
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s: failed to encode request", req.Operation)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(p.command, p.args...)
+	cmd.Env = os.Environ()
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("%s: storage plugin %q failed: %s: %s", req.Operation, p.name, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var res PluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, errors.Wrapf(err, "%s: invalid response from storage plugin %q", req.Operation, p.name)
+	}
+	if res.Error != nil {
+		switch res.Error.Code {
+		case PluginErrorNotFound:
+			return nil, ErrReleaseNotFound
+		case PluginErrorAlreadyExists:
+			return nil, ErrReleaseExists
+		}
+		return nil, errors.Errorf("%s: storage plugin %q failed: %s", req.Operation, p.name, res.Error.Message)
+	}
+
+	releases := make([]*rspb.Release, 0, len(res.Records))
+	for _, r := range res.Records {
+		if r.Release == nil {
+			p.Log("%s: storage plugin %q returned record %q without release", req.Operation, p.name, r.Key)
+			continue
+		}
+		r.Release.Labels = filterSystemLabels(r.Labels)
+		releases = append(releases, r.Release)
+	}
+	return releases, nil
+}
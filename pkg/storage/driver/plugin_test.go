@@ -0,0 +1,158 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rspb "helm.sh/helm/v3/pkg/release"
+)
+
+const pluginStoreEnv = "HELM_TEST_STORAGE_PLUGIN_STORE"
+
+// TestPluginHelperProcess is not a real test. It is run as the storage
+// plugin by the tests below and keeps its records in a JSON file.
+func TestPluginHelperProcess(_ *testing.T) {
+	path := os.Getenv(pluginStoreEnv)
+	if path == "" {
+		return
+	}
+	defer os.Exit(0)
+
+	var req PluginRequest
+	if err := json.NewDecoder(os.Stdin).Decode(&req); err != nil {
+		os.Stderr.WriteString(err.Error())
+		os.Exit(1)
+	}
+	store := map[string]PluginRecord{}
+	if b, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(b, &store)
+	}
+
+	var res PluginResponse
+	id := req.Namespace + "/" + req.Key
+	switch req.Operation {
+	case PluginOperationGet, PluginOperationDelete:
+		r, ok := store[id]
+		if !ok {
+			res.Error = &PluginError{Code: PluginErrorNotFound}
+			break
+		}
+		res.Records = []PluginRecord{r}
+		if req.Operation == PluginOperationDelete {
+			delete(store, id)
+		}
+	case PluginOperationCreate:
+		if _, ok := store[id]; ok {
+			res.Error = &PluginError{Code: PluginErrorAlreadyExists}
+			break
+		}
+		store[id] = *req.Record
+	case PluginOperationUpdate:
+		if _, ok := store[id]; !ok {
+			res.Error = &PluginError{Code: PluginErrorNotFound}
+			break
+		}
+		store[id] = *req.Record
+	case PluginOperationQuery:
+		for k, r := range store {
+			if filepath.Dir(k) == req.Namespace && labels(r.Labels).match(req.Labels) {
+				res.Records = append(res.Records, r)
+			}
+		}
+	default:
+		res.Error = &PluginError{Message: "unknown operation " + req.Operation}
+	}
+
+	b, _ := json.Marshal(store)
+	_ = os.WriteFile(path, b, 0644)
+	_ = json.NewEncoder(os.Stdout).Encode(res)
+}
+
+func newTestPlugin(t *testing.T, namespace string) *Plugin {
+	t.Helper()
+	t.Setenv(pluginStoreEnv, filepath.Join(t.TempDir(), "store.json"))
+	return NewPlugin("test", os.Args[0], []string{"-test.run=TestPluginHelperProcess"}, namespace)
+}
+
+func TestPluginName(t *testing.T) {
+	if p := NewPlugin("mydb", "mydb", nil, "default"); p.Name() != "mydb" {
+		t.Errorf("Expected name to be %q, got %q", "mydb", p.Name())
+	}
+}
+
+func TestPluginCRUD(t *testing.T) {
+	p := newTestPlugin(t, "default")
+
+	rls := releaseStub("rls-a", 1, "default", rspb.StatusDeployed)
+	key := testKey(rls.Name, rls.Version)
+	if err := p.Create(key, rls); err != nil {
+		t.Fatalf("failed to create release: %s", err)
+	}
+	if err := p.Create(key, rls); err != ErrReleaseExists {
+		t.Errorf("expected ErrReleaseExists, got %v", err)
+	}
+
+	got, err := p.Get(key)
+	if err != nil {
+		t.Fatalf("failed to get release: %s", err)
+	}
+	if got.Name != rls.Name || got.Labels["key1"] != "val1" {
+		t.Errorf("unexpected release %+v with labels %v", got, got.Labels)
+	}
+	if ContainsSystemLabels(got.Labels) {
+		t.Errorf("expected the system labels to be filtered from %v", got.Labels)
+	}
+
+	rls.Info.Status = rspb.StatusSuperseded
+	if err := p.Update(key, rls); err != nil {
+		t.Fatalf("failed to update release: %s", err)
+	}
+	if err := p.Update("missing.v1", rls); err != ErrReleaseNotFound {
+		t.Errorf("expected ErrReleaseNotFound, got %v", err)
+	}
+
+	res, err := p.Query(map[string]string{"name": "rls-a", "status": "superseded"})
+	if err != nil || len(res) != 1 {
+		t.Fatalf("expected one superseded release, got %v (%v)", res, err)
+	}
+	if _, err := p.Query(map[string]string{"name": "rls-a", "status": "deployed"}); err != ErrReleaseNotFound {
+		t.Errorf("expected ErrReleaseNotFound, got %v", err)
+	}
+
+	all, err := p.List(func(_ *rspb.Release) bool { return true })
+	if err != nil || len(all) != 1 {
+		t.Fatalf("expected one release, got %v (%v)", all, err)
+	}
+
+	if _, err := p.Delete(key); err != nil {
+		t.Fatalf("failed to delete release: %s", err)
+	}
+	if _, err := p.Get(key); err != ErrReleaseNotFound {
+		t.Errorf("expected ErrReleaseNotFound, got %v", err)
+	}
+}
+
+func TestPluginFailure(t *testing.T) {
+	p := NewPlugin("broken", "false", nil, "default")
+	if _, err := p.Get("rls-a.v1"); err == nil {
+		t.Error("expected an error from a failing plugin")
+	}
+}
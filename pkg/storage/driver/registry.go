@@ -0,0 +1,172 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver // import "helm.sh/helm/v3/pkg/storage/driver"
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Config is passed to a Constructor when a driver is created.
+type Config struct {
+	// KubernetesClientSet loads the Kubernetes client for drivers that store
+	// releases in the cluster. Built-in drivers only call it once the client
+	// is first needed.
+	KubernetesClientSet func() (*kubernetes.Clientset, error)
+
+	// Current is the driver used so far, if any. A driver may reuse its
+	// state, e.g. the memory driver keeps the releases it already holds.
+	Current Driver
+
+	// Encryption encrypts the releases stored by drivers which support it.
+	// New configures it from the environment if it is not set, and fails
+	// for drivers which don't use it, like the memory and plugin drivers.
+	Encryption KeyProvider
+}
+
+// Constructor creates a driver storing releases in the given namespace.
+type Constructor func(namespace string, log func(string, ...interface{}), config Config) (Driver, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Constructor{}
+)
+
+func init() {
+	secrets := func(namespace string, log func(string, ...interface{}), config Config) (Driver, error) {
+		d := NewSecrets(newSecretClient(newLazyClient(namespace, config)))
+		d.Log = log
+		d.Encryption = config.Encryption
+		return d, nil
+	}
+	configMaps := func(namespace string, log func(string, ...interface{}), config Config) (Driver, error) {
+		d := NewConfigMaps(newConfigMapClient(newLazyClient(namespace, config)))
+		d.Log = log
+		d.Encryption = config.Encryption
+		return d, nil
+	}
+	memory := func(namespace string, _ func(string, ...interface{}), config Config) (Driver, error) {
+		// The driver can be created more than once (e.g., helm list --all-namespaces).
+		// If a memory driver was already created, re-use it but set the possibly new namespace.
+		// We re-use it in case some releases where already created in the existing memory driver.
+		d, ok := config.Current.(*Memory)
+		if !ok {
+			d = NewMemory()
+		}
+		d.SetNamespace(namespace)
+		return d, nil
+	}
+	sql := func(namespace string, log func(string, ...interface{}), config Config) (Driver, error) {
+		d, err := NewSQL(os.Getenv("HELM_DRIVER_SQL_CONNECTION_STRING"), log, namespace)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to instantiate SQL driver")
+		}
+		d.Encryption = config.Encryption
+		return d, nil
+	}
+
+	for name, c := range map[string]Constructor{
+		"secret":     secrets,
+		"secrets":    secrets,
+		"configmap":  configMaps,
+		"configmaps": configMaps,
+		"memory":     memory,
+		"sql":        sql,
+	} {
+		registry[name] = c
+	}
+}
+
+// Register makes a driver available under the given name, e.g. for use with
+// HELM_DRIVER. It returns an error if a driver is already registered under
+// the name.
+func Register(name string, constructor Constructor) error {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if name == "" {
+		return errors.New("driver name must not be empty")
+	}
+	if constructor == nil {
+		return errors.Errorf("driver %q has no constructor", name)
+	}
+	if _, exists := registry[name]; exists {
+		return errors.Errorf("driver %q is already registered", name)
+	}
+	registry[name] = constructor
+	return nil
+}
+
+// Registered returns the sorted names of all registered drivers.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the driver registered under the given name. The secrets
+// driver is used if the name is empty.
+func New(name, namespace string, log func(string, ...interface{}), config Config) (Driver, error) {
+	if name == "" {
+		name = "secret"
+	}
+
+	registryMu.RLock()
+	constructor, ok := registry[name]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, errors.Errorf("unknown driver %q", name)
+	}
+	if log == nil {
+		log = func(_ string, _ ...interface{}) {}
+	}
+	if config.Encryption == nil {
+		kp, err := KeyProviderFromEnv()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to configure release encryption")
+		}
+		config.Encryption = kp
+	}
+	d, err := constructor(namespace, log, config)
+	if err != nil {
+		return nil, err
+	}
+	// Storing the releases unencrypted when encryption is configured would
+	// go unnoticed.
+	if config.Encryption != nil {
+		if e, ok := d.(encrypter); !ok || e.encryption() == nil {
+			return nil, errors.Errorf("driver %q does not support release encryption", name)
+		}
+	}
+	return d, nil
+}
+
+// encrypter is implemented by the drivers which encrypt the releases they
+// store.
+type encrypter interface {
+	encryption() KeyProvider
+}
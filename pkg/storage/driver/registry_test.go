@@ -0,0 +1,129 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package driver
+
+import (
+	"testing"
+)
+
+func TestRegistryBuiltins(t *testing.T) {
+	for name, want := range map[string]string{
+		"":           SecretsDriverName,
+		"secret":     SecretsDriverName,
+		"secrets":    SecretsDriverName,
+		"configmap":  ConfigMapsDriverName,
+		"configmaps": ConfigMapsDriverName,
+		"memory":     MemoryDriverName,
+	} {
+		d, err := New(name, "default", nil, Config{})
+		if err != nil {
+			t.Fatalf("failed to create driver %q: %s", name, err)
+		}
+		if d.Name() != want {
+			t.Errorf("expected driver %q to be %q, got %q", name, want, d.Name())
+		}
+	}
+
+	if _, err := New("nope", "default", nil, Config{}); err == nil {
+		t.Error("expected an error for an unknown driver")
+	}
+}
+
+func TestRegistryMemoryReuse(t *testing.T) {
+	mem := NewMemory()
+	d, err := New("memory", "other", nil, Config{Current: mem})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d != mem {
+		t.Error("expected the current memory driver to be reused")
+	}
+	if mem.namespace != "other" {
+		t.Errorf("expected namespace %q, got %q", "other", mem.namespace)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	constructor := func(namespace string, _ func(string, ...interface{}), _ Config) (Driver, error) {
+		return NewPlugin("test-register", "true", nil, namespace), nil
+	}
+	if err := Register("test-register", constructor); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test-register")
+		registryMu.Unlock()
+	})
+
+	if err := Register("test-register", constructor); err == nil {
+		t.Error("expected an error registering a driver twice")
+	}
+	if err := Register("secret", constructor); err == nil {
+		t.Error("expected an error registering over a built-in driver")
+	}
+
+	d, err := New("test-register", "default", nil, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Name() != "test-register" {
+		t.Errorf("unexpected driver %q", d.Name())
+	}
+
+	found := false
+	for _, name := range Registered() {
+		found = found || name == "test-register"
+	}
+	if !found {
+		t.Errorf("expected %q in %v", "test-register", Registered())
+	}
+}
+
+func TestRegistryEncryption(t *testing.T) {
+	keys, err := NewLocalKeys(testKeyA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := Config{Encryption: keys}
+
+	d, err := New("secret", "default", nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.(*Secrets).Encryption != keys {
+		t.Error("expected the secrets driver to encrypt releases")
+	}
+
+	// Drivers that would store the releases unencrypted are refused.
+	if _, err := New("memory", "default", nil, config); err == nil || err.Error() != `driver "memory" does not support release encryption` {
+		t.Errorf("expected the memory driver to be refused, got %v", err)
+	}
+	if err := Register("test-plain", func(namespace string, _ func(string, ...interface{}), _ Config) (Driver, error) {
+		return NewPlugin("test-plain", "true", nil, namespace), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test-plain")
+		registryMu.Unlock()
+	})
+	if _, err := New("test-plain", "default", nil, config); err == nil {
+		t.Error("expected the plugin driver to be refused")
+	}
+}
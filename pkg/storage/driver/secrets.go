@@ -43,6 +43,13 @@ const SecretsDriverName = "Secret"
 type Secrets struct {
 	impl corev1.SecretInterface
 	Log  func(string, ...interface{})
+
+	// ChunkSize is the maximum size of the encoded release stored in a
+	// single Secret. Larger releases are split across several Secrets.
+	// DefaultChunkSize is used if it is not set.
+	ChunkSize int
+	// Encryption, if set, encrypts the releases stored from now on.
+	Encryption KeyProvider
 }
 
 // NewSecrets initializes a new Secrets wrapping an implementation of
@@ -59,6 +66,27 @@ func (secrets *Secrets) Name() string {
 	return SecretsDriverName
 }
 
+// Keys returns the keys of all releases, including those that cannot be
+// decoded.
+func (secrets *Secrets) Keys() ([]string, error) {
+	lsel := kblabels.Set{"owner": "helm"}.AsSelector()
+	opts := metav1.ListOptions{LabelSelector: lsel.String()}
+
+	list, err := secrets.impl.List(context.Background(), opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "keys: failed to list")
+	}
+	keys := make([]string, 0, len(list.Items))
+	for _, item := range list.Items {
+		keys = append(keys, item.Name)
+	}
+	return keys, nil
+}
+
+func (secrets *Secrets) encryption() KeyProvider {
+	return secrets.Encryption
+}
+
 // Get fetches the release named by key. The corresponding release is returned
 // or error if not found.
 func (secrets *Secrets) Get(key string) (*rspb.Release, error) {
@@ -71,9 +99,12 @@ func (secrets *Secrets) Get(key string) (*rspb.Release, error) {
 		return nil, errors.Wrapf(err, "get: failed to get %q", key)
 	}
 	// found the secret, decode the base64 data string
-	r, err := decodeRelease(string(obj.Data["release"]))
+	r, err := secrets.decode(obj)
+	if err != nil {
+		return nil, errors.Wrapf(err, "get: failed to decode data %q", key)
+	}
 	r.Labels = filterSystemLabels(obj.ObjectMeta.Labels)
-	return r, errors.Wrapf(err, "get: failed to decode data %q", key)
+	return r, nil
 }
 
 // List fetches all releases and returns the list releases such
@@ -93,7 +124,7 @@ func (secrets *Secrets) List(filter func(*rspb.Release) bool) ([]*rspb.Release,
 	// iterate over the secrets object list
 	// and decode each release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+		rls, err := secrets.decode(&item)
 		if err != nil {
 			secrets.Log("list: failed to decode release: %v: %s", item, err)
 			continue
@@ -132,7 +163,7 @@ func (secrets *Secrets) Query(labels map[string]string) ([]*rspb.Release, error)
 
 	var results []*rspb.Release
 	for _, item := range list.Items {
-		rls, err := decodeRelease(string(item.Data["release"]))
+		rls, err := secrets.decode(&item)
 		if err != nil {
 			secrets.Log("query: failed to decode release: %s", err)
 			continue
@@ -158,8 +189,27 @@ func (secrets *Secrets) Create(key string, rls *rspb.Release) error {
 	if err != nil {
 		return errors.Wrapf(err, "create: failed to encode release %q", rls.Name)
 	}
+	if err := secrets.encrypt(obj); err != nil {
+		return errors.Wrapf(err, "create: failed to encrypt release %q", rls.Name)
+	}
+	if secrets.oversized(obj) {
+		if _, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+			return ErrReleaseExists
+		}
+		if err := secrets.split(obj, nil); err != nil {
+			return errors.Wrap(err, "create: failed to split release")
+		}
+	}
 	// push the secret object out into the kubiverse
 	if _, err := secrets.impl.Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+		// drop the chunks written, unless they are those of the release
+		// created in the meantime
+		var existing map[string]string
+		if other, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+			existing = secretData(other)
+		}
+		_ = dropChunks(secretChunks{secrets.impl}, key, secretData(obj), existing)
+
 		if apierrors.IsAlreadyExists(err) {
 			return ErrReleaseExists
 		}
@@ -184,9 +234,26 @@ func (secrets *Secrets) Update(key string, rls *rspb.Release) error {
 	if err != nil {
 		return errors.Wrapf(err, "update: failed to encode release %q", rls.Name)
 	}
+	if err := secrets.encrypt(obj); err != nil {
+		return errors.Wrapf(err, "update: failed to encrypt release %q", rls.Name)
+	}
+	// remember the chunks of the previous record, which are deleted once the
+	// record is updated
+	var old map[string]string
+	if obj, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{}); err == nil {
+		old = secretData(obj)
+	}
+	if secrets.oversized(obj) {
+		if err := secrets.split(obj, old); err != nil {
+			return errors.Wrap(err, "update: failed to split release")
+		}
+	}
 	// push the secret object out into the kubiverse
-	_, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{})
-	return errors.Wrap(err, "update: failed to update")
+	if _, err = secrets.impl.Update(context.Background(), obj, metav1.UpdateOptions{}); err != nil {
+		_ = dropChunks(secretChunks{secrets.impl}, key, secretData(obj), old)
+		return errors.Wrap(err, "update: failed to update")
+	}
+	return errors.Wrap(dropChunks(secretChunks{secrets.impl}, key, old, secretData(obj)), "update: failed to clean up chunks")
 }
 
 // Delete deletes the Secret holding the release named by key.
@@ -195,9 +262,108 @@ func (secrets *Secrets) Delete(key string) (rls *rspb.Release, err error) {
 	if rls, err = secrets.Get(key); err != nil {
 		return nil, err
 	}
+	obj, err := secrets.impl.Get(context.Background(), key, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
 	// delete the release
-	err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{})
-	return rls, err
+	if err = secrets.impl.Delete(context.Background(), key, metav1.DeleteOptions{}); err != nil {
+		return rls, err
+	}
+	// delete its chunks, if any
+	return rls, deleteChunks(secretChunks{secrets.impl}, key, secretData(obj))
+}
+
+// decode returns the release held by obj, reassembling it from its chunks if
+// it was split and decrypting it if it was encrypted.
+func (secrets *Secrets) decode(obj *v1.Secret) (*rspb.Release, error) {
+	s, err := readRecord(secretChunks{secrets.impl}, obj.Name, secretData(obj))
+	if err != nil {
+		return nil, err
+	}
+	if s, err = decryptRecord(secrets.Encryption, s); err != nil {
+		return nil, err
+	}
+	return decodeRelease(s)
+}
+
+// encrypt encrypts the release held by obj if encryption is enabled.
+func (secrets *Secrets) encrypt(obj *v1.Secret) error {
+	s, err := encryptRecord(secrets.Encryption, string(obj.Data[recordDataKey]))
+	if err != nil {
+		return err
+	}
+	obj.Data[recordDataKey] = []byte(s)
+	return nil
+}
+
+// oversized returns true if the release held by obj must be split.
+func (secrets *Secrets) oversized(obj *v1.Secret) bool {
+	size := secrets.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	return len(obj.Data[recordDataKey]) > size
+}
+
+// split moves the release held by obj into chunk Secrets and replaces it
+// with the number of chunks and the digest of the release. The chunks of the
+// current record, held with the data current, are kept if it fails.
+func (secrets *Secrets) split(obj *v1.Secret, current map[string]string) error {
+	index, err := writeChunks(secretChunks{secrets.impl}, obj.Name, string(obj.Data[recordDataKey]), secrets.ChunkSize, obj.Labels, current)
+	if err != nil {
+		return err
+	}
+	obj.Data = make(map[string][]byte, len(index))
+	for k, v := range index {
+		obj.Data[k] = []byte(v)
+	}
+	return nil
+}
+
+func secretData(obj *v1.Secret) map[string]string {
+	data := make(map[string]string, len(obj.Data))
+	for k, v := range obj.Data {
+		data[k] = string(v)
+	}
+	return data
+}
+
+// secretChunks stores the chunks of split releases in Secrets.
+type secretChunks struct {
+	impl corev1.SecretInterface
+}
+
+func (c secretChunks) getChunk(name string) (string, error) {
+	obj, err := c.impl.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(obj.Data[recordDataKey]), nil
+}
+
+func (c secretChunks) putChunk(name, data string, lbs labels) error {
+	obj := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: lbs.toMap(),
+		},
+		Type: "helm.sh/release-chunk.v1",
+		Data: map[string][]byte{recordDataKey: []byte(data)},
+	}
+	_, err := c.impl.Create(context.Background(), obj, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+func (c secretChunks) deleteChunk(name string) error {
+	err := c.impl.Delete(context.Background(), name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
 }
 
 // newSecretsObject constructs a kubernetes Secret object
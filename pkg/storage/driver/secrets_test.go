@@ -17,6 +17,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"reflect"
+	"strings"
 	"testing"
 
 	v1 "k8s.io/api/core/v1"
@@ -249,3 +250,102 @@ func TestSecretDelete(t *testing.T) {
 		t.Errorf("Expected {%v}, got {%v}", ErrReleaseNotFound, err)
 	}
 }
+
+func TestSecretChunks(t *testing.T) {
+	vers := 1
+	name := "smug-pigeon"
+	namespace := "default"
+	key := testKey(name, vers)
+	rel := releaseStub(name, vers, namespace, rspb.StatusDeployed)
+
+	// chunks only fit the release without a manifest
+	small, err := encodeRelease(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel.Manifest = randomManifest(2048)
+
+	secrets := newTestFixtureSecrets(t)
+	secrets.ChunkSize = len(small)
+	mock := secrets.impl.(*MockSecretsInterface)
+
+	if err := secrets.Create(key, rel); err != nil {
+		t.Fatalf("Failed to create release with key %q: %s", key, err)
+	}
+	index := mock.objects[key]
+	if _, ok := index.Data["release"]; ok {
+		t.Fatal("Expected the release to be split across chunks")
+	}
+	chunks, err := chunkCount(secretData(index))
+	if err != nil || chunks < 2 {
+		t.Fatalf("Expected several chunks, got %d: %v", chunks, err)
+	}
+	if len(mock.objects) != chunks+1 {
+		t.Errorf("Expected %d secrets, got %d", chunks+1, len(mock.objects))
+	}
+	if err := secrets.Create(key, rel); err != ErrReleaseExists {
+		t.Errorf("Expected %v, got %v", ErrReleaseExists, err)
+	}
+
+	// get, list and query reassemble the release
+	got, err := secrets.Get(key)
+	if err != nil {
+		t.Fatalf("Failed to get release with key %q: %s", key, err)
+	}
+	if !reflect.DeepEqual(rel, got) {
+		t.Errorf("Expected {%v}, got {%v}", rel, got)
+	}
+	all, err := secrets.List(func(_ *rspb.Release) bool { return true })
+	if err != nil || len(all) != 1 || all[0].Manifest != rel.Manifest {
+		t.Errorf("Expected the release to be listed once, got %d releases: %v", len(all), err)
+	}
+	found, err := secrets.Query(map[string]string{"name": name, "owner": "helm"})
+	if err != nil || len(found) != 1 || found[0].Manifest != rel.Manifest {
+		t.Errorf("Expected the release to be found once, got %d releases: %v", len(found), err)
+	}
+
+	// a corrupted chunk is detected
+	chunk := mock.objects[chunkKey(key, secretData(index)["digest"], 0)]
+	data := chunk.Data["release"]
+	chunk.Data["release"] = []byte(strings.ToUpper(string(data)))
+	if _, err := secrets.Get(key); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("Expected digest mismatch, got %v", err)
+	}
+	chunk.Data["release"] = data
+
+	// a failed update leaves the current release and its chunks as they are
+	grown := *rel
+	grown.Manifest = randomManifest(4096)
+	secrets.impl = failingSecretUpdates{mock}
+	if err := secrets.Update(key, &grown); err == nil {
+		t.Fatal("Expected the update to fail")
+	}
+	secrets.impl = mock
+	if got, err := secrets.Get(key); err != nil || got.Manifest != rel.Manifest {
+		t.Errorf("Expected the release to be unchanged: %v", err)
+	}
+	if len(mock.objects) != chunks+1 {
+		t.Errorf("Expected the chunks written to be deleted, got %d objects", len(mock.objects))
+	}
+
+	// a smaller release drops the chunks
+	rel.Manifest = ""
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if len(mock.objects) != 1 {
+		t.Errorf("Expected stale chunks to be deleted, got %d secrets", len(mock.objects))
+	}
+
+	// deleting a split release deletes its chunks
+	rel.Manifest = randomManifest(2048)
+	if err := secrets.Update(key, rel); err != nil {
+		t.Fatalf("Failed to update release: %s", err)
+	}
+	if _, err := secrets.Delete(key); err != nil {
+		t.Fatalf("Failed to delete release with key %q: %s", key, err)
+	}
+	if len(mock.objects) != 0 {
+		t.Errorf("Expected all secrets to be deleted, got %d", len(mock.objects))
+	}
+}
@@ -88,6 +88,8 @@ type SQL struct {
 	statementBuilder sq.StatementBuilderType
 
 	Log func(string, ...interface{})
+	// Encryption, if set, encrypts the releases stored from now on.
+	Encryption KeyProvider
 }
 
 // Name returns the name of the driver.
@@ -95,6 +97,50 @@ func (s *SQL) Name() string {
 	return SQLDriverName
 }
 
+// Keys returns the keys of all releases of the namespace, including those that
+// cannot be decoded.
+func (s *SQL) Keys() ([]string, error) {
+	query, args, err := s.statementBuilder.
+		Select(sqlReleaseTableKeyColumn).
+		From(sqlReleaseTableName).
+		Where(sq.Eq{sqlReleaseTableOwnerColumn: sqlReleaseDefaultOwner}).
+		Where(sq.Eq{sqlReleaseTableNamespaceColumn: s.namespace}).
+		ToSql()
+	if err != nil {
+		s.Log("failed to build query: %v", err)
+		return nil, err
+	}
+
+	var keys []string
+	if err := s.db.Select(&keys, query, args...); err != nil {
+		s.Log("keys: failed to list: %v", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *SQL) encryption() KeyProvider {
+	return s.Encryption
+}
+
+// encode encodes a release and encrypts it if encryption is enabled.
+func (s *SQL) encode(rls *rspb.Release) (string, error) {
+	body, err := encodeRelease(rls)
+	if err != nil {
+		return "", err
+	}
+	return encryptRecord(s.Encryption, body)
+}
+
+// decode decrypts a release if it was encrypted and decodes it.
+func (s *SQL) decode(body string) (*rspb.Release, error) {
+	body, err := decryptRecord(s.Encryption, body)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRelease(body)
+}
+
 // Check if all migrations al
 func (s *SQL) checkAlreadyApplied(migrations []*migrate.Migration) bool {
 	// make map (set) of ids for fast search
@@ -319,7 +365,7 @@ func (s *SQL) Get(key string) (*rspb.Release, error) {
 		return nil, ErrReleaseNotFound
 	}
 
-	release, err := decodeRelease(record.Body)
+	release, err := s.decode(record.Body)
 	if err != nil {
 		s.Log("get: failed to decode data %q: %v", key, err)
 		return nil, err
@@ -359,7 +405,7 @@ func (s *SQL) List(filter func(*rspb.Release) bool) ([]*rspb.Release, error) {
 
 	var releases []*rspb.Release
 	for _, record := range records {
-		release, err := decodeRelease(record.Body)
+		release, err := s.decode(record.Body)
 		if err != nil {
 			s.Log("list: failed to decode release: %v: %v", record, err)
 			continue
@@ -425,7 +471,7 @@ func (s *SQL) Query(labels map[string]string) ([]*rspb.Release, error) {
 
 	var releases []*rspb.Release
 	for _, record := range records {
-		release, err := decodeRelease(record.Body)
+		release, err := s.decode(record.Body)
 		if err != nil {
 			s.Log("list: failed to decode release: %v: %v", record, err)
 			continue
@@ -454,7 +500,7 @@ func (s *SQL) Create(key string, rls *rspb.Release) error {
 	}
 	s.namespace = namespace
 
-	body, err := encodeRelease(rls)
+	body, err := s.encode(rls)
 	if err != nil {
 		s.Log("failed to encode release: %v", err)
 		return err
@@ -561,7 +607,7 @@ func (s *SQL) Update(key string, rls *rspb.Release) error {
 	}
 	s.namespace = namespace
 
-	body, err := encodeRelease(rls)
+	body, err := s.encode(rls)
 	if err != nil {
 		s.Log("failed to encode release: %v", err)
 		return err
@@ -618,7 +664,7 @@ func (s *SQL) Delete(key string) (*rspb.Release, error) {
 		return nil, ErrReleaseNotFound
 	}
 
-	release, err := decodeRelease(record.Body)
+	release, err := s.decode(record.Body)
 	if err != nil {
 		s.Log("failed to decode release %s: %v", key, err)
 		transaction.Rollback()